@@ -9,14 +9,33 @@ import (
 
 var JwtKey []byte
 
+// AccessTokenTTL is how long an access token is valid. Clients renew it
+// with a refresh token.
+var AccessTokenTTL = 15 * time.Minute
+
+// TokenTimePrecision is the precision of the times in a token. Whole seconds
+// would make a token issued just after logging out everywhere look as if it
+// was issued before.
+const TokenTimePrecision = time.Millisecond
+
+func init() {
+	// Token times are parsed through a float and truncated to this
+	// precision; keeping it finer than TokenTimePrecision leaves room to
+	// round them back to the millisecond they were issued in
+	jwt.TimePrecision = time.Microsecond
+}
+
 type Claims struct {
 	UserID int `json:"user_id"`
 	Email  string `json:"email"`
 	jwt.RegisteredClaims
 }
 
-func InitializeAuth(secret string) {
+func InitializeAuth(secret string, accessTTL time.Duration) {
 	JwtKey = []byte(secret)
+	if accessTTL > 0 {
+		AccessTokenTTL = accessTTL
+	}
 }
 
 func GenerateToken(userID int, email string) (string, error) {
@@ -24,14 +43,21 @@ func GenerateToken(userID int, email string) (string, error) {
 		return "", errors.New("JWT key not initialized")
 	}
 
-	expirationTime := time.Now().Add(24 * time.Hour)
+	jti, err := NewTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().Truncate(TokenTimePrecision)
+	expirationTime := now.Add(AccessTokenTTL)
 	claims := &Claims{
 		UserID: userID,
 		Email:  email,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
@@ -57,5 +83,15 @@ func ValidateToken(tokenString string) (*Claims, error) {
 		return nil, errors.New("invalid token")
 	}
 
+	// Tokens without an ID predate revocation and can't be revoked
+	if claims.ID == "" {
+		return nil, errors.New("token has no ID")
+	}
+
+	// Parsing can land just short of the millisecond the token was issued in
+	if claims.IssuedAt != nil {
+		claims.IssuedAt.Time = claims.IssuedAt.Round(TokenTimePrecision)
+	}
+
 	return claims, nil
 }
\ No newline at end of file
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// countMetrics are tallies of problems: any increase is a regression. Every
+// other metric is a score where a drop beyond the tolerance is.
+var countMetrics = map[string]bool{
+	"analysis.failures": true,
+	"safety.violations": true,
+}
+
+// baselineFile is a run's metrics together with the provider, model and
+// prompt that produced them.
+type baselineFile struct {
+	Provider string             `json:"provider"`
+	Model    string             `json:"model"`
+	Prompt   string             `json:"prompt"`
+	Metrics  map[string]float64 `json:"metrics"`
+}
+
+func loadBaseline(path string) (*baselineFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var b baselineFile
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	return &b, nil
+}
+
+func saveBaseline(path string, b *baselineFile) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// checkBaseline returns an error when the baseline was written by a run
+// this one can't be compared with: another model, or the fake provider on
+// one side only. Other differences, such as the prompt version being
+// evaluated, are returned as warnings.
+func checkBaseline(baseline, current *baselineFile) ([]string, error) {
+	if baseline.Provider == "" && baseline.Model == "" && baseline.Prompt == "" {
+		return []string{"the baseline doesn't record the provider, model or prompt it was written with"}, nil
+	}
+	if (baseline.Provider == "fake") != (current.Provider == "fake") {
+		return nil, fmt.Errorf("baseline was written with provider %s, this run uses %s", baseline.Provider, current.Provider)
+	}
+	if baseline.Model != current.Model {
+		return nil, fmt.Errorf("baseline was written with model %s, this run uses %s", baseline.Model, current.Model)
+	}
+
+	var warnings []string
+	if baseline.Provider != current.Provider {
+		warnings = append(warnings, fmt.Sprintf("baseline was written with provider %s, this run uses %s", baseline.Provider, current.Provider))
+	}
+	if baseline.Prompt != current.Prompt {
+		warnings = append(warnings, fmt.Sprintf("baseline was written with prompt %s, this run uses %s", baseline.Prompt, current.Prompt))
+	}
+	return warnings, nil
+}
+
+// gated reports whether a regression of the metric fails the run. The fake
+// provider's replies say nothing about model quality, so its model metrics
+// are only reported.
+func gated(name, provider string) bool {
+	return provider != "fake" || !strings.Contains(name, ".model.")
+}
+
+// printComparison lists each metric against its baseline and returns how
+// many regressed. Metrics missing from the baseline, and those the provider
+// doesn't gate on, are reported but never count as regressions.
+func printComparison(w io.Writer, baseline, current *baselineFile, tolerance float64) int {
+	names := make([]string, 0, len(current.Metrics))
+	for name := range current.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	heading(w, "Against baseline")
+	regressions := 0
+	for _, name := range names {
+		value := current.Metrics[name]
+		base, ok := baseline.Metrics[name]
+		if !ok {
+			fmt.Fprintf(w, "  %-28s %8.4f  (new)\n", name, value)
+			continue
+		}
+
+		regressed := value < base-tolerance
+		if countMetrics[name] {
+			regressed = value > base
+		}
+		status := ""
+		switch {
+		case regressed && !gated(name, current.Provider):
+			status = "  (not gated)"
+		case regressed:
+			status = "  REGRESSION"
+			regressions++
+		}
+		fmt.Fprintf(w, "  %-28s %8.4f  baseline %8.4f%s\n", name, value, base, status)
+	}
+	return regressions
+}
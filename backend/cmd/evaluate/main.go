@@ -0,0 +1,415 @@
+// Command evaluate runs a labelled corpus of journal entries through the
+// analysis pipeline and scores the results against the labels: sentiment
+// accuracy, emotion F1 and crisis screening, with confusion matrices, plus
+// any model replies that break the output safety rules. It exits non-zero
+// when a metric regresses against the stored baseline, which records the
+// provider, model and prompt it was written with and is only compared with
+// runs of the same model. The fake provider's model metrics are reported
+// but never fail a run.
+//
+// The deterministic fake provider is used by default, so runs need no
+// network. A live run can be recorded and later replayed offline:
+//
+//	go run ./cmd/evaluate
+//	go run ./cmd/evaluate -provider live -recording cmd/evaluate/recording.json
+//	go run ./cmd/evaluate -provider replay -recording cmd/evaluate/recording.json
+//	go run ./cmd/evaluate -write-baseline
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"go_health_sentiment/config"
+	"go_health_sentiment/langdetect"
+	"go_health_sentiment/models"
+	"go_health_sentiment/prompts"
+	"go_health_sentiment/safety"
+	"go_health_sentiment/sentiment"
+	"go_health_sentiment/services"
+)
+
+var sentimentLabels = []string{"positive", "neutral", "negative"}
+
+// corpusEntry is one labelled journal entry. Language is detected when
+// empty; Risk is the expected safety level of the entry.
+type corpusEntry struct {
+	ID        string   `json:"id"`
+	Content   string   `json:"content"`
+	Language  string   `json:"language,omitempty"`
+	Sentiment string   `json:"sentiment"`
+	Emotions  []string `json:"emotions"`
+	Risk      string   `json:"risk"`
+}
+
+func main() {
+	corpusPath := flag.String("corpus", "cmd/evaluate/corpus.jsonl", "labelled entries, one JSON object per line")
+	baselinePath := flag.String("baseline", "cmd/evaluate/baseline.json", "stored metrics to compare against")
+	providerName := flag.String("provider", "fake", "model provider: fake, live (as configured by LLM_PROVIDER) or replay")
+	recordingPath := flag.String("recording", "", "file to record live replies to, or to replay them from")
+	promptVersion := flag.String("prompt-version", "", "analysis prompt version to evaluate (default PROMPT_VERSION)")
+	emotionThreshold := flag.Float64("emotion-threshold", 0.3, "lowest intensity counted as an emotion being present")
+	tolerance := flag.Float64("tolerance", 0.01, "largest drop in a score that isn't a regression")
+	writeBaseline := flag.Bool("write-baseline", false, "store this run's metrics as the new baseline")
+	verbose := flag.Bool("v", false, "list every entry that was scored wrongly")
+	flag.Parse()
+
+	cfg := config.LoadConfig()
+	if *promptVersion == "" {
+		*promptVersion = cfg.LLM.PromptVersion
+	}
+
+	corpus, err := loadCorpus(*corpusPath)
+	if err != nil {
+		log.Fatal("Failed to load corpus:", err)
+	}
+
+	provider, rec, err := newProvider(*providerName, *recordingPath, cfg.LLM)
+	if err != nil {
+		log.Fatal("Failed to configure LLM provider:", err)
+	}
+
+	registry, err := prompts.LoadEmbedded()
+	if err != nil {
+		log.Fatal("Failed to load prompt templates:", err)
+	}
+	if err := registry.SetDefault(prompts.Analysis, *promptVersion); err != nil {
+		log.Fatal("Invalid prompt version:", err)
+	}
+
+	chat := services.NewChatConversation(nil, provider, registry, services.ChatOptions{
+		Params: services.GenerationParams{
+			MaxTokens:   cfg.LLM.MaxTokens,
+			Temperature: cfg.LLM.Temperature,
+			TopP:        cfg.LLM.TopP,
+		},
+		RepairAttempts: cfg.LLM.RepairAttempts,
+	})
+	e := &evaluation{
+		chat:             chat,
+		inputSafety:      safety.NewInputCheckers(cfg.Safety.Threshold),
+		outputSafety:     safety.NewOutputCheckers(cfg.Safety.Threshold),
+		emotionThreshold: *emotionThreshold,
+		verbose:          *verbose,
+
+		lexiconSentiment: newConfusion(sentimentLabels...),
+		modelSentiment:   newConfusion(sentimentLabels...),
+		lexiconEmotions:  newMultiLabel(sentiment.EmotionLabels...),
+		modelEmotions:    newMultiLabel(sentiment.EmotionLabels...),
+		risk:             newConfusion(safety.LevelNone, safety.LevelModerate, safety.LevelHigh),
+		flagging:         newConfusion("flagged", "not_flagged"),
+	}
+
+	fmt.Printf("Evaluating %d entries with %s (model %s, prompt %s@%s)\n",
+		len(corpus), provider.Name(), provider.Model(), prompts.Analysis, *promptVersion)
+	if *verbose {
+		heading(os.Stdout, "Mismatches")
+	}
+	for i := range corpus {
+		e.run(context.Background(), &corpus[i])
+	}
+	e.report()
+
+	if rec != nil {
+		if err := rec.Save(*recordingPath); err != nil {
+			log.Fatal("Failed to save recording:", err)
+		}
+		fmt.Printf("\nRecorded %d replies to %s\n", len(rec.Replies), *recordingPath)
+	}
+
+	current := &baselineFile{
+		Provider: provider.Name(),
+		Model:    provider.Model(),
+		Prompt:   prompts.Analysis + "@" + *promptVersion,
+		Metrics:  e.metrics(),
+	}
+	if *writeBaseline {
+		if err := saveBaseline(*baselinePath, current); err != nil {
+			log.Fatal("Failed to write baseline:", err)
+		}
+		fmt.Printf("\nWrote baseline to %s\n", *baselinePath)
+		return
+	}
+
+	baseline, err := loadBaseline(*baselinePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("\nNo baseline at %s; run with -write-baseline to create one\n", *baselinePath)
+			return
+		}
+		log.Fatal("Failed to load baseline:", err)
+	}
+	warnings, err := checkBaseline(baseline, current)
+	if err != nil {
+		log.Fatalf("Baseline %s doesn't match this run: %v; pass -baseline for a matching one or -write-baseline to replace it", *baselinePath, err)
+	}
+	for _, warning := range warnings {
+		fmt.Printf("\nWarning: %s\n", warning)
+	}
+	if regressions := printComparison(os.Stdout, baseline, current, *tolerance); regressions > 0 {
+		fmt.Printf("\n%d metrics regressed against the baseline\n", regressions)
+		os.Exit(1)
+	}
+	fmt.Println("\nNo regressions against the baseline")
+}
+
+// newProvider returns the provider to evaluate and, when live replies are
+// being recorded, the recording to save afterwards.
+func newProvider(name, recordingPath string, cfg config.LLMConfig) (services.Provider, *services.Recording, error) {
+	switch name {
+	case "fake":
+		return services.NewFakeProvider(""), nil, nil
+	case "live":
+		base, err := services.NewProvider(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		var p services.Provider = services.NewResilientProvider(base,
+			services.RetryOptions{MaxAttempts: cfg.RetryAttempts, Base: cfg.RetryBase, Max: cfg.RetryMax},
+			services.NewCircuitBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+		)
+		if recordingPath == "" {
+			return p, nil, nil
+		}
+		rec := &services.Recording{}
+		return services.NewRecordingProvider(p, rec), rec, nil
+	case "replay":
+		if recordingPath == "" {
+			return nil, nil, fmt.Errorf("-recording is required to replay")
+		}
+		rec, err := services.LoadRecording(recordingPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return services.NewReplayProvider(rec), nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown provider %q", name)
+	}
+}
+
+func loadCorpus(path string) ([]corpusEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var corpus []corpusEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var entry corpusEntry
+		if err := json.Unmarshal([]byte(text), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if err := entry.validate(); err != nil {
+			return nil, fmt.Errorf("line %d (%s): %v", line, entry.ID, err)
+		}
+		corpus = append(corpus, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(corpus) == 0 {
+		return nil, fmt.Errorf("%s has no entries", path)
+	}
+	return corpus, nil
+}
+
+func (e *corpusEntry) validate() error {
+	if e.ID == "" || strings.TrimSpace(e.Content) == "" {
+		return fmt.Errorf("id and content are required")
+	}
+	if !slices.Contains(sentimentLabels, e.Sentiment) {
+		return fmt.Errorf("sentiment must be one of: %s", strings.Join(sentimentLabels, ", "))
+	}
+	for _, emotion := range e.Emotions {
+		if !sentiment.IsEmotion(emotion) {
+			return fmt.Errorf("unknown emotion %q", emotion)
+		}
+	}
+	if e.Risk == "" {
+		e.Risk = safety.LevelNone
+	}
+	if !safety.IsLevel(e.Risk) {
+		return fmt.Errorf("unknown risk level %q", e.Risk)
+	}
+	return nil
+}
+
+// evaluation accumulates the scores of one run.
+type evaluation struct {
+	chat             *services.ChatConversation
+	inputSafety      *safety.LanguageCheckers
+	outputSafety     *safety.LanguageCheckers
+	emotionThreshold float64
+	verbose          bool
+
+	lexiconSentiment *confusion
+	modelSentiment   *confusion
+	lexiconEmotions  *multiLabel
+	modelEmotions    *multiLabel
+	risk             *confusion
+	flagging         *confusion
+
+	analyzed   int
+	failures   []string
+	violations []string
+}
+
+// run scores one entry the way the server would handle it. Entries the
+// input rules flag get crisis resources instead of an analysis, so they
+// aren't sent to the model.
+func (e *evaluation) run(ctx context.Context, entry *corpusEntry) {
+	language := entry.Language
+	if language == "" {
+		language = langdetect.Detect(entry.Content).Language
+	}
+
+	lexicon := sentiment.ForText(language, entry.Content).Analyze(entry.Content).Label()
+	e.lexiconSentiment.add(entry.Sentiment, lexicon)
+	e.mismatch(entry, "lexicon sentiment", entry.Sentiment, lexicon)
+
+	lexiconEmotions := e.present(services.LexiconEmotions(entry.Content, language))
+	e.lexiconEmotions.add(entry.Emotions, lexiconEmotions)
+	e.mismatch(entry, "lexicon emotions", strings.Join(entry.Emotions, ","), strings.Join(lexiconEmotions, ","))
+
+	checker := e.inputSafety.ForLanguage(language)
+	assessment := checker.Check(entry.Content)
+	e.risk.add(entry.Risk, assessment.Level)
+	e.flagging.add(flagLabel(checker.Flags(entry.Risk)), flagLabel(assessment.Flagged))
+	e.mismatch(entry, "risk", entry.Risk, assessment.Level)
+	if assessment.Flagged {
+		return
+	}
+
+	analysis, err := e.chat.AnalyzeText(ctx, entry.Content, language)
+	if err != nil {
+		e.failures = append(e.failures, fmt.Sprintf("%s: %v", entry.ID, err))
+		return
+	}
+	e.analyzed++
+	result := analysis.Result
+
+	model := sentiment.Scores{Compound: result.SentimentScore}.Label()
+	e.modelSentiment.add(entry.Sentiment, model)
+	e.mismatch(entry, "model sentiment", entry.Sentiment, model)
+
+	modelEmotions := e.present(services.RefineEmotions(entry.Content, language, result.PrimaryEmotions))
+	e.modelEmotions.add(entry.Emotions, modelEmotions)
+	e.mismatch(entry, "model emotions", strings.Join(entry.Emotions, ","), strings.Join(modelEmotions, ","))
+
+	reply := result.SupportiveMessage + "\n" + strings.Join(result.ReflectionSuggestions, "\n")
+	if out := e.outputSafety.ForLanguage(language).Check(reply); out.Level != safety.LevelNone {
+		e.violations = append(e.violations, fmt.Sprintf("%s: %s (%s)", entry.ID, out.Level, strings.Join(out.Reasons, ", ")))
+	}
+}
+
+// present returns the emotions scored at or above the threshold, in
+// canonical order so mismatches read consistently.
+func (e *evaluation) present(emotions []models.EntryEmotion) []string {
+	found := make(map[string]bool, len(emotions))
+	for _, em := range emotions {
+		if em.Intensity >= e.emotionThreshold {
+			found[em.Emotion] = true
+		}
+	}
+	var labels []string
+	for _, l := range sentiment.EmotionLabels {
+		if found[l] {
+			labels = append(labels, l)
+		}
+	}
+	return labels
+}
+
+func (e *evaluation) mismatch(entry *corpusEntry, what, expected, got string) {
+	if e.verbose && !sameLabels(expected, got) {
+		fmt.Printf("  %s: %s expected %q, got %q\n", entry.ID, what, expected, got)
+	}
+}
+
+func (e *evaluation) report() {
+	w := os.Stdout
+
+	heading(w, "Sentiment (lexicon)")
+	e.lexiconSentiment.print(w)
+	heading(w, "Sentiment (model)")
+	e.modelSentiment.print(w)
+
+	heading(w, "Emotions (lexicon)")
+	e.lexiconEmotions.print(w)
+	heading(w, "Emotions (model, refined)")
+	e.modelEmotions.print(w)
+
+	heading(w, "Risk level (input rules)")
+	e.risk.print(w)
+	heading(w, "Crisis flagging (input rules)")
+	e.flagging.print(w)
+
+	heading(w, "Analysis")
+	fmt.Fprintf(w, "  %d analyzed, %d failed\n", e.analyzed, len(e.failures))
+	for _, f := range e.failures {
+		fmt.Fprintf(w, "  failed %s\n", f)
+	}
+
+	heading(w, "Safety rule violations (output rules)")
+	if len(e.violations) == 0 {
+		fmt.Fprintln(w, "  none")
+	}
+	for _, v := range e.violations {
+		fmt.Fprintf(w, "  %s\n", v)
+	}
+}
+
+// metrics flattens the run into the scores compared against the baseline.
+func (e *evaluation) metrics() map[string]float64 {
+	_, flaggedRecall, _ := e.flagging.scores("flagged")
+	return map[string]float64{
+		"sentiment.lexicon.accuracy": e.lexiconSentiment.accuracy(),
+		"sentiment.lexicon.macro_f1": e.lexiconSentiment.macroF1(),
+		"sentiment.model.accuracy":   e.modelSentiment.accuracy(),
+		"sentiment.model.macro_f1":   e.modelSentiment.macroF1(),
+		"emotions.lexicon.micro_f1":  e.lexiconEmotions.microF1(),
+		"emotions.model.micro_f1":    e.modelEmotions.microF1(),
+		"risk.accuracy":              e.risk.accuracy(),
+		"risk.macro_f1":              e.risk.macroF1(),
+		"risk.flagged_recall":        flaggedRecall,
+		"analysis.failures":          float64(len(e.failures)),
+		"safety.violations":          float64(len(e.violations)),
+	}
+}
+
+func flagLabel(flagged bool) string {
+	if flagged {
+		return "flagged"
+	}
+	return "not_flagged"
+}
+
+// sameLabels compares comma-separated label lists regardless of order.
+func sameLabels(a, b string) bool {
+	as, bs := strings.Split(a, ","), strings.Split(b, ",")
+	if len(as) != len(bs) {
+		return false
+	}
+	for _, l := range as {
+		if !slices.Contains(bs, l) {
+			return false
+		}
+	}
+	return true
+}
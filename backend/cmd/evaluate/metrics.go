@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// confusion counts single-label predictions against the expected labels.
+type confusion struct {
+	labels []string
+	counts map[string]map[string]int // expected -> predicted -> count
+	total  int
+}
+
+func newConfusion(labels ...string) *confusion {
+	c := &confusion{labels: labels, counts: make(map[string]map[string]int, len(labels))}
+	for _, l := range labels {
+		c.counts[l] = make(map[string]int, len(labels))
+	}
+	return c
+}
+
+func (c *confusion) add(expected, predicted string) {
+	if c.counts[expected] == nil {
+		c.labels = append(c.labels, expected)
+		c.counts[expected] = make(map[string]int)
+	}
+	c.counts[expected][predicted]++
+	c.total++
+}
+
+func (c *confusion) accuracy() float64 {
+	correct := 0
+	for _, l := range c.labels {
+		correct += c.counts[l][l]
+	}
+	return ratio(correct, c.total)
+}
+
+// scores returns the precision, recall and F1 of one label.
+func (c *confusion) scores(label string) (precision, recall, f1 float64) {
+	tp, fp, fn := 0, 0, 0
+	for _, expected := range c.labels {
+		for predicted, n := range c.counts[expected] {
+			switch {
+			case expected == label && predicted == label:
+				tp += n
+			case predicted == label:
+				fp += n
+			case expected == label:
+				fn += n
+			}
+		}
+	}
+	return prf(tp, fp, fn)
+}
+
+// macroF1 averages the F1 of every label.
+func (c *confusion) macroF1() float64 {
+	if len(c.labels) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, l := range c.labels {
+		_, _, f1 := c.scores(l)
+		sum += f1
+	}
+	return round4(sum / float64(len(c.labels)))
+}
+
+func (c *confusion) print(w io.Writer) {
+	width, col := len("expected \\ predicted"), 10
+	for _, l := range c.labels {
+		width, col = max(width, len(l)), max(col, len(l))
+	}
+
+	fmt.Fprintf(w, "  %-*s", width, "expected \\ predicted")
+	for _, l := range c.labels {
+		fmt.Fprintf(w, " %*s", col, l)
+	}
+	fmt.Fprintln(w)
+	for _, expected := range c.labels {
+		fmt.Fprintf(w, "  %-*s", width, expected)
+		for _, predicted := range c.labels {
+			fmt.Fprintf(w, " %*d", col, c.counts[expected][predicted])
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "\n  %-*s %10s %10s %10s\n", width, "label", "precision", "recall", "f1")
+	for _, l := range c.labels {
+		p, r, f1 := c.scores(l)
+		fmt.Fprintf(w, "  %-*s %10.3f %10.3f %10.3f\n", width, l, p, r, f1)
+	}
+	fmt.Fprintf(w, "  accuracy %.3f, macro F1 %.3f over %d entries\n", c.accuracy(), c.macroF1(), c.total)
+}
+
+// multiLabel scores predictions where each entry may carry several labels,
+// such as the emotions present in it.
+type multiLabel struct {
+	labels []string
+	tp     map[string]int
+	fp     map[string]int
+	fn     map[string]int
+}
+
+func newMultiLabel(labels ...string) *multiLabel {
+	return &multiLabel{labels: labels, tp: map[string]int{}, fp: map[string]int{}, fn: map[string]int{}}
+}
+
+func (m *multiLabel) add(expected, predicted []string) {
+	want := make(map[string]bool, len(expected))
+	for _, l := range expected {
+		want[l] = true
+	}
+	got := make(map[string]bool, len(predicted))
+	for _, l := range predicted {
+		got[l] = true
+		if want[l] {
+			m.tp[l]++
+		} else {
+			m.fp[l]++
+		}
+	}
+	for l := range want {
+		if !got[l] {
+			m.fn[l]++
+		}
+	}
+}
+
+// microF1 pools the counts of every label.
+func (m *multiLabel) microF1() float64 {
+	tp, fp, fn := 0, 0, 0
+	for _, l := range m.labels {
+		tp, fp, fn = tp+m.tp[l], fp+m.fp[l], fn+m.fn[l]
+	}
+	_, _, f1 := prf(tp, fp, fn)
+	return f1
+}
+
+func (m *multiLabel) print(w io.Writer) {
+	fmt.Fprintf(w, "  %-14s %6s %6s %6s %10s %10s %10s\n", "label", "tp", "fp", "fn", "precision", "recall", "f1")
+	for _, l := range m.labels {
+		p, r, f1 := prf(m.tp[l], m.fp[l], m.fn[l])
+		fmt.Fprintf(w, "  %-14s %6d %6d %6d %10.3f %10.3f %10.3f\n", l, m.tp[l], m.fp[l], m.fn[l], p, r, f1)
+	}
+	fmt.Fprintf(w, "  micro F1 %.3f\n", m.microF1())
+}
+
+func prf(tp, fp, fn int) (precision, recall, f1 float64) {
+	precision, recall = ratio(tp, tp+fp), ratio(tp, tp+fn)
+	if precision+recall > 0 {
+		f1 = round4(2 * precision * recall / (precision + recall))
+	}
+	return precision, recall, f1
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return round4(float64(n) / float64(d))
+}
+
+func round4(f float64) float64 {
+	return math.Round(f*10000) / 10000
+}
+
+func heading(w io.Writer, title string) {
+	fmt.Fprintf(w, "\n%s\n%s\n", title, strings.Repeat("-", len(title)))
+}
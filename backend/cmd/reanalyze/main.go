@@ -0,0 +1,90 @@
+// Command reanalyze queues journal entries for a fresh analysis, e.g. after
+// a model or prompt upgrade. Jobs are picked up by the server's analysis
+// workers; each completed run is added to the entry's analysis history.
+//
+//	go run ./cmd/reanalyze -stale
+//	go run ./cmd/reanalyze -user 42 -since 2024-01-01 -dry-run
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"go_health_sentiment/config"
+	"go_health_sentiment/db"
+	"go_health_sentiment/models"
+	"go_health_sentiment/prompts"
+	"go_health_sentiment/services"
+)
+
+func main() {
+	userID := flag.Int("user", 0, "only re-analyze this user's entries")
+	since := flag.String("since", "", "only re-analyze entries written on or after this date (YYYY-MM-DD)")
+	stale := flag.Bool("stale", false, "only re-analyze entries not analyzed with the configured model and prompt version")
+	all := flag.Bool("all", false, "re-analyze every matching entry; required when no other filter is given")
+	limit := flag.Int("limit", 1000, "maximum number of entries to queue")
+	dryRun := flag.Bool("dry-run", false, "list matching entries without queueing them")
+	useCache := flag.Bool("use-cache", false, "reuse cached analyses made with the same model and prompt instead of replacing them")
+	flag.Parse()
+
+	cfg := config.LoadConfig()
+
+	filter := models.ReanalysisFilter{UserID: *userID, Limit: *limit}
+	if *since != "" {
+		t, err := time.Parse("2006-01-02", *since)
+		if err != nil {
+			log.Fatalf("Invalid -since date %q: %v", *since, err)
+		}
+		filter.Since = t
+	}
+	if *stale {
+		provider, err := services.NewProvider(cfg.LLM)
+		if err != nil {
+			log.Fatal("Failed to configure LLM provider:", err)
+		}
+		filter.StalePromptVersion = prompts.Analysis + "@" + cfg.LLM.PromptVersion
+		filter.StaleModel = provider.Model()
+	}
+	if filter.UserID == 0 && filter.Since.IsZero() && !*stale && !*all {
+		log.Fatal("Refusing to re-analyze every entry without -all")
+	}
+
+	database, err := db.NewConnection(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	entries, err := models.FindEntriesForReanalysis(ctx, database.DB, filter)
+	if err != nil {
+		log.Fatal("Failed to find entries:", err)
+	}
+
+	queued, skipped := 0, 0
+	for i := range entries {
+		entry := &entries[i]
+		if *dryRun {
+			log.Printf("Would re-analyze entry %d (user %d, prompt %s, model %s)",
+				entry.ID, entry.UserID, entry.PromptVersion, entry.Model)
+			continue
+		}
+
+		if err := models.QueueReanalysis(ctx, database.DB, entry, cfg.Analysis.MaxAttempts, !*useCache); err != nil {
+			if err == models.ErrAnalysisQueued {
+				skipped++
+				continue
+			}
+			log.Fatalf("Failed to queue entry %d: %v", entry.ID, err)
+		}
+		queued++
+	}
+
+	if *dryRun {
+		log.Printf("%d entries match", len(entries))
+		return
+	}
+	log.Printf("Queued %d entries for re-analysis (%d already queued)", queued, skipped)
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -13,6 +15,164 @@ type Config struct {
 	OpenAIAPIKey   string
 	ServerPort     string
 	AllowedOrigins []string
+	LLM            LLMConfig
+	Analysis       AnalysisConfig
+	Safety         SafetyConfig
+	Usage          UsageConfig
+	Redaction      RedactionConfig
+	Embedding      EmbeddingConfig
+	Digest         DigestConfig
+	Timeouts       TimeoutConfig
+	Auth           AuthConfig
+
+	ShutdownTimeout time.Duration
+}
+
+// LLMConfig selects the model provider used for journal analysis and the
+// generation parameters sent with every request.
+type LLMConfig struct {
+	Provider    string
+	Model       string
+	BaseURL     string
+	APIKey      string
+	MaxTokens   int
+	Temperature float64
+	TopP        float64
+	Timeout     time.Duration
+
+	// HistoryLimit is the number of messages kept per user conversation
+	HistoryLimit int
+
+	// ChatWindow is the number of recent messages sent with each chat reply
+	// about an entry; older messages are summarized
+	ChatWindow int
+
+	// PromptVersion is the deployment default version of the analysis prompt
+	PromptVersion string
+
+	// RepairAttempts is how many times a malformed JSON analysis is sent
+	// back to the model for correction before the attempt fails
+	RepairAttempts int
+
+	// RetryAttempts is the number of attempts per upstream call, including
+	// the first, when the API is rate limited, overloaded or unreachable
+	RetryAttempts int
+	RetryBase     time.Duration
+	RetryMax      time.Duration
+
+	// The circuit breaker opens after BreakerThreshold consecutive failed
+	// calls and stays open for BreakerCooldown before a trial call
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// AnalysisConfig controls the background worker pool that runs analysis jobs.
+type AnalysisConfig struct {
+	Workers      int
+	MaxAttempts  int
+	PollInterval time.Duration
+	RetryBase    time.Duration
+	RetryMax     time.Duration
+	StaleAfter   time.Duration
+
+	// Cache is the analysis cache backend: "memory", "postgres" or "off"
+	Cache     string
+	CacheSize int
+	CacheTTL  time.Duration
+
+	// ContextTokens budgets the notes on earlier entries sent with each
+	// analysis; 0 sends recent conversation turns instead. ContextRecent
+	// and ContextSimilar are how many latest and most similar entries are
+	// considered, and ContextLookback how far back recent ones reach.
+	ContextTokens   int
+	ContextRecent   int
+	ContextSimilar  int
+	ContextLookback time.Duration
+
+	// RefineEmotions blends the emotions the model names into each entry's
+	// lexicon emotion scores
+	RefineEmotions bool
+}
+
+// SafetyConfig controls crisis screening and the resources shown when an
+// entry is flagged.
+type SafetyConfig struct {
+	// Threshold is the lowest risk level ("moderate" or "high") that flags
+	Threshold     string
+	ResourcesFile string
+	DefaultLocale string
+}
+
+// RedactionConfig controls PII redaction of text sent to the model. Mode is
+// "on" (redact unless the user opts out), "opt-in" or "off" (never redact).
+// NamesFile and PlacesFile add terms, one per line, to the built-in
+// dictionaries.
+type RedactionConfig struct {
+	Mode       string
+	NamesFile  string
+	PlacesFile string
+}
+
+// EmbeddingConfig selects how entries are embedded for semantic search.
+// Provider is "hashing" (offline, the default), "openai", "llamacpp",
+// "ollama" or "huggingface"; Dimensions only applies to hashing.
+type EmbeddingConfig struct {
+	Provider   string
+	Model      string
+	BaseURL    string
+	APIKey     string
+	Dimensions int
+	Timeout    time.Duration
+}
+
+// DigestConfig controls the reflection digests. Periods is a comma-separated
+// list of "weekly" and "monthly"; empty or "off" disables digests.
+type DigestConfig struct {
+	Periods       string
+	CheckInterval time.Duration
+	MinEntries    int
+	MaxAttempts   int
+}
+
+// TimeoutConfig sets the deadline of each kind of operation. Request covers
+// handlers that only touch the database; the others bound work that calls
+// the model or the embedder. Zero disables a deadline.
+type TimeoutConfig struct {
+	Request time.Duration
+	Search  time.Duration
+
+	// Analysis bounds one analysis job, including retries of malformed
+	// output; Chat one chat reply or style preview; Digest one digest
+	Analysis time.Duration
+	Chat     time.Duration
+	Digest   time.Duration
+}
+
+// AuthConfig controls token lifetimes. Access tokens are short-lived JWTs;
+// refresh tokens renew them and are rotated on every use. RefreshCookie
+// delivers refresh tokens in an httpOnly cookie instead of the response
+// body, and CookieSecure restricts that cookie to HTTPS.
+type AuthConfig struct {
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	RefreshCookie   bool
+	CookieSecure    bool
+
+	// Revoked tokens are mirrored in memory: RevocationSync is how often
+	// other instances' revocations are loaded, RevocationCleanup how often
+	// expired ones are deleted, and RevocationCacheSize how many token
+	// lookups are cached
+	RevocationSync      time.Duration
+	RevocationCleanup   time.Duration
+	RevocationCacheSize int
+}
+
+// UsageConfig sets the analysis quotas of each plan. Plans is a list such
+// as "free=daily_calls:20,monthly_tokens:200000;pro=daily_calls:200"; limits
+// left out are unlimited, as are users on a plan that isn't listed.
+type UsageConfig struct {
+	Plans       string
+	DefaultPlan string
 }
 
 func LoadConfig() *Config {
@@ -29,10 +189,98 @@ func LoadConfig() *Config {
 		AllowedOrigins: []string{
 			getEnv("FRONTEND_URL", "http://localhost:8081"),
 		},
+		LLM: LLMConfig{
+			Provider:    getEnv("LLM_PROVIDER", "huggingface"),
+			Model:       getEnv("LLM_MODEL", "meta-llama/Llama-3.2-1B-Instruct"),
+			BaseURL:     getEnv("LLM_BASE_URL", ""),
+			MaxTokens:   getEnvInt("LLM_MAX_TOKENS", 512),
+			Temperature: getEnvFloat("LLM_TEMPERATURE", 0.7),
+			TopP:        getEnvFloat("LLM_TOP_P", 0.9),
+			Timeout:     getEnvDuration("LLM_TIMEOUT", 30*time.Second),
+
+			HistoryLimit:   getEnvInt("CONVERSATION_HISTORY_LIMIT", 10),
+			ChatWindow:     getEnvInt("CHAT_CONTEXT_MESSAGES", 12),
+			PromptVersion:  getEnv("PROMPT_VERSION", "v3"),
+			RepairAttempts: getEnvInt("LLM_REPAIR_ATTEMPTS", 1),
+
+			RetryAttempts:    getEnvInt("LLM_RETRY_ATTEMPTS", 3),
+			RetryBase:        getEnvDuration("LLM_RETRY_BASE", 500*time.Millisecond),
+			RetryMax:         getEnvDuration("LLM_RETRY_MAX", 30*time.Second),
+			BreakerThreshold: getEnvInt("LLM_BREAKER_THRESHOLD", 5),
+			BreakerCooldown:  getEnvDuration("LLM_BREAKER_COOLDOWN", 30*time.Second),
+		},
+		Analysis: AnalysisConfig{
+			Workers:      getEnvInt("ANALYSIS_WORKERS", 4),
+			MaxAttempts:  getEnvInt("ANALYSIS_MAX_ATTEMPTS", 5),
+			PollInterval: getEnvDuration("ANALYSIS_POLL_INTERVAL", 2*time.Second),
+			RetryBase:    getEnvDuration("ANALYSIS_RETRY_BASE", 5*time.Second),
+			RetryMax:     getEnvDuration("ANALYSIS_RETRY_MAX", 5*time.Minute),
+			StaleAfter:   getEnvDuration("ANALYSIS_STALE_AFTER", 5*time.Minute),
+
+			Cache:     getEnv("ANALYSIS_CACHE", "memory"),
+			CacheSize: getEnvInt("ANALYSIS_CACHE_SIZE", 1000),
+			CacheTTL:  getEnvDuration("ANALYSIS_CACHE_TTL", 24*time.Hour),
+
+			ContextTokens:   getEnvInt("ANALYSIS_CONTEXT_TOKENS", 600),
+			ContextRecent:   getEnvInt("ANALYSIS_CONTEXT_RECENT", 5),
+			ContextSimilar:  getEnvInt("ANALYSIS_CONTEXT_SIMILAR", 3),
+			ContextLookback: getEnvDuration("ANALYSIS_CONTEXT_LOOKBACK", 30*24*time.Hour),
+
+			RefineEmotions: getEnv("EMOTION_REFINEMENT", "on") != "off",
+		},
+		Safety: SafetyConfig{
+			Threshold:     getEnv("SAFETY_THRESHOLD", "high"),
+			ResourcesFile: getEnv("CRISIS_RESOURCES_FILE", ""),
+			DefaultLocale: getEnv("CRISIS_DEFAULT_LOCALE", "en-US"),
+		},
+		Usage: UsageConfig{
+			Plans:       getEnv("USAGE_PLANS", ""),
+			DefaultPlan: getEnv("USAGE_DEFAULT_PLAN", "free"),
+		},
+		Embedding: EmbeddingConfig{
+			Provider:   getEnv("EMBEDDING_PROVIDER", "hashing"),
+			Model:      getEnv("EMBEDDING_MODEL", ""),
+			BaseURL:    getEnv("EMBEDDING_BASE_URL", ""),
+			Dimensions: getEnvInt("EMBEDDING_DIMENSIONS", 512),
+			Timeout:    getEnvDuration("EMBEDDING_TIMEOUT", 15*time.Second),
+		},
+		Digest: DigestConfig{
+			Periods:       getEnv("DIGEST_PERIODS", "weekly,monthly"),
+			CheckInterval: getEnvDuration("DIGEST_CHECK_INTERVAL", time.Hour),
+			MinEntries:    getEnvInt("DIGEST_MIN_ENTRIES", 2),
+			MaxAttempts:   getEnvInt("DIGEST_MAX_ATTEMPTS", 3),
+		},
+		Timeouts: TimeoutConfig{
+			Request:  getEnvDuration("REQUEST_TIMEOUT", 15*time.Second),
+			Search:   getEnvDuration("SEARCH_TIMEOUT", 10*time.Second),
+			Analysis: getEnvDuration("ANALYSIS_TIMEOUT", 2*time.Minute),
+			Chat:     getEnvDuration("CHAT_TIMEOUT", 60*time.Second),
+			Digest:   getEnvDuration("DIGEST_TIMEOUT", 2*time.Minute),
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:  getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+			RefreshTokenTTL: getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+			RefreshCookie:   getEnv("REFRESH_TOKEN_COOKIE", "off") == "on",
+			CookieSecure:    getEnv("COOKIE_SECURE", "on") != "off",
+
+			RevocationSync:      getEnvDuration("REVOCATION_SYNC_INTERVAL", 30*time.Second),
+			RevocationCleanup:   getEnvDuration("REVOCATION_CLEANUP_INTERVAL", time.Hour),
+			RevocationCacheSize: getEnvInt("REVOCATION_CACHE_SIZE", 10000),
+		},
+		Redaction: RedactionConfig{
+			Mode:       getEnv("PII_REDACTION", "on"),
+			NamesFile:  getEnv("PII_NAMES_FILE", ""),
+			PlacesFile: getEnv("PII_PLACES_FILE", ""),
+		},
+		ShutdownTimeout: getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
 	}
 
-	if config.OpenAIAPIKey == "" {
-		log.Println("Warning: OPENAI_API_KEY not set")
+	// LLM_API_KEY takes precedence; OPENAI_API_KEY is kept for existing deployments
+	config.LLM.APIKey = getEnv("LLM_API_KEY", config.OpenAIAPIKey)
+	config.Embedding.APIKey = getEnv("EMBEDDING_API_KEY", config.LLM.APIKey)
+
+	if config.LLM.APIKey == "" && config.LLM.Provider != "ollama" && config.LLM.Provider != "fake" {
+		log.Println("Warning: LLM_API_KEY / OPENAI_API_KEY not set")
 	}
 
 	return config
@@ -43,4 +291,34 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+		log.Printf("Warning: invalid integer for %s, using default %d", key, defaultValue)
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+		log.Printf("Warning: invalid number for %s, using default %v", key, defaultValue)
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		log.Printf("Warning: invalid duration for %s, using default %v", key, defaultValue)
+	}
+	return defaultValue
+}
@@ -71,6 +71,303 @@ func (db *Database) initSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_journals_created_at ON journals(created_at);
 	`
 
+	// Create conversation memory tables
+	conversationsTable := `
+	CREATE TABLE IF NOT EXISTS conversations (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS messages (
+		id SERIAL PRIMARY KEY,
+		conversation_id INTEGER NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+		role VARCHAR(20) NOT NULL,
+		content TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_messages_conversation_id ON messages(conversation_id, id);
+	`
+
+	// Track asynchronous analysis on journals and queue the work
+	analysisJobsTable := `
+	ALTER TABLE journals ADD COLUMN IF NOT EXISTS analysis_status VARCHAR(20) DEFAULT 'done';
+	ALTER TABLE journals ALTER COLUMN analysis_status SET DEFAULT 'pending';
+
+	CREATE TABLE IF NOT EXISTS analysis_jobs (
+		id SERIAL PRIMARY KEY,
+		journal_id INTEGER NOT NULL REFERENCES journals(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		status VARCHAR(20) NOT NULL DEFAULT 'queued',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		max_attempts INTEGER NOT NULL DEFAULT 5,
+		run_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		locked_at TIMESTAMP,
+		last_error TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_analysis_jobs_ready ON analysis_jobs(status, run_at);
+	CREATE INDEX IF NOT EXISTS idx_analysis_jobs_journal_id ON analysis_jobs(journal_id);
+	`
+
+	// Store the structured analysis returned by the model
+	structuredAnalysisColumns := `
+	ALTER TABLE journals ADD COLUMN IF NOT EXISTS sentiment_score DOUBLE PRECISION;
+	ALTER TABLE journals ADD COLUMN IF NOT EXISTS structured_analysis JSONB;
+	`
+
+	// Offline lexicon sentiment scores computed from the entry text
+	sentimentColumns := `
+	ALTER TABLE journals ADD COLUMN IF NOT EXISTS sentiment_compound DOUBLE PRECISION;
+	ALTER TABLE journals ADD COLUMN IF NOT EXISTS sentiment_pos DOUBLE PRECISION;
+	ALTER TABLE journals ADD COLUMN IF NOT EXISTS sentiment_neg DOUBLE PRECISION;
+	ALTER TABLE journals ADD COLUMN IF NOT EXISTS sentiment_neu DOUBLE PRECISION;
+	`
+
+	// Safety screening results, kept on the entry for auditing
+	riskColumns := `
+	ALTER TABLE journals ADD COLUMN IF NOT EXISTS risk_flagged BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE journals ADD COLUMN IF NOT EXISTS risk_level VARCHAR(20);
+	ALTER TABLE journals ADD COLUMN IF NOT EXISTS risk_reasons TEXT[];
+	ALTER TABLE journals ADD COLUMN IF NOT EXISTS risk_source VARCHAR(20);
+
+	CREATE INDEX IF NOT EXISTS idx_journals_risk_flagged ON journals(risk_flagged) WHERE risk_flagged;
+	`
+
+	// Versioned prompt templates and the prompt/model that produced each analysis
+	promptTables := `
+	CREATE TABLE IF NOT EXISTS prompt_templates (
+		name VARCHAR(100) NOT NULL,
+		version VARCHAR(50) NOT NULL,
+		body TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (name, version)
+	);
+
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS prompt_version VARCHAR(50);
+	ALTER TABLE journals ADD COLUMN IF NOT EXISTS prompt_version VARCHAR(150);
+	ALTER TABLE journals ADD COLUMN IF NOT EXISTS model VARCHAR(255);
+	`
+
+	// Prompt-injection patterns detected in entry text
+	injectionColumns := `
+	ALTER TABLE journals ADD COLUMN IF NOT EXISTS injection_flags TEXT[];
+	`
+
+	// Cached analyses keyed on a hash of the user, normalized content, prompt and model
+	analysisCacheTable := `
+	CREATE TABLE IF NOT EXISTS analysis_cache (
+		cache_key VARCHAR(64) PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		result JSONB NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMP NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_analysis_cache_expires_at ON analysis_cache(expires_at);
+	`
+
+	// Conversations about a single journal entry, kept alongside the
+	// per-user analysis conversation, with a running summary of older turns
+	entryConversationColumns := `
+	ALTER TABLE conversations ADD COLUMN IF NOT EXISTS journal_id INTEGER REFERENCES journals(id) ON DELETE CASCADE;
+	ALTER TABLE conversations ADD COLUMN IF NOT EXISTS summary TEXT;
+	ALTER TABLE conversations ADD COLUMN IF NOT EXISTS summarized_through INTEGER NOT NULL DEFAULT 0;
+
+	DROP INDEX IF EXISTS idx_conversations_user_id;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_conversations_user_general ON conversations(user_id) WHERE journal_id IS NULL;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_conversations_journal_id ON conversations(journal_id) WHERE journal_id IS NOT NULL;
+	`
+
+	// Every completed analysis of an entry, with the entry pointing at its
+	// current one. Analyses from before the table existed are backfilled.
+	analysisHistoryTable := `
+	CREATE TABLE IF NOT EXISTS journal_analyses (
+		id SERIAL PRIMARY KEY,
+		journal_id INTEGER NOT NULL REFERENCES journals(id) ON DELETE CASCADE,
+		analysis TEXT,
+		structured_analysis JSONB,
+		sentiment_score DOUBLE PRECISION,
+		prompt_version VARCHAR(150),
+		model VARCHAR(255),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_journal_analyses_journal_id ON journal_analyses(journal_id, created_at DESC);
+
+	ALTER TABLE journals ADD COLUMN IF NOT EXISTS current_analysis_id INTEGER REFERENCES journal_analyses(id) ON DELETE SET NULL;
+
+	WITH backfilled AS (
+		INSERT INTO journal_analyses (journal_id, analysis, structured_analysis, sentiment_score, prompt_version, model, created_at)
+		SELECT id, analysis, structured_analysis, sentiment_score, prompt_version, model, updated_at
+		FROM journals
+		WHERE current_analysis_id IS NULL AND analysis_status = 'done' AND analysis IS NOT NULL AND analysis <> ''
+			AND risk_source IS DISTINCT FROM 'entry'
+		RETURNING id, journal_id
+	)
+	UPDATE journals SET current_analysis_id = backfilled.id
+	FROM backfilled WHERE journals.id = backfilled.journal_id;
+
+	-- At most one queued or running job per entry, so re-analysis can't pile up
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_analysis_jobs_active_journal ON analysis_jobs(journal_id) WHERE status IN ('queued', 'running');
+	`
+
+	// Per-call LLM usage, user plans and per-user quota overrides
+	usageTables := `
+	CREATE TABLE IF NOT EXISTS llm_usage (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		provider VARCHAR(50) NOT NULL,
+		model VARCHAR(255) NOT NULL,
+		prompt_version VARCHAR(150),
+		prompt_tokens INTEGER NOT NULL DEFAULT 0,
+		completion_tokens INTEGER NOT NULL DEFAULT 0,
+		estimated BOOLEAN NOT NULL DEFAULT FALSE,
+		latency_ms INTEGER NOT NULL DEFAULT 0,
+		outcome VARCHAR(20) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_llm_usage_user_created ON llm_usage(user_id, created_at);
+
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS plan VARCHAR(50);
+
+	CREATE TABLE IF NOT EXISTS user_quotas (
+		user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		daily_calls INTEGER,
+		monthly_calls INTEGER,
+		daily_tokens INTEGER,
+		monthly_tokens INTEGER,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	// Per-user PII redaction preference; NULL follows the deployment default
+	redactionColumns := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS redact_pii BOOLEAN;
+	`
+
+	// Entry vectors for semantic search, one per entry from the current embedder
+	embeddingsTable := `
+	CREATE TABLE IF NOT EXISTS journal_embeddings (
+		journal_id INTEGER PRIMARY KEY REFERENCES journals(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		model VARCHAR(255) NOT NULL,
+		dimensions INTEGER NOT NULL,
+		vector BYTEA NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_journal_embeddings_user_model ON journal_embeddings(user_id, model);
+	`
+
+	// Weekly and monthly reflection digests; one per user, period and start
+	digestsTable := `
+	CREATE TABLE IF NOT EXISTS digests (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		period VARCHAR(20) NOT NULL,
+		period_start DATE NOT NULL,
+		period_end DATE NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		content JSONB,
+		entry_count INTEGER NOT NULL DEFAULT 0,
+		average_sentiment DOUBLE PRECISION,
+		prompt_version VARCHAR(150),
+		model VARCHAR(255),
+		claimed_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (user_id, period, period_start)
+	);
+
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS digest_opt_out BOOLEAN NOT NULL DEFAULT FALSE;
+	`
+
+	// Emotion scores per entry, one row per Plutchik emotion expressed
+	entryEmotionsTable := `
+	CREATE TABLE IF NOT EXISTS entry_emotions (
+		journal_id INTEGER NOT NULL REFERENCES journals(id) ON DELETE CASCADE,
+		emotion VARCHAR(20) NOT NULL,
+		intensity DOUBLE PRECISION NOT NULL,
+		source VARCHAR(20) NOT NULL DEFAULT 'lexicon',
+		PRIMARY KEY (journal_id, emotion)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_entry_emotions_emotion ON entry_emotions(emotion, intensity);
+	`
+
+	// Detected language of each entry, as an ISO 639-1 code
+	languageColumns := `
+	ALTER TABLE journals ADD COLUMN IF NOT EXISTS language VARCHAR(10);
+	`
+
+	// Per-user response preferences; NULL keeps the default companion
+	responseStyleColumns := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS response_style VARCHAR(30);
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS response_length VARCHAR(20);
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS response_tone VARCHAR(20);
+	`
+
+	// Refresh tokens, stored as hashes. Tokens rotated from the same login
+	// share a family so a reused token can revoke all of them.
+	refreshTokensTable := `
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		family_id VARCHAR(64) NOT NULL,
+		token_hash VARCHAR(64) NOT NULL UNIQUE,
+		expires_at TIMESTAMP NOT NULL,
+		used_at TIMESTAMP,
+		revoked_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens(family_id);
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens(user_id);
+	`
+
+	// Access tokens revoked before they expire, by token ID, and the time
+	// before which all of a user's tokens are revoked
+	revokedTokensTable := `
+	CREATE TABLE IF NOT EXISTS revoked_tokens (
+		jti VARCHAR(64) PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		expires_at TIMESTAMP NOT NULL,
+		revoked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_revoked_tokens_revoked_at ON revoked_tokens(revoked_at);
+
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS tokens_revoked_at TIMESTAMP;
+	`
+
+	// Let re-analysis jobs skip the analysis cache
+	analysisJobCacheColumns := `
+	ALTER TABLE analysis_jobs ADD COLUMN IF NOT EXISTS bypass_cache BOOLEAN NOT NULL DEFAULT FALSE;
+	`
+
+	// Count failed digest attempts so a failing digest isn't retried forever
+	digestAttemptColumns := `
+	ALTER TABLE digests ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE digests ADD COLUMN IF NOT EXISTS last_error TEXT;
+	`
+
+	// Token times are compared with times in Go, so they must not depend on
+	// the session time zone
+	tokenTimeColumns := `
+	ALTER TABLE users ALTER COLUMN tokens_revoked_at TYPE TIMESTAMPTZ;
+	ALTER TABLE revoked_tokens ALTER COLUMN expires_at TYPE TIMESTAMPTZ;
+	ALTER TABLE revoked_tokens ALTER COLUMN revoked_at TYPE TIMESTAMPTZ;
+	ALTER TABLE refresh_tokens ALTER COLUMN expires_at TYPE TIMESTAMPTZ;
+	ALTER TABLE refresh_tokens ALTER COLUMN used_at TYPE TIMESTAMPTZ;
+	ALTER TABLE refresh_tokens ALTER COLUMN revoked_at TYPE TIMESTAMPTZ;
+	ALTER TABLE refresh_tokens ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+	`
+
 	// Execute schema creation
 	if _, err := db.Exec(usersTable); err != nil {
 		return fmt.Errorf("error creating users table: %v", err)
@@ -80,6 +377,94 @@ func (db *Database) initSchema() error {
 		return fmt.Errorf("error creating journals table: %v", err)
 	}
 
+	if _, err := db.Exec(conversationsTable); err != nil {
+		return fmt.Errorf("error creating conversation tables: %v", err)
+	}
+
+	if _, err := db.Exec(analysisJobsTable); err != nil {
+		return fmt.Errorf("error creating analysis jobs table: %v", err)
+	}
+
+	if _, err := db.Exec(structuredAnalysisColumns); err != nil {
+		return fmt.Errorf("error adding structured analysis columns: %v", err)
+	}
+
+	if _, err := db.Exec(sentimentColumns); err != nil {
+		return fmt.Errorf("error adding sentiment columns: %v", err)
+	}
+
+	if _, err := db.Exec(riskColumns); err != nil {
+		return fmt.Errorf("error adding risk columns: %v", err)
+	}
+
+	if _, err := db.Exec(promptTables); err != nil {
+		return fmt.Errorf("error creating prompt tables: %v", err)
+	}
+
+	if _, err := db.Exec(injectionColumns); err != nil {
+		return fmt.Errorf("error adding injection columns: %v", err)
+	}
+
+	if _, err := db.Exec(analysisCacheTable); err != nil {
+		return fmt.Errorf("error creating analysis cache table: %v", err)
+	}
+
+	if _, err := db.Exec(entryConversationColumns); err != nil {
+		return fmt.Errorf("error adding entry conversation columns: %v", err)
+	}
+
+	if _, err := db.Exec(analysisHistoryTable); err != nil {
+		return fmt.Errorf("error creating analysis history table: %v", err)
+	}
+
+	if _, err := db.Exec(usageTables); err != nil {
+		return fmt.Errorf("error creating usage tables: %v", err)
+	}
+
+	if _, err := db.Exec(redactionColumns); err != nil {
+		return fmt.Errorf("error adding redaction columns: %v", err)
+	}
+
+	if _, err := db.Exec(embeddingsTable); err != nil {
+		return fmt.Errorf("error creating embeddings table: %v", err)
+	}
+
+	if _, err := db.Exec(digestsTable); err != nil {
+		return fmt.Errorf("error creating digests table: %v", err)
+	}
+
+	if _, err := db.Exec(entryEmotionsTable); err != nil {
+		return fmt.Errorf("error creating entry emotions table: %v", err)
+	}
+
+	if _, err := db.Exec(languageColumns); err != nil {
+		return fmt.Errorf("error adding language columns: %v", err)
+	}
+
+	if _, err := db.Exec(responseStyleColumns); err != nil {
+		return fmt.Errorf("error adding response style columns: %v", err)
+	}
+
+	if _, err := db.Exec(refreshTokensTable); err != nil {
+		return fmt.Errorf("error creating refresh tokens table: %v", err)
+	}
+
+	if _, err := db.Exec(revokedTokensTable); err != nil {
+		return fmt.Errorf("error creating revoked tokens table: %v", err)
+	}
+
+	if _, err := db.Exec(analysisJobCacheColumns); err != nil {
+		return fmt.Errorf("error adding analysis job cache columns: %v", err)
+	}
+
+	if _, err := db.Exec(digestAttemptColumns); err != nil {
+		return fmt.Errorf("error adding digest attempt columns: %v", err)
+	}
+
+	if _, err := db.Exec(tokenTimeColumns); err != nil {
+		return fmt.Errorf("error converting token time columns: %v", err)
+	}
+
 	log.Println("Database schema initialized successfully")
 	return nil
 }
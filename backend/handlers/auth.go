@@ -5,19 +5,35 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
-	"go_health_sentiment/auth"
+	"go_health_sentiment/middleware"
 	"go_health_sentiment/models"
+	"go_health_sentiment/services"
 	"go_health_sentiment/utils"
 )
 
 type AuthHandler struct {
-	db *sql.DB
+	db   *sql.DB
+	opts AuthOptions
 }
 
-func NewAuthHandler(db *sql.DB) *AuthHandler {
-	return &AuthHandler{db: db}
+// AuthOptions controls the refresh tokens issued alongside access tokens.
+type AuthOptions struct {
+	RefreshTTL time.Duration
+
+	// Cookie delivers refresh tokens in an httpOnly cookie instead of the
+	// response body; CookieSecure limits the cookie to HTTPS
+	Cookie       bool
+	CookieSecure bool
+
+	// Revocations records tokens revoked by logging out
+	Revocations *services.TokenRevocations
+}
+
+func NewAuthHandler(db *sql.DB, opts AuthOptions) *AuthHandler {
+	return &AuthHandler{db: db, opts: opts}
 }
 
 type RegisterRequest struct {
@@ -33,6 +49,11 @@ type LoginRequest struct {
 type AuthResponse struct {
 	Token string                `json:"token"`
 	User  models.UserResponse   `json:"user"`
+
+	// ExpiresIn is the access token's lifetime in seconds. RefreshToken is
+	// left out when it is sent as a cookie.
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
 }
 
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
@@ -74,27 +95,28 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		Password: string(hashedPassword),
 	}
 
-	if err := user.CreateUser(h.db); err != nil {
+	if err := user.CreateUser(r.Context(), h.db); err != nil {
 		if strings.Contains(err.Error(), "email already exists") {
 			utils.WriteError(w, http.StatusConflict, "Email already registered")
 			return
 		}
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, "Error creating user")
 		return
 	}
 
-	// Generate token
-	token, err := auth.GenerateToken(user.ID, user.Email)
+	// Generate tokens
+	response, err := h.issueTokens(w, r, &user)
 	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, "Error generating token")
 		return
 	}
 
-	response := AuthResponse{
-		Token: token,
-		User:  user.ToResponse(),
-	}
-
 	utils.WriteCreated(w, "User registered successfully", response)
 }
 
@@ -113,7 +135,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user by email
-	user, err := models.GetUserByEmail(h.db, strings.ToLower(req.Email))
+	user, err := models.GetUserByEmail(r.Context(), h.db, strings.ToLower(req.Email))
 	if err != nil {
 		utils.WriteError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
@@ -125,18 +147,16 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate token
-	token, err := auth.GenerateToken(user.ID, user.Email)
+	// Generate tokens
+	response, err := h.issueTokens(w, r, user)
 	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, "Error generating token")
 		return
 	}
 
-	response := AuthResponse{
-		Token: token,
-		User:  user.ToResponse(),
-	}
-
 	utils.WriteSuccess(w, "Login successful", response)
 }
 
@@ -147,7 +167,7 @@ func (h *AuthHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := models.GetUserByID(h.db, userID)
+	user, err := models.GetUserByID(r.Context(), h.db, userID)
 	if err != nil {
 		utils.WriteError(w, http.StatusNotFound, "User not found")
 		return
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go_health_sentiment/middleware"
+	"go_health_sentiment/services"
+	"go_health_sentiment/utils"
+)
+
+type ConversationHandler struct {
+	chat *services.ChatConversation
+}
+
+func NewConversationHandler(chat *services.ChatConversation) *ConversationHandler {
+	return &ConversationHandler{chat: chat}
+}
+
+func (h *ConversationHandler) GetConversationHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserKey).(int)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	history, err := h.chat.GetConversationHistory(r.Context(), userID)
+	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error retrieving conversation history")
+		return
+	}
+
+	utils.WriteSuccess(w, "Conversation history retrieved successfully", history)
+}
+
+func (h *ConversationHandler) ClearHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserKey).(int)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if err := h.chat.ClearHistory(r.Context(), userID); err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error clearing conversation history")
+		return
+	}
+
+	utils.WriteSuccess(w, "Conversation history cleared successfully", nil)
+}
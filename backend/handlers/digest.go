@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go_health_sentiment/middleware"
+	"go_health_sentiment/models"
+	"go_health_sentiment/utils"
+)
+
+type DigestHandler struct {
+	db *sql.DB
+}
+
+func NewDigestHandler(db *sql.DB) *DigestHandler {
+	return &DigestHandler{db: db}
+}
+
+func (h *DigestHandler) GetDigests(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserKey).(int)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	limit, offset := 10, 0
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	digests, err := models.GetDigestsByUser(r.Context(), h.db, userID, limit, offset)
+	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error retrieving digests")
+		return
+	}
+
+	utils.WriteSuccess(w, "Digests retrieved successfully", digests)
+}
+
+func (h *DigestHandler) GetDigest(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserKey).(int)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	digestID, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/digests/"), "/"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid digest ID")
+		return
+	}
+
+	digest, err := models.GetDigestByID(r.Context(), h.db, digestID, userID)
+	if err != nil {
+		if err.Error() == "digest not found" {
+			utils.WriteError(w, http.StatusNotFound, "Digest not found")
+			return
+		}
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error retrieving digest")
+		return
+	}
+
+	utils.WriteSuccess(w, "Digest retrieved successfully", digest)
+}
+
+type DigestSettings struct {
+	OptOut bool `json:"opt_out"`
+}
+
+func (h *DigestHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserKey).(int)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	optOut, err := models.GetDigestOptOut(r.Context(), h.db, userID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			utils.WriteError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error retrieving digest settings")
+		return
+	}
+
+	utils.WriteSuccess(w, "Digest settings retrieved successfully", DigestSettings{OptOut: optOut})
+}
+
+func (h *DigestHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserKey).(int)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req DigestSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := models.SetDigestOptOut(r.Context(), h.db, userID, req.OptOut); err != nil {
+		if err.Error() == "user not found" {
+			utils.WriteError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error updating digest settings")
+		return
+	}
+
+	utils.WriteSuccess(w, "Digest settings updated successfully", req)
+}
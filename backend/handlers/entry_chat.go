@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"go_health_sentiment/langdetect"
+	"go_health_sentiment/middleware"
+	"go_health_sentiment/models"
+	"go_health_sentiment/safety"
+	"go_health_sentiment/services"
+	"go_health_sentiment/utils"
+)
+
+type EntryChatRequest struct {
+	Message string `json:"message"`
+}
+
+type EntryChatResponse struct {
+	Reply           models.ConversationMessage `json:"reply"`
+	CrisisResources *safety.CrisisResources    `json:"crisis_resources,omitempty"`
+}
+
+// GetEntryChat returns the conversation about a journal entry.
+func (h *JournalHandler) GetEntryChat(w http.ResponseWriter, r *http.Request) {
+	entry, ok := h.entryFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	thread, err := h.chat.GetEntryThread(r.Context(), entry)
+	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error retrieving conversation")
+		return
+	}
+
+	utils.WriteSuccess(w, "Conversation retrieved successfully", thread)
+}
+
+// ChatAboutEntry replies to the user's message in the conversation about a
+// journal entry. Messages are screened like entries: crisis language gets
+// crisis resources instead of a model reply, and so does every message
+// about an entry that was itself answered with crisis resources.
+func (h *JournalHandler) ChatAboutEntry(w http.ResponseWriter, r *http.Request) {
+	entry, ok := h.entryFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req EntryChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	validationErrors := utils.ValidateChatMessage(req.Message)
+	if len(validationErrors) > 0 {
+		utils.WriteValidationError(w, validationErrors)
+		return
+	}
+	req.Message = utils.SanitizeInput(req.Message)
+
+	// An entry answered with crisis resources is never sent to the model,
+	// and neither is a conversation about it
+	crisisEntry := entry.RiskSource == "entry"
+	assessment := h.safety.ForLanguage(langdetect.Detect(req.Message).Language).Check(req.Message)
+	if assessment.Flagged || crisisEntry {
+		resources := h.resources.ForAcceptLanguage(r.Header.Get("Accept-Language"))
+		if assessment.Flagged {
+			log.Printf("Safety: chat message on entry %d flagged %s (%s)", entry.ID, assessment.Level, strings.Join(assessment.Reasons, ", "))
+		}
+
+		// An entry answered with crisis resources is flagged already
+		if assessment.Flagged && !crisisEntry {
+			if err := models.RecordRisk(r.Context(), h.db, entry.ID, true, assessment.Level, assessment.Reasons, "chat"); err != nil {
+				log.Printf("Error recording risk for entry %d: %v", entry.ID, err)
+			}
+		}
+		stored, err := h.chat.AppendEntryMessages(r.Context(), entry, req.Message, resources.Message)
+		if err != nil {
+			if utils.WriteContextError(w, r.Context(), err) {
+				return
+			}
+			utils.WriteError(w, http.StatusInternalServerError, "Error saving conversation")
+			return
+		}
+
+		utils.WriteCreated(w, "Reply created successfully", EntryChatResponse{
+			Reply:           stored[1],
+			CrisisResources: &resources,
+		})
+		return
+	}
+
+	report, err := h.usage.Report(r.Context(), entry.UserID)
+	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error checking usage quota")
+		return
+	}
+	if report.Exceeded != "" {
+		utils.WriteError(w, http.StatusTooManyRequests, "You've reached your "+report.Exceeded+" usage limit")
+		return
+	}
+
+	reply, err := h.chat.ReplyToEntry(r.Context(), entry, req.Message, func(reply string) string {
+		return h.screenReply(r.Context(), entry, reply)
+	})
+	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		log.Printf("Error replying in conversation about entry %d: %v", entry.ID, err)
+		utils.WriteError(w, http.StatusServiceUnavailable, "Reply temporarily unavailable, please try again")
+		return
+	}
+
+	utils.WriteCreated(w, "Reply created successfully", EntryChatResponse{Reply: *reply})
+}
+
+// screenReply replaces a chat reply that fails output screening. Replies
+// are written in the entry's language.
+func (h *JournalHandler) screenReply(ctx context.Context, entry *models.JournalEntry, reply string) string {
+	assessment := h.output.ForLanguage(entry.Language).Check(reply)
+	if !assessment.Flagged {
+		return reply
+	}
+
+	log.Printf("Safety: chat reply on entry %d assessed %s (%s)", entry.ID, assessment.Level, strings.Join(assessment.Reasons, ", "))
+	// The flag is kept even if the client has gone away
+	if err := models.RecordRisk(context.WithoutCancel(ctx), h.db, entry.ID, true, assessment.Level, assessment.Reasons, "model_output"); err != nil {
+		log.Printf("Error recording risk for entry %d: %v", entry.ID, err)
+	}
+	return services.SafeFallbackMessage
+}
+
+// entryFromRequest loads the authenticated user's entry named in the path,
+// writing an error response and returning false if it can't.
+func (h *JournalHandler) entryFromRequest(w http.ResponseWriter, r *http.Request) (*models.JournalEntry, bool) {
+	userID, ok := r.Context().Value(middleware.UserKey).(int)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "User not authenticated")
+		return nil, false
+	}
+
+	entryID, err := entryIDFromPath(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid entry ID")
+		return nil, false
+	}
+
+	entry, err := models.GetEntryByID(r.Context(), h.db, entryID, userID)
+	if err != nil {
+		if err.Error() == "journal entry not found" {
+			utils.WriteError(w, http.StatusNotFound, "Journal entry not found")
+			return nil, false
+		}
+		if utils.WriteContextError(w, r.Context(), err) {
+			return nil, false
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error retrieving journal entry")
+		return nil, false
+	}
+	return entry, true
+}
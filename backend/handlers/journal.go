@@ -1,36 +1,65 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"go_health_sentiment/langdetect"
 	"go_health_sentiment/middleware"
 	"go_health_sentiment/models"
+	"go_health_sentiment/promptguard"
+	"go_health_sentiment/safety"
+	"go_health_sentiment/sentiment"
 	"go_health_sentiment/services"
 	"go_health_sentiment/utils"
 )
 
 type JournalHandler struct {
-	db   *sql.DB
-	chat *services.ChatConversation
+	db        *sql.DB
+	chat      *services.ChatConversation
+	workers   *services.AnalysisWorkerPool
+	notifier  *services.AnalysisNotifier
+	safety    *safety.LanguageCheckers
+	output    *safety.LanguageCheckers
+	resources *safety.ResourceDirectory
+	usage     *services.UsageTracker
+
+	// timeout bounds the entry routes that don't call the model
+	timeout time.Duration
 }
 
-func NewJournalHandler(db *sql.DB, chat *services.ChatConversation) *JournalHandler {
+func NewJournalHandler(db *sql.DB, chat *services.ChatConversation, workers *services.AnalysisWorkerPool, notifier *services.AnalysisNotifier, checker *safety.LanguageCheckers, outputChecker *safety.LanguageCheckers, resources *safety.ResourceDirectory, usage *services.UsageTracker, timeout time.Duration) *JournalHandler {
 	return &JournalHandler{
-		db:   db,
-		chat: chat,
+		db:        db,
+		chat:      chat,
+		workers:   workers,
+		notifier:  notifier,
+		safety:    checker,
+		output:    outputChecker,
+		resources: resources,
+		usage:     usage,
+		timeout:   timeout,
 	}
 }
 
+// maxAnalysisWait caps how long GET /journal/{id}?wait= may block.
+const maxAnalysisWait = 60 * time.Second
+
 type CreateJournalRequest struct {
 	Content string `json:"content"`
 }
 
 type JournalResponse struct {
-	Entry    models.JournalEntryResponse `json:"entry"`
-	Analysis string                      `json:"analysis"`
+	Entry           models.JournalEntryResponse `json:"entry"`
+	Analysis        string                      `json:"analysis"`
+	CrisisResources *safety.CrisisResources     `json:"crisis_resources,omitempty"`
+	Usage           *services.UsageReport       `json:"usage,omitempty"`
 }
 
 func (h *JournalHandler) CreateJournalEntry(w http.ResponseWriter, r *http.Request) {
@@ -55,35 +84,107 @@ func (h *JournalHandler) CreateJournalEntry(w http.ResponseWriter, r *http.Reque
 
 	req.Content = utils.SanitizeInput(req.Content)
 
-	// Analyze journal entry
-	analysis, err := h.chat.AnalyzeJournalEntry(req.Content)
-	if err != nil {
-		// Don't fail the request if analysis fails, just log it
-		analysis = "Analysis temporarily unavailable. Your entry has been saved successfully."
-	}
-
-	// Determine sentiment (simple implementation)
-	sentiment := h.determineSentiment(analysis)
+	// Score sentiment offline from the entry itself, so the label does not
+	// depend on the model being available. The lexicon and, later, the
+	// prompt follow the entry's language.
+	language := langdetect.Detect(req.Content).Language
+	scores := sentiment.ForText(language, req.Content).Analyze(req.Content)
 
-	// Create journal entry
+	// Save the entry right away and leave the analysis to the worker pool
 	entry := models.JournalEntry{
 		Content:   req.Content,
 		UserID:    userID,
-		Analysis:  analysis,
-		Sentiment: sentiment,
+		Language:  language,
+		Sentiment: scores.Label(),
+		SentimentScores: &models.SentimentBreakdown{
+			Compound: scores.Compound,
+			Positive: scores.Positive,
+			Negative: scores.Negative,
+			Neutral:  scores.Neutral,
+		},
+		Emotions: services.LexiconEmotions(req.Content, language),
+	}
+
+	// Entries that look like attempts to steer the model are still analyzed
+	// (the prompt treats them as data) but are recorded for review
+	if flags := promptguard.Detect(req.Content); len(flags) > 0 {
+		log.Printf("Prompt guard: entry from user %d matched %s", userID, strings.Join(flags, ", "))
+		entry.InjectionFlags = flags
+	}
+
+	// Screen for crisis language before anything is sent to the model. A
+	// flagged entry gets crisis resources instead of the generic AI reply.
+	assessment := h.safety.ForLanguage(language).Check(req.Content)
+	entry.RiskLevel = assessment.Level
+	entry.RiskReasons = assessment.Reasons
+
+	if assessment.Flagged {
+		resources := h.resources.ForAcceptLanguage(r.Header.Get("Accept-Language"))
+		log.Printf("Safety: entry from user %d flagged %s (%s)", userID, assessment.Level, strings.Join(assessment.Reasons, ", "))
+
+		entry.RiskFlagged = true
+		entry.RiskSource = "entry"
+		entry.Analysis = resources.Message
+		if err := entry.CreateEntry(r.Context(), h.db); err != nil {
+			if utils.WriteContextError(w, r.Context(), err) {
+				return
+			}
+			utils.WriteError(w, http.StatusInternalServerError, "Error creating journal entry")
+			return
+		}
+
+		utils.WriteCreated(w, "Journal entry created successfully", JournalResponse{
+			Entry:           entry.ToResponse(),
+			Analysis:        entry.Analysis,
+			CrisisResources: &resources,
+		})
+		return
+	}
+
+	// Once the quota is used up the entry is kept but not analyzed
+	report, err := h.usage.Report(r.Context(), userID)
+	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error checking usage quota")
+		return
 	}
+	if report.Exceeded != "" {
+		log.Printf("Usage: user %d has exhausted their %s quota", userID, report.Exceeded)
 
-	if err := entry.CreateEntry(h.db); err != nil {
+		entry.AnalysisStatus = models.AnalysisSkipped
+		entry.Analysis = report.QuotaExceededMessage()
+		if err := entry.CreateEntry(r.Context(), h.db); err != nil {
+			if utils.WriteContextError(w, r.Context(), err) {
+				return
+			}
+			utils.WriteError(w, http.StatusInternalServerError, "Error creating journal entry")
+			return
+		}
+
+		utils.WriteCreated(w, "Journal entry saved without analysis: usage quota reached", JournalResponse{
+			Entry:    entry.ToResponse(),
+			Analysis: entry.Analysis,
+			Usage:    report,
+		})
+		return
+	}
+
+	if err := h.workers.Enqueue(r.Context(), &entry); err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, "Error creating journal entry")
 		return
 	}
 
 	response := JournalResponse{
 		Entry:    entry.ToResponse(),
-		Analysis: analysis,
+		Analysis: entry.Analysis,
 	}
 
-	utils.WriteCreated(w, "Journal entry created successfully", response)
+	utils.WriteCreated(w, "Journal entry created successfully, analysis in progress", response)
 }
 
 func (h *JournalHandler) GetJournalEntries(w http.ResponseWriter, r *http.Request) {
@@ -112,8 +213,24 @@ func (h *JournalHandler) GetJournalEntries(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
-	entries, err := models.GetEntriesByUser(h.db, userID, limit, offset)
+	// Optional emotion filter: ?emotion=fear,sadness&min_intensity=0.5
+	filter, validationErrors := parseEmotionFilter(r)
+	if len(validationErrors) > 0 {
+		utils.WriteValidationError(w, validationErrors)
+		return
+	}
+
+	var entries []models.JournalEntry
+	var err error
+	if filter != nil {
+		entries, err = models.GetEntriesByEmotion(r.Context(), h.db, userID, *filter, limit, offset)
+	} else {
+		entries, err = models.GetEntriesByUser(r.Context(), h.db, userID, limit, offset)
+	}
 	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, "Error retrieving journal entries")
 		return
 	}
@@ -127,6 +244,102 @@ func (h *JournalHandler) GetJournalEntries(w http.ResponseWriter, r *http.Reques
 	utils.WriteSuccess(w, "Journal entries retrieved successfully", responses)
 }
 
+// parseEmotionFilter reads the emotion and min_intensity query parameters.
+// It returns nil when no emotion is given.
+func parseEmotionFilter(r *http.Request) (*models.EmotionFilter, []utils.ValidationError) {
+	param := r.URL.Query().Get("emotion")
+	if param == "" {
+		return nil, nil
+	}
+
+	var errors []utils.ValidationError
+	filter := models.EmotionFilter{MinIntensity: sentiment.MinEmotionIntensity}
+	for _, emotion := range strings.Split(param, ",") {
+		emotion = strings.ToLower(strings.TrimSpace(emotion))
+		if !sentiment.IsEmotion(emotion) {
+			errors = append(errors, utils.ValidationError{
+				Field:   "emotion",
+				Message: "Emotion must be one of " + strings.Join(sentiment.EmotionLabels, ", "),
+			})
+			break
+		}
+		filter.Emotions = append(filter.Emotions, emotion)
+	}
+
+	if minStr := r.URL.Query().Get("min_intensity"); minStr != "" {
+		min, err := strconv.ParseFloat(minStr, 64)
+		if err != nil || min < 0 || min > 1 {
+			errors = append(errors, utils.ValidationError{
+				Field:   "min_intensity",
+				Message: "Minimum intensity must be a number between 0 and 1",
+			})
+		} else {
+			filter.MinIntensity = min
+		}
+	}
+
+	return &filter, errors
+}
+
+// ServeEntry routes /journal/{id} and its sub-resources.
+func (h *JournalHandler) ServeEntry(w http.ResponseWriter, r *http.Request) {
+	parts := entryPathParts(r)
+
+	switch {
+	case len(parts) == 1:
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		// Leave room for the ?wait= long-poll
+		h.withTimeout(h.timeout+maxAnalysisWait, h.GetJournalEntry)(w, r)
+	case len(parts) == 3 && parts[1] == "analysis" && parts[2] == "stream":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.StreamAnalysis(w, r)
+	case len(parts) == 2 && parts[1] == "reanalyze":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.withTimeout(h.timeout, h.ReanalyzeEntry)(w, r)
+	case len(parts) == 2 && parts[1] == "analyses":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.withTimeout(h.timeout, h.GetEntryAnalyses)(w, r)
+	case len(parts) == 3 && parts[1] == "analyses":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.withTimeout(h.timeout, h.GetEntryAnalysis)(w, r)
+	case len(parts) == 2 && parts[1] == "chat":
+		switch r.Method {
+		case http.MethodGet:
+			h.withTimeout(h.timeout, h.GetEntryChat)(w, r)
+		case http.MethodPost:
+			h.ChatAboutEntry(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	default:
+		utils.WriteError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+// withTimeout bounds next by d. Streaming and chat replies aren't wrapped:
+// the analysis and reply deadlines in services bound them instead.
+func (h *JournalHandler) withTimeout(d time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	if h.timeout <= 0 {
+		return next
+	}
+	return middleware.Timeout(d, next).ServeHTTP
+}
+
 func (h *JournalHandler) GetJournalEntry(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserKey).(int)
 	if !ok {
@@ -134,55 +347,169 @@ func (h *JournalHandler) GetJournalEntry(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Extract entry ID from URL path
-	entryIDStr := r.URL.Path[len("/journal/"):]
-	entryID, err := strconv.Atoi(entryIDStr)
+	entryID, err := entryIDFromPath(r)
 	if err != nil {
 		utils.WriteError(w, http.StatusBadRequest, "Invalid entry ID")
 		return
 	}
 
-	entry, err := models.GetEntryByID(h.db, entryID, userID)
+	// Optional long-poll: ?wait=<seconds> blocks until the analysis finishes
+	var wait time.Duration
+	if waitStr := r.URL.Query().Get("wait"); waitStr != "" {
+		if secs, err := strconv.Atoi(waitStr); err == nil && secs > 0 {
+			wait = time.Duration(secs) * time.Second
+			if wait > maxAnalysisWait {
+				wait = maxAnalysisWait
+			}
+		}
+	}
+
+	// Subscribe before loading so a completion between the two isn't missed
+	var done <-chan struct{}
+	if wait > 0 {
+		ch, cancel := h.notifier.Subscribe(entryID)
+		defer cancel()
+		done = ch
+	}
+
+	entry, err := models.GetEntryByID(r.Context(), h.db, entryID, userID)
 	if err != nil {
 		if err.Error() == "journal entry not found" {
 			utils.WriteError(w, http.StatusNotFound, "Journal entry not found")
 			return
 		}
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
 		utils.WriteError(w, http.StatusInternalServerError, "Error retrieving journal entry")
 		return
 	}
 
+	if wait > 0 && !analysisFinished(entry.AnalysisStatus) {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-done:
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+
+		entry, err = models.GetEntryByID(r.Context(), h.db, entryID, userID)
+		if err != nil {
+			if utils.WriteContextError(w, r.Context(), err) {
+				return
+			}
+			utils.WriteError(w, http.StatusInternalServerError, "Error retrieving journal entry")
+			return
+		}
+	}
+
 	utils.WriteSuccess(w, "Journal entry retrieved successfully", entry.ToResponse())
 }
 
-func (h *JournalHandler) determineSentiment(analysis string) string {
-	// Simple sentiment analysis based on keywords
-	// In a production app, you might want to use a more sophisticated approach
-	analysis = strings.ToLower(analysis)
-	
-	positiveWords := []string{"positive", "happy", "good", "great", "excellent", "wonderful", "joy", "grateful"}
-	negativeWords := []string{"negative", "sad", "bad", "terrible", "awful", "depressed", "anxious", "worried"}
-	
-	positiveCount := 0
-	negativeCount := 0
-	
-	for _, word := range positiveWords {
-		if strings.Contains(analysis, word) {
-			positiveCount++
-		}
-	}
-	
-	for _, word := range negativeWords {
-		if strings.Contains(analysis, word) {
-			negativeCount++
-		}
-	}
-	
-	if positiveCount > negativeCount {
-		return "positive"
-	} else if negativeCount > positiveCount {
-		return "negative"
-	}
-	
-	return "neutral"
-}
\ No newline at end of file
+func analysisFinished(status string) bool {
+	return status == models.AnalysisDone || status == models.AnalysisFailed || status == models.AnalysisSkipped
+}
+
+// StreamAnalysis streams the entry's analysis over Server-Sent Events. If the
+// analysis is still queued it is generated in this request and each token is
+// sent as a "token" event; otherwise the stored text is sent as one event.
+// The stream ends with a "done" event carrying the saved entry.
+func (h *JournalHandler) StreamAnalysis(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserKey).(int)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	entryID, err := entryIDFromPath(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid entry ID")
+		return
+	}
+
+	// Subscribe before loading so a completion between the two isn't missed
+	done, unsubscribe := h.notifier.Subscribe(entryID)
+	defer unsubscribe()
+
+	entry, err := models.GetEntryByID(r.Context(), h.db, entryID, userID)
+	if err != nil {
+		if err.Error() == "journal entry not found" {
+			utils.WriteError(w, http.StatusNotFound, "Journal entry not found")
+			return
+		}
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error retrieving journal entry")
+		return
+	}
+
+	sse, err := utils.NewSSEWriter(w)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	// Cancel generation as soon as the client goes away or a write fails
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	if !analysisFinished(entry.AnalysisStatus) {
+		err = h.workers.StreamAnalysis(ctx, entry, func(token string) error {
+			if err := sse.Send("token", map[string]string{"text": token}); err != nil {
+				cancel()
+				return err
+			}
+			return nil
+		})
+
+		switch {
+		case err == nil:
+			h.finishStream(r.Context(), sse, entryID, userID)
+			return
+		case ctx.Err() != nil:
+			return
+		case err != services.ErrAnalysisInProgress:
+			sse.Send("error", map[string]string{"error": "Analysis temporarily unavailable, it will be retried"})
+			return
+		}
+
+		// A worker already holds the job; wait for it to finish
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	entry, err = models.GetEntryByID(r.Context(), h.db, entryID, userID)
+	if err != nil {
+		sse.Send("error", map[string]string{"error": "Error retrieving journal entry"})
+		return
+	}
+	if err := sse.Send("token", map[string]string{"text": entry.Analysis}); err != nil {
+		return
+	}
+	sse.Send("done", entry.ToResponse())
+}
+
+func (h *JournalHandler) finishStream(ctx context.Context, sse *utils.SSEWriter, entryID, userID int) {
+	entry, err := models.GetEntryByID(ctx, h.db, entryID, userID)
+	if err != nil {
+		sse.Send("error", map[string]string{"error": "Error retrieving journal entry"})
+		return
+	}
+	sse.Send("done", entry.ToResponse())
+}
+
+// entryPathParts splits the path below /journal/ into its segments.
+func entryPathParts(r *http.Request) []string {
+	return strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/journal/"), "/"), "/")
+}
+
+func entryIDFromPath(r *http.Request) (int, error) {
+	return strconv.Atoi(entryPathParts(r)[0])
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"go_health_sentiment/middleware"
+	"go_health_sentiment/models"
+	"go_health_sentiment/utils"
+)
+
+// PrivacyHandler manages whether a user's entries have PII redacted before
+// they are sent to the model.
+type PrivacyHandler struct {
+	db              *sql.DB
+	available       bool
+	redactByDefault bool
+}
+
+func NewPrivacyHandler(db *sql.DB, available, redactByDefault bool) *PrivacyHandler {
+	return &PrivacyHandler{db: db, available: available, redactByDefault: redactByDefault}
+}
+
+// PrivacySettings reports the redaction preference. RedactPII is what
+// applies to the user; Choice is what they picked, null meaning the default.
+type PrivacySettings struct {
+	RedactPII bool  `json:"redact_pii"`
+	Choice    *bool `json:"choice"`
+	Default   bool  `json:"default"`
+	Available bool  `json:"available"`
+}
+
+type UpdatePrivacyRequest struct {
+	// RedactPII turns redaction on or off; null returns to the default
+	RedactPII *bool `json:"redact_pii"`
+}
+
+func (h *PrivacyHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserKey).(int)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	choice, err := models.GetUserRedaction(r.Context(), h.db, userID)
+	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error retrieving privacy settings")
+		return
+	}
+
+	utils.WriteSuccess(w, "Privacy settings retrieved successfully", h.settings(choice))
+}
+
+func (h *PrivacyHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserKey).(int)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req UpdatePrivacyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := models.SetUserRedaction(r.Context(), h.db, userID, req.RedactPII); err != nil {
+		if err.Error() == "user not found" {
+			utils.WriteError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error updating privacy settings")
+		return
+	}
+
+	utils.WriteSuccess(w, "Privacy settings updated successfully", h.settings(req.RedactPII))
+}
+
+func (h *PrivacyHandler) settings(choice *bool) PrivacySettings {
+	s := PrivacySettings{
+		RedactPII: h.redactByDefault,
+		Choice:    choice,
+		Default:   h.redactByDefault,
+		Available: h.available,
+	}
+	if choice != nil {
+		s.RedactPII = *choice
+	}
+	s.RedactPII = s.RedactPII && h.available
+	return s
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"go_health_sentiment/models"
+	"go_health_sentiment/utils"
+)
+
+// ReanalyzeEntry queues a fresh analysis of an entry with the current model
+// and prompt, bypassing the analysis cache so an unchanged entry still gets
+// a new reading. The entry keeps its current analysis until the new one is
+// done; progress can be followed like a new entry's.
+func (h *JournalHandler) ReanalyzeEntry(w http.ResponseWriter, r *http.Request) {
+	entry, ok := h.entryFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if entry.RiskSource == "entry" {
+		utils.WriteError(w, http.StatusConflict, "This entry was answered with crisis resources and isn't analyzed")
+		return
+	}
+
+	report, err := h.usage.Report(r.Context(), entry.UserID)
+	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error checking usage quota")
+		return
+	}
+	if report.Exceeded != "" {
+		utils.WriteError(w, http.StatusTooManyRequests, "You've reached your "+report.Exceeded+" usage limit")
+		return
+	}
+
+	if err := h.workers.Reanalyze(r.Context(), entry, true); err != nil {
+		if err == models.ErrAnalysisQueued {
+			utils.WriteError(w, http.StatusConflict, "Analysis already in progress")
+			return
+		}
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		log.Printf("Error queueing re-analysis of entry %d: %v", entry.ID, err)
+		utils.WriteError(w, http.StatusInternalServerError, "Error queueing analysis")
+		return
+	}
+
+	utils.WriteAccepted(w, "Re-analysis queued", JournalResponse{
+		Entry:    entry.ToResponse(),
+		Analysis: entry.Analysis,
+	})
+}
+
+// GetEntryAnalyses lists every analysis of an entry, newest first.
+func (h *JournalHandler) GetEntryAnalyses(w http.ResponseWriter, r *http.Request) {
+	entry, ok := h.entryFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	analyses, err := models.GetEntryAnalyses(r.Context(), h.db, entry.ID)
+	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error retrieving analyses")
+		return
+	}
+
+	utils.WriteSuccess(w, "Analyses retrieved successfully", analyses)
+}
+
+func (h *JournalHandler) GetEntryAnalysis(w http.ResponseWriter, r *http.Request) {
+	entry, ok := h.entryFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	analysisID, err := strconv.Atoi(entryPathParts(r)[2])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid analysis ID")
+		return
+	}
+
+	analysis, err := models.GetEntryAnalysis(r.Context(), h.db, entry.ID, analysisID)
+	if err != nil {
+		if err.Error() == "analysis not found" {
+			utils.WriteError(w, http.StatusNotFound, "Analysis not found")
+			return
+		}
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error retrieving analysis")
+		return
+	}
+
+	utils.WriteSuccess(w, "Analysis retrieved successfully", analysis)
+}
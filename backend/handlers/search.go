@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"go_health_sentiment/middleware"
+	"go_health_sentiment/models"
+	"go_health_sentiment/services"
+	"go_health_sentiment/utils"
+)
+
+type SearchHandler struct {
+	index *services.SemanticIndex
+}
+
+func NewSearchHandler(index *services.SemanticIndex) *SearchHandler {
+	return &SearchHandler{index: index}
+}
+
+// SearchResult is an entry matching a search, with its similarity to the
+// query between 0 and 1.
+type SearchResult struct {
+	Entry models.JournalEntryResponse `json:"entry"`
+	Score float64                     `json:"score"`
+}
+
+// SearchEntries finds the caller's entries most similar in meaning to the
+// semantic query parameter.
+func (h *SearchHandler) SearchEntries(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserKey).(int)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	query := r.URL.Query().Get("semantic")
+	if validationErrors := utils.ValidateSearchQuery(query); len(validationErrors) > 0 {
+		utils.WriteValidationError(w, validationErrors)
+		return
+	}
+
+	limit := 10
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 50 {
+		limit = l
+	}
+
+	matches, err := h.index.Search(r.Context(), userID, utils.SanitizeInput(query), limit)
+	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		log.Printf("Error searching entries for user %d: %v", userID, err)
+		utils.WriteError(w, http.StatusInternalServerError, "Error searching journal entries")
+		return
+	}
+
+	results := make([]SearchResult, len(matches))
+	for i, m := range matches {
+		results[i] = SearchResult{Entry: m.Entry.ToResponse(), Score: m.Score}
+	}
+
+	utils.WriteSuccess(w, "Search completed successfully", results)
+}
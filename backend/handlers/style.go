@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"go_health_sentiment/langdetect"
+	"go_health_sentiment/middleware"
+	"go_health_sentiment/models"
+	"go_health_sentiment/safety"
+	"go_health_sentiment/services"
+	"go_health_sentiment/utils"
+)
+
+// ResponseStyleHandler manages how the companion writes to a user and
+// previews the available styles.
+type ResponseStyleHandler struct {
+	db        *sql.DB
+	chat      *services.ChatConversation
+	usage     *services.UsageTracker
+	safety    *safety.LanguageCheckers
+	output    *safety.LanguageCheckers
+	resources *safety.ResourceDirectory
+}
+
+func NewResponseStyleHandler(db *sql.DB, chat *services.ChatConversation, usage *services.UsageTracker, checker *safety.LanguageCheckers, outputChecker *safety.LanguageCheckers, resources *safety.ResourceDirectory) *ResponseStyleHandler {
+	return &ResponseStyleHandler{
+		db:        db,
+		chat:      chat,
+		usage:     usage,
+		safety:    checker,
+		output:    outputChecker,
+		resources: resources,
+	}
+}
+
+// ResponseSettings reports the user's response preferences alongside the
+// values each can take. Empty preferences use the default companion.
+type ResponseSettings struct {
+	models.ResponsePreferences
+	Options ResponseOptions `json:"options"`
+}
+
+type ResponseOptions struct {
+	Styles  []string `json:"styles"`
+	Lengths []string `json:"lengths"`
+	Tones   []string `json:"tones"`
+}
+
+var responseOptions = ResponseOptions{
+	Styles:  models.ResponseStyles,
+	Lengths: models.ResponseLengths,
+	Tones:   models.ResponseTones,
+}
+
+type PreviewStylesRequest struct {
+	// Content is the entry to answer; empty uses a sample entry
+	Content string `json:"content"`
+
+	// Length and Tone default to the user's saved preferences
+	Length *string `json:"length"`
+	Tone   *string `json:"tone"`
+}
+
+type PreviewStylesResponse struct {
+	Content         string                  `json:"content"`
+	Previews        []services.StylePreview `json:"previews,omitempty"`
+	CrisisResources *safety.CrisisResources `json:"crisis_resources,omitempty"`
+}
+
+func (h *ResponseStyleHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserKey).(int)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	prefs, err := models.GetUserResponsePreferences(r.Context(), h.db, userID)
+	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error retrieving response settings")
+		return
+	}
+
+	utils.WriteSuccess(w, "Response settings retrieved successfully", ResponseSettings{prefs, responseOptions})
+}
+
+func (h *ResponseStyleHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserKey).(int)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req models.ResponsePreferences
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if errs := validateResponsePreferences(req); len(errs) > 0 {
+		utils.WriteValidationError(w, errs)
+		return
+	}
+
+	if err := models.SetUserResponsePreferences(r.Context(), h.db, userID, req); err != nil {
+		if err.Error() == "user not found" {
+			utils.WriteError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error updating response settings")
+		return
+	}
+
+	utils.WriteSuccess(w, "Response settings updated successfully", ResponseSettings{req, responseOptions})
+}
+
+// PreviewStyles answers an entry in every response style so the user can
+// pick one. Previews are screened like analyses and count towards usage.
+func (h *ResponseStyleHandler) PreviewStyles(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserKey).(int)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req PreviewStylesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	prefs, err := models.GetUserResponsePreferences(r.Context(), h.db, userID)
+	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error retrieving response settings")
+		return
+	}
+	if req.Length != nil {
+		prefs.Length = *req.Length
+	}
+	if req.Tone != nil {
+		prefs.Tone = *req.Tone
+	}
+	prefs.Style = ""
+	if errs := validateResponsePreferences(prefs); len(errs) > 0 {
+		utils.WriteValidationError(w, errs)
+		return
+	}
+
+	content := services.SamplePreviewEntry
+	language := ""
+	if strings.TrimSpace(req.Content) != "" {
+		if errs := utils.ValidateJournalContent(req.Content); len(errs) > 0 {
+			utils.WriteValidationError(w, errs)
+			return
+		}
+		content = utils.SanitizeInput(req.Content)
+		language = langdetect.Detect(content).Language
+
+		// A preview isn't saved, but crisis language still gets crisis
+		// resources rather than sample replies
+		if assessment := h.safety.ForLanguage(language).Check(content); assessment.Flagged {
+			log.Printf("Safety: style preview for user %d flagged %s (%s)", userID, assessment.Level, strings.Join(assessment.Reasons, ", "))
+			resources := h.resources.ForAcceptLanguage(r.Header.Get("Accept-Language"))
+			utils.WriteSuccess(w, "Crisis resources provided", PreviewStylesResponse{
+				Content:         content,
+				CrisisResources: &resources,
+			})
+			return
+		}
+	}
+
+	report, err := h.usage.Report(r.Context(), userID)
+	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error checking usage quota")
+		return
+	}
+	if report.Exceeded != "" {
+		utils.WriteError(w, http.StatusTooManyRequests, "You've reached your "+report.Exceeded+" usage limit")
+		return
+	}
+
+	previews, err := h.chat.PreviewStyles(r.Context(), userID, content, prefs.Length, prefs.Tone, func(result *models.AnalysisResult) {
+		h.screenPreview(userID, language, result)
+	})
+	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		log.Printf("Error previewing response styles for user %d: %v", userID, err)
+		utils.WriteError(w, http.StatusInternalServerError, "Error previewing response styles")
+		return
+	}
+
+	utils.WriteSuccess(w, "Response styles previewed successfully", PreviewStylesResponse{
+		Content:  content,
+		Previews: previews,
+	})
+}
+
+// screenPreview replaces a preview that fails output screening. Previews
+// are written in the language of the sample they reply to.
+func (h *ResponseStyleHandler) screenPreview(userID int, language string, result *models.AnalysisResult) {
+	text := result.SupportiveMessage + "\n" + strings.Join(result.ReflectionSuggestions, "\n")
+	assessment := h.output.ForLanguage(language).Check(text)
+	if !assessment.Flagged {
+		return
+	}
+
+	log.Printf("Safety: style preview for user %d assessed %s (%s)", userID, assessment.Level, strings.Join(assessment.Reasons, ", "))
+	result.SupportiveMessage = services.SafeFallbackMessage
+	result.ReflectionSuggestions = []string{"Reach out to someone you trust and let them know how you are feeling."}
+}
+
+// validateResponsePreferences checks each set preference against the
+// values it can take.
+func validateResponsePreferences(prefs models.ResponsePreferences) []utils.ValidationError {
+	var errs []utils.ValidationError
+	check := func(field, value string, allowed []string) {
+		if value != "" && !slices.Contains(allowed, value) {
+			errs = append(errs, utils.ValidationError{
+				Field:   field,
+				Message: field + " must be one of: " + strings.Join(allowed, ", "),
+			})
+		}
+	}
+	check("style", prefs.Style, models.ResponseStyles)
+	check("length", prefs.Length, models.ResponseLengths)
+	check("tone", prefs.Tone, models.ResponseTones)
+	return errs
+}
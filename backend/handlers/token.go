@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"go_health_sentiment/auth"
+	"go_health_sentiment/middleware"
+	"go_health_sentiment/models"
+	"go_health_sentiment/utils"
+)
+
+// refreshCookie is the httpOnly cookie refresh tokens are sent in when
+// AuthOptions.Cookie is set.
+const refreshCookie = "refresh_token"
+
+type RefreshRequest struct {
+	// RefreshToken may be left out when it is sent as a cookie
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token. Each refresh token works once: presenting a spent one
+// revokes every token rotated from the same login.
+func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	presented, err := refreshTokenFromRequest(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if presented == "" {
+		utils.WriteError(w, http.StatusUnauthorized, "Missing refresh token")
+		return
+	}
+
+	refresh, err := auth.NewRefreshToken()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Error generating token")
+		return
+	}
+	expiresAt := time.Now().Add(h.opts.RefreshTTL)
+
+	spent, err := models.RotateRefreshToken(r.Context(), h.db, auth.HashRefreshToken(presented), auth.HashRefreshToken(refresh), expiresAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRefreshTokenReused):
+			log.Printf("Auth: spent refresh token presented again; revoked its family")
+			h.clearRefreshCookie(w)
+			utils.WriteError(w, http.StatusUnauthorized, "Refresh token already used, please log in again")
+			return
+		case errors.Is(err, models.ErrRefreshTokenInvalid):
+			h.clearRefreshCookie(w)
+			utils.WriteError(w, http.StatusUnauthorized, "Invalid refresh token")
+			return
+		}
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error refreshing token")
+		return
+	}
+
+	user, err := models.GetUserByID(r.Context(), h.db, spent.UserID)
+	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	response, err := h.tokenResponse(w, user, refresh, expiresAt)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Error generating token")
+		return
+	}
+
+	utils.WriteSuccess(w, "Token refreshed successfully", response)
+}
+
+// Logout revokes the access token of the request and, when one is sent,
+// the refresh token family it was issued with.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.ClaimsKey).(*auth.Claims)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	presented, err := refreshTokenFromRequest(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.opts.Revocations.Revoke(r.Context(), claims); err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error logging out")
+		return
+	}
+	if presented != "" {
+		if err := models.RevokeRefreshTokenFamily(r.Context(), h.db, auth.HashRefreshToken(presented)); err != nil {
+			if utils.WriteContextError(w, r.Context(), err) {
+				return
+			}
+			utils.WriteError(w, http.StatusInternalServerError, "Error logging out")
+			return
+		}
+	}
+
+	h.clearRefreshCookie(w)
+	utils.WriteSuccess(w, "Logged out successfully", nil)
+}
+
+// LogoutEverywhere revokes every access and refresh token issued to the
+// user, on every device.
+func (h *AuthHandler) LogoutEverywhere(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserKey).(int)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if err := h.opts.Revocations.RevokeAll(r.Context(), userID); err != nil {
+		if err.Error() == "user not found" {
+			utils.WriteError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error logging out")
+		return
+	}
+
+	h.clearRefreshCookie(w)
+	utils.WriteSuccess(w, "Logged out everywhere successfully", nil)
+}
+
+// refreshTokenFromRequest returns the refresh token in the request body,
+// falling back to the refresh cookie. The body may be empty.
+func refreshTokenFromRequest(r *http.Request) (string, error) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if req.RefreshToken == "" {
+		if cookie, err := r.Cookie(refreshCookie); err == nil {
+			req.RefreshToken = cookie.Value
+		}
+	}
+	return req.RefreshToken, nil
+}
+
+// issueTokens signs an access token for user and starts a new family of
+// refresh tokens, as on login.
+func (h *AuthHandler) issueTokens(w http.ResponseWriter, r *http.Request, user *models.User) (*AuthResponse, error) {
+	refresh, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	family, err := auth.NewTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(h.opts.RefreshTTL)
+	if err := models.CreateRefreshToken(r.Context(), h.db, user.ID, family, auth.HashRefreshToken(refresh), expiresAt); err != nil {
+		return nil, err
+	}
+	return h.tokenResponse(w, user, refresh, expiresAt)
+}
+
+// tokenResponse signs an access token for user and hands over the refresh
+// token, as a cookie or in the body.
+func (h *AuthHandler) tokenResponse(w http.ResponseWriter, user *models.User, refresh string, expiresAt time.Time) (*AuthResponse, error) {
+	token, err := auth.GenerateToken(user.ID, user.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &AuthResponse{
+		Token:     token,
+		User:      user.ToResponse(),
+		ExpiresIn: int(auth.AccessTokenTTL.Seconds()),
+	}
+	if h.opts.Cookie {
+		http.SetCookie(w, &http.Cookie{
+			Name:     refreshCookie,
+			Value:    refresh,
+			Path:     "/",
+			Expires:  expiresAt,
+			HttpOnly: true,
+			Secure:   h.opts.CookieSecure,
+			SameSite: http.SameSiteStrictMode,
+		})
+	} else {
+		response.RefreshToken = refresh
+	}
+	return response, nil
+}
+
+func (h *AuthHandler) clearRefreshCookie(w http.ResponseWriter) {
+	if !h.opts.Cookie {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   h.opts.CookieSecure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go_health_sentiment/middleware"
+	"go_health_sentiment/models"
+	"go_health_sentiment/services"
+	"go_health_sentiment/utils"
+)
+
+// recentUsageLimit is the number of individual records returned by GET /usage.
+const recentUsageLimit = 20
+
+type UsageHandler struct {
+	usage *services.UsageTracker
+}
+
+func NewUsageHandler(usage *services.UsageTracker) *UsageHandler {
+	return &UsageHandler{usage: usage}
+}
+
+type UsageResponse struct {
+	*services.UsageReport
+	Recent []models.UsageRecord `json:"recent"`
+}
+
+func (h *UsageHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserKey).(int)
+	if !ok {
+		utils.WriteError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	report, err := h.usage.Report(r.Context(), userID)
+	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error retrieving usage")
+		return
+	}
+
+	recent, err := h.usage.Recent(r.Context(), userID, recentUsageLimit)
+	if err != nil {
+		if utils.WriteContextError(w, r.Context(), err) {
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Error retrieving usage")
+		return
+	}
+
+	utils.WriteSuccess(w, "Usage retrieved successfully", UsageResponse{
+		UsageReport: report,
+		Recent:      recent,
+	})
+}
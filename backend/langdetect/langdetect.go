@@ -0,0 +1,169 @@
+// Package langdetect identifies the language of a piece of text offline. It
+// scores the text's character trigrams against per-language frequency
+// profiles trained at start-up from the sample text embedded under corpus/.
+package langdetect
+
+import (
+	"embed"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+//go:embed corpus
+var corpus embed.FS
+
+// ISO 639-1 codes of the languages with a built-in profile.
+const (
+	English = "en"
+	Spanish = "es"
+	German  = "de"
+	Hindi   = "hi"
+)
+
+const (
+	// minLetters is the shortest text, in letters and combining marks,
+	// worth guessing at
+	minLetters = 12
+
+	// minConfidence is the lowest confidence reported as a detection
+	minConfidence = 0.6
+)
+
+// Result is a detected language with the confidence of the detection, from
+// 0 to 1. Language is empty when the text is too short or ambiguous.
+type Result struct {
+	Language   string  `json:"language"`
+	Confidence float64 `json:"confidence"`
+}
+
+type profile struct {
+	counts map[string]float64
+	total  float64
+}
+
+// Detector scores text against a set of language profiles.
+type Detector struct {
+	profiles map[string]*profile
+}
+
+// New trains a detector from sample text for each language, keyed by
+// language code.
+func New(samples map[string]string) *Detector {
+	d := &Detector{profiles: make(map[string]*profile, len(samples))}
+	for lang, text := range samples {
+		p := &profile{counts: make(map[string]float64)}
+		for _, tri := range trigrams(text) {
+			p.counts[tri]++
+			p.total++
+		}
+		d.profiles[lang] = p
+	}
+	return d
+}
+
+var defaultDetector = mustLoadEmbedded()
+
+func mustLoadEmbedded() *Detector {
+	entries, err := corpus.ReadDir("corpus")
+	if err != nil {
+		panic(fmt.Sprintf("langdetect: error reading corpus: %v", err))
+	}
+
+	samples := make(map[string]string, len(entries))
+	for _, e := range entries {
+		body, err := corpus.ReadFile(path.Join("corpus", e.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("langdetect: error reading %s: %v", e.Name(), err))
+		}
+		samples[strings.TrimSuffix(e.Name(), path.Ext(e.Name()))] = string(body)
+	}
+	return New(samples)
+}
+
+// Detect identifies the language of text using the built-in profiles.
+func Detect(text string) Result {
+	return defaultDetector.Detect(text)
+}
+
+// Languages lists the codes of the built-in profiles.
+func Languages() []string {
+	return defaultDetector.Languages()
+}
+
+// Languages lists the detector's language codes in sorted order.
+func (d *Detector) Languages() []string {
+	langs := make([]string, 0, len(d.profiles))
+	for lang := range d.profiles {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Detect returns the language whose profile gives text the highest
+// likelihood. Confidence is that language's share of the likelihood across
+// all profiles.
+func (d *Detector) Detect(text string) Result {
+	if countLetters(text) < minLetters || len(d.profiles) == 0 {
+		return Result{}
+	}
+
+	tris := trigrams(text)
+	scores := make(map[string]float64, len(d.profiles))
+	best, bestScore := "", math.Inf(-1)
+	for _, lang := range d.Languages() {
+		p := d.profiles[lang]
+		vocab := float64(len(p.counts)) + 1
+		score := 0.0
+		for _, tri := range tris {
+			// Add-one smoothing keeps unseen trigrams from ruling a language out
+			score += math.Log((p.counts[tri] + 1) / (p.total + vocab))
+		}
+		scores[lang] = score
+		if score > bestScore {
+			best, bestScore = lang, score
+		}
+	}
+
+	sum := 0.0
+	for _, score := range scores {
+		sum += math.Exp(score - bestScore)
+	}
+	confidence := math.Round(10000/sum) / 10000
+	if confidence < minConfidence {
+		return Result{Confidence: confidence}
+	}
+	return Result{Language: best, Confidence: confidence}
+}
+
+// trigrams splits text into lower-cased words and returns the character
+// trigrams of each, padded with a space on either side.
+func trigrams(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsMark(r)
+	})
+
+	var tris []string
+	for _, w := range words {
+		runes := []rune(" " + w + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			tris = append(tris, string(runes[i:i+3]))
+		}
+	}
+	return tris
+}
+
+func countLetters(text string) int {
+	n := 0
+	for _, r := range text {
+		// Devanagari vowel signs are marks, not letters
+		if unicode.IsLetter(r) || unicode.IsMark(r) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,58 @@
+package langdetect
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"english", "Today was a long day at work, but I feel better after talking with my sister.", English},
+		{"spanish", "Hoy fue un día largo en el trabajo, pero me siento mejor después de hablar con mi hermana.", Spanish},
+		{"german", "Heute war ein langer Tag bei der Arbeit, aber nach dem Gespräch mit meiner Schwester geht es mir besser.", German},
+		{"hindi", "आज काम पर बहुत लंबा दिन था, लेकिन अपनी बहन से बात करके मुझे अच्छा लगा।", Hindi},
+		{"too short", "no está mal", ""},
+		{"no letters", "12345 !!! ???", ""},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Detect(tt.text)
+			if got.Language != tt.want {
+				t.Fatalf("Detect(%q) = %+v, want language %q", tt.text, got, tt.want)
+			}
+			if got.Language != "" && got.Confidence < minConfidence {
+				t.Errorf("confidence %v is below the minimum for a detection", got.Confidence)
+			}
+		})
+	}
+}
+
+func TestNewDetector(t *testing.T) {
+	d := New(map[string]string{
+		"aa": "banana bandana cabana banana bandana cabana",
+		"bb": "kiwi kiwi kiwi wiki kiwi wiki kiwi",
+	})
+
+	if got := d.Languages(); !slices.Equal(got, []string{"aa", "bb"}) {
+		t.Errorf("Languages() = %v", got)
+	}
+	if got := d.Detect("banana cabana bandana").Language; got != "aa" {
+		t.Errorf("Detect = %q, want aa", got)
+	}
+	if got := New(nil).Detect("long enough text to guess at").Language; got != "" {
+		t.Errorf("detector without profiles detected %q", got)
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	want := []string{German, English, Spanish, Hindi}
+	if got := Languages(); !slices.Equal(got, want) {
+		t.Errorf("Languages() = %v, want %v", got, want)
+	}
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/rs/cors"
@@ -14,6 +17,8 @@ import (
 	"go_health_sentiment/db"
 	"go_health_sentiment/handlers"
 	"go_health_sentiment/middleware"
+	"go_health_sentiment/prompts"
+	"go_health_sentiment/safety"
 	"go_health_sentiment/services"
 )
 
@@ -22,7 +27,7 @@ func main() {
 	cfg := config.LoadConfig()
 
 	// Initialize auth with JWT secret
-	auth.InitializeAuth(cfg.JWTSecret)
+	auth.InitializeAuth(cfg.JWTSecret, cfg.Auth.AccessTokenTTL)
 
 	// Connect to database
 	database, err := db.NewConnection(cfg.DatabaseURL)
@@ -31,12 +36,169 @@ func main() {
 	}
 	defer database.Close()
 
+	// Reject revoked tokens, with revocations mirrored in memory
+	revocations := services.NewTokenRevocations(database.DB, services.RevocationOptions{
+		SyncInterval:    cfg.Auth.RevocationSync,
+		CleanupInterval: cfg.Auth.RevocationCleanup,
+		CacheSize:       cfg.Auth.RevocationCacheSize,
+	})
+	if err := revocations.Load(context.Background()); err != nil {
+		log.Fatal("Failed to load token revocations:", err)
+	}
+	revocations.Start()
+	middleware.UseRevocations(revocations)
+
 	// Initialize services
-	chat := services.NewChatConversation()
+	baseProvider, err := services.NewProvider(cfg.LLM)
+	if err != nil {
+		log.Fatal("Failed to configure LLM provider:", err)
+	}
+	log.Printf("Using LLM provider %s (model %s)", baseProvider.Name(), baseProvider.Model())
+
+	// Retry transient upstream failures and fail fast while the upstream is down
+	provider := services.NewResilientProvider(baseProvider,
+		services.RetryOptions{
+			MaxAttempts: cfg.LLM.RetryAttempts,
+			Base:        cfg.LLM.RetryBase,
+			Max:         cfg.LLM.RetryMax,
+		},
+		services.NewCircuitBreaker(cfg.LLM.BreakerThreshold, cfg.LLM.BreakerCooldown),
+	)
+
+	// Load prompt templates: embedded defaults, then any stored in the database
+	promptRegistry, err := prompts.LoadEmbedded()
+	if err != nil {
+		log.Fatal("Failed to load prompt templates:", err)
+	}
+	if err := promptRegistry.LoadFromDB(context.Background(), database.DB); err != nil {
+		log.Fatal("Failed to load prompt templates from database:", err)
+	}
+	if err := promptRegistry.SetDefault(prompts.Analysis, cfg.LLM.PromptVersion); err != nil {
+		log.Fatal("Invalid PROMPT_VERSION:", err)
+	}
+	for _, name := range []string{prompts.EntryChat, prompts.ChatSummary, prompts.Digest, prompts.ResponseStyle} {
+		if err := promptRegistry.SetDefault(name, "v1"); err != nil {
+			log.Fatal("Failed to load prompt templates:", err)
+		}
+	}
+
+	// Reuse analyses of resubmitted entries
+	cacheBackend, err := services.NewCacheBackend(cfg.Analysis.Cache, database.DB, cfg.Analysis.CacheSize, cfg.Analysis.CacheTTL)
+	if err != nil {
+		log.Fatal("Failed to configure analysis cache:", err)
+	}
+	var analysisCache *services.AnalysisCache
+	if cacheBackend != nil {
+		analysisCache = services.NewAnalysisCache(cacheBackend)
+	}
+
+	// Usage accounting and per-plan quotas
+	quotaPlans, err := services.ParseQuotaPlans(cfg.Usage.Plans)
+	if err != nil {
+		log.Fatal("Failed to parse usage plans:", err)
+	}
+	usageTracker := services.NewUsageTracker(database.DB, quotaPlans, cfg.Usage.DefaultPlan)
+
+	// Strip PII from entries before they reach the model
+	redactor, redactByDefault, err := services.NewRedactor(cfg.Redaction)
+	if err != nil {
+		log.Fatal("Failed to configure PII redaction:", err)
+	}
+	if redactor != nil {
+		log.Printf("PII redaction enabled (detectors: %s)", strings.Join(redactor.Detectors(), ", "))
+	}
+
+	// Embed entries for semantic search
+	embedder, err := services.NewEmbedder(cfg.Embedding)
+	if err != nil {
+		log.Fatal("Failed to configure embeddings:", err)
+	}
+	semanticIndex := services.NewSemanticIndex(database.DB, embedder, services.SemanticIndexOptions{
+		Redactor:        redactor,
+		RedactByDefault: redactByDefault,
+	})
+	log.Printf("Using embedder %s", embedder.Name())
+
+	chat := services.NewChatConversation(database.DB, provider, promptRegistry, services.ChatOptions{
+		Params: services.GenerationParams{
+			MaxTokens:   cfg.LLM.MaxTokens,
+			Temperature: cfg.LLM.Temperature,
+			TopP:        cfg.LLM.TopP,
+		},
+		HistoryLimit:   cfg.LLM.HistoryLimit,
+		RepairAttempts: cfg.LLM.RepairAttempts,
+		ChatWindow:     cfg.LLM.ChatWindow,
+		Cache:          analysisCache,
+		Usage:          usageTracker,
+
+		Redactor:        redactor,
+		RedactByDefault: redactByDefault,
+
+		History: services.HistoryOptions{
+			Tokens:   cfg.Analysis.ContextTokens,
+			Recent:   cfg.Analysis.ContextRecent,
+			Similar:  cfg.Analysis.ContextSimilar,
+			Lookback: cfg.Analysis.ContextLookback,
+		},
+		Index: semanticIndex,
+
+		ReplyTimeout: cfg.Timeouts.Chat,
+	})
+
+	// Safety screening for entries and model replies
+	inputSafety := safety.NewInputCheckers(cfg.Safety.Threshold)
+	outputSafety := safety.NewOutputCheckers(cfg.Safety.Threshold)
+	crisisResources, err := safety.LoadResources(cfg.Safety.ResourcesFile, cfg.Safety.DefaultLocale)
+	if err != nil {
+		log.Fatal("Failed to load crisis resources:", err)
+	}
+
+	// Start the analysis worker pool
+	notifier := services.NewAnalysisNotifier()
+	workerPool := services.NewAnalysisWorkerPool(database.DB, chat, notifier, outputSafety, services.WorkerPoolOptions{
+		Workers:      cfg.Analysis.Workers,
+		MaxAttempts:  cfg.Analysis.MaxAttempts,
+		PollInterval: cfg.Analysis.PollInterval,
+		RetryBase:    cfg.Analysis.RetryBase,
+		RetryMax:     cfg.Analysis.RetryMax,
+		StaleAfter:   cfg.Analysis.StaleAfter,
+		Timeout:      cfg.Timeouts.Analysis,
+		Index:        semanticIndex,
+
+		RefineEmotions: cfg.Analysis.RefineEmotions,
+	})
+	workerPool.Start()
+
+	// Write weekly and monthly reflection digests in the background
+	digestPeriods, err := services.ParseDigestPeriods(cfg.Digest.Periods)
+	if err != nil {
+		log.Fatal("Invalid DIGEST_PERIODS:", err)
+	}
+	digestScheduler := services.NewDigestScheduler(database.DB, chat, usageTracker, outputSafety, services.DigestOptions{
+		Periods:     digestPeriods,
+		Interval:    cfg.Digest.CheckInterval,
+		MinEntries:  cfg.Digest.MinEntries,
+		MaxAttempts: cfg.Digest.MaxAttempts,
+		Timeout:     cfg.Timeouts.Digest,
+	})
+	if len(digestPeriods) > 0 {
+		digestScheduler.Start()
+	}
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(database.DB)
-	journalHandler := handlers.NewJournalHandler(database.DB, chat)
+	authHandler := handlers.NewAuthHandler(database.DB, handlers.AuthOptions{
+		RefreshTTL:   cfg.Auth.RefreshTokenTTL,
+		Cookie:       cfg.Auth.RefreshCookie,
+		CookieSecure: cfg.Auth.CookieSecure,
+		Revocations:  revocations,
+	})
+	journalHandler := handlers.NewJournalHandler(database.DB, chat, workerPool, notifier, inputSafety, outputSafety, crisisResources, usageTracker, cfg.Timeouts.Request)
+	conversationHandler := handlers.NewConversationHandler(chat)
+	usageHandler := handlers.NewUsageHandler(usageTracker)
+	searchHandler := handlers.NewSearchHandler(semanticIndex)
+	digestHandler := handlers.NewDigestHandler(database.DB)
+	privacyHandler := handlers.NewPrivacyHandler(database.DB, redactor != nil, redactByDefault)
+	responseStyleHandler := handlers.NewResponseStyleHandler(database.DB, chat, usageTracker, inputSafety, outputSafety, crisisResources)
 
 	// Initialize rate limiter (60 requests per minute, burst of 10)
 	rateLimiter := middleware.NewRateLimiter(60, 10)
@@ -50,17 +212,59 @@ func main() {
 			http.Error(w, "Database connection failed", http.StatusServiceUnavailable)
 			return
 		}
+
+		// An open breaker degrades analysis but the API itself still works
+		breaker := provider.Breaker().Status()
+		status := "healthy"
+		if breaker.State != services.BreakerClosed {
+			status = "degraded"
+		}
+
+		health := map[string]interface{}{
+			"status": status,
+			"llm": map[string]interface{}{
+				"provider": provider.Name(),
+				"model":    provider.Model(),
+				"breaker":  breaker,
+			},
+		}
+		if analysisCache != nil {
+			health["analysis_cache"] = analysisCache.Stats()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"healthy"}`))
+		json.NewEncoder(w).Encode(health)
 	})
 
 	// Public routes
-	mux.HandleFunc("/register", authHandler.Register)
-	mux.HandleFunc("/login", authHandler.Login)
+	mux.Handle("/register", middleware.Timeout(cfg.Timeouts.Request, http.HandlerFunc(authHandler.Register)))
+	mux.Handle("/login", middleware.Timeout(cfg.Timeouts.Request, http.HandlerFunc(authHandler.Login)))
+	mux.Handle("/token/refresh", middleware.Timeout(cfg.Timeouts.Request, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		authHandler.RefreshToken(w, r)
+	})))
 
 	// Protected routes
-	mux.Handle("/profile", middleware.JWTMiddleware(http.HandlerFunc(authHandler.GetProfile)))
-	mux.Handle("/journal", middleware.JWTMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("/logout", middleware.JWTMiddleware(middleware.Timeout(cfg.Timeouts.Request, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		authHandler.Logout(w, r)
+	}))))
+	mux.Handle("/logout/all", middleware.JWTMiddleware(middleware.Timeout(cfg.Timeouts.Request, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		authHandler.LogoutEverywhere(w, r)
+	}))))
+	mux.Handle("/profile", middleware.JWTMiddleware(middleware.Timeout(cfg.Timeouts.Request, http.HandlerFunc(authHandler.GetProfile))))
+	mux.Handle("/journal", middleware.JWTMiddleware(middleware.Timeout(cfg.Timeouts.Request, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodPost:
 			journalHandler.CreateJournalEntry(w, r)
@@ -69,16 +273,94 @@ func main() {
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
+	}))))
+
+	// Conversation history routes, scoped to the authenticated user
+	mux.Handle("/conversation", middleware.JWTMiddleware(middleware.Timeout(cfg.Timeouts.Request, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			conversationHandler.GetConversationHistory(w, r)
+		case http.MethodDelete:
+			conversationHandler.ClearHistory(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))))
+
+	mux.Handle("/usage", middleware.JWTMiddleware(middleware.Timeout(cfg.Timeouts.Request, http.HandlerFunc(usageHandler.GetUsage))))
+
+	mux.Handle("/settings/privacy", middleware.JWTMiddleware(middleware.Timeout(cfg.Timeouts.Request, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			privacyHandler.GetSettings(w, r)
+		case http.MethodPut:
+			privacyHandler.UpdateSettings(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))))
+
+	// Companion response style. Previews are bounded by CHAT_TIMEOUT.
+	mux.Handle("/settings/response", middleware.JWTMiddleware(middleware.Timeout(cfg.Timeouts.Request, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			responseStyleHandler.GetSettings(w, r)
+		case http.MethodPut:
+			responseStyleHandler.UpdateSettings(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))))
+	mux.Handle("/settings/response/preview", middleware.JWTMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		responseStyleHandler.PreviewStyles(w, r)
 	})))
 
-	// Individual journal entry route
-	mux.Handle("/journal/", middleware.JWTMiddleware(http.HandlerFunc(journalHandler.GetJournalEntry)))
+	mux.Handle("/journal/search", middleware.JWTMiddleware(middleware.Timeout(cfg.Timeouts.Search, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		searchHandler.SearchEntries(w, r)
+	}))))
+
+	// Reflection digests
+	mux.Handle("/digests", middleware.JWTMiddleware(middleware.Timeout(cfg.Timeouts.Request, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		digestHandler.GetDigests(w, r)
+	}))))
+	mux.Handle("/digests/", middleware.JWTMiddleware(middleware.Timeout(cfg.Timeouts.Request, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		digestHandler.GetDigest(w, r)
+	}))))
+	mux.Handle("/settings/digests", middleware.JWTMiddleware(middleware.Timeout(cfg.Timeouts.Request, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			digestHandler.GetSettings(w, r)
+		case http.MethodPut:
+			digestHandler.UpdateSettings(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))))
+
+	// Individual journal entry routes set their own deadlines
+	mux.Handle("/journal/", middleware.JWTMiddleware(http.HandlerFunc(journalHandler.ServeEntry)))
 
 	// Setup CORS
 	c := cors.New(cors.Options{
 		AllowedOrigins:   cfg.AllowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", "Accept-Language"},
 		AllowCredentials: true,
 	})
 
@@ -95,16 +377,32 @@ func main() {
 		Handler: handler,
 	}
 
-	// Graceful shutdown
+	// Graceful shutdown: stop accepting requests, then drain in-flight analysis
+	shutdownComplete := make(chan struct{})
 	go func() {
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 		<-sigChan
 
 		log.Println("Shutting down server...")
-		if err := server.Close(); err != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+
+		if err := server.Shutdown(ctx); err != nil {
 			log.Printf("Error during server shutdown: %v", err)
 		}
+
+		log.Println("Draining analysis workers...")
+		if err := workerPool.Shutdown(ctx); err != nil {
+			log.Printf("Analysis workers did not finish before timeout: %v", err)
+		}
+		if err := digestScheduler.Shutdown(ctx); err != nil {
+			log.Printf("Digest scheduler did not stop before timeout: %v", err)
+		}
+		if err := revocations.Shutdown(ctx); err != nil {
+			log.Printf("Token revocation sync did not stop before timeout: %v", err)
+		}
+		close(shutdownComplete)
 	}()
 
 	fmt.Printf("Server starting on port %s\n", cfg.ServerPort)
@@ -114,5 +412,6 @@ func main() {
 		log.Fatal("Server failed to start:", err)
 	}
 
+	<-shutdownComplete
 	log.Println("Server stopped")
 }
\ No newline at end of file
@@ -13,6 +13,22 @@ type key int
 
 const UserKey key = 0
 
+// ClaimsKey holds the *auth.Claims of the request's access token.
+const ClaimsKey key = 1
+
+// RevocationChecker reports whether a validly signed token was revoked.
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, claims *auth.Claims) (bool, error)
+}
+
+var revocations RevocationChecker
+
+// UseRevocations makes JWTMiddleware reject tokens that checker reports as
+// revoked.
+func UseRevocations(checker RevocationChecker) {
+	revocations = checker
+}
+
 func JWTMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
@@ -33,7 +49,20 @@ func JWTMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		if revocations != nil {
+			revoked, err := revocations.IsRevoked(r.Context(), claims)
+			if err != nil {
+				utils.WriteError(w, http.StatusServiceUnavailable, "Unable to verify token, please try again")
+				return
+			}
+			if revoked {
+				utils.WriteError(w, http.StatusUnauthorized, "Token has been revoked")
+				return
+			}
+		}
+
 		ctx := context.WithValue(r.Context(), UserKey, claims.UserID)
+		ctx = context.WithValue(ctx, ClaimsKey, claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
\ No newline at end of file
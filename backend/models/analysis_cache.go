@@ -0,0 +1,48 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// GetCachedAnalysis returns the unexpired cached analysis stored under key
+// for the user, or nil when there is none.
+func GetCachedAnalysis(ctx context.Context, db *sql.DB, userID int, key string) (*AnalysisResult, error) {
+	var result AnalysisResult
+	err := db.QueryRowContext(ctx, `
+		SELECT result FROM analysis_cache
+		WHERE cache_key = $1 AND user_id = $2 AND expires_at > NOW()`,
+		key, userID,
+	).Scan(&result)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SaveCachedAnalysis stores result under key until ttl has passed,
+// replacing any earlier entry.
+func SaveCachedAnalysis(ctx context.Context, db *sql.DB, userID int, key string, result *AnalysisResult, ttl time.Duration) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO analysis_cache (cache_key, user_id, result, created_at, expires_at)
+		VALUES ($1, $2, $3, NOW(), NOW() + $4 * INTERVAL '1 second')
+		ON CONFLICT (cache_key) DO UPDATE
+		SET result = EXCLUDED.result, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at`,
+		key, userID, result, ttl.Seconds(),
+	)
+	return err
+}
+
+// DeleteExpiredCachedAnalyses removes expired cache rows and returns how
+// many were deleted.
+func DeleteExpiredCachedAnalyses(ctx context.Context, db *sql.DB) (int64, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM analysis_cache WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
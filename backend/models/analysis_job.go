@@ -0,0 +1,164 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Analysis status values stored on journals.analysis_status. Entries saved
+// after the user's quota ran out are "skipped" and never analyzed.
+const (
+	AnalysisPending = "pending"
+	AnalysisRunning = "running"
+	AnalysisDone    = "done"
+	AnalysisFailed  = "failed"
+	AnalysisSkipped = "skipped"
+)
+
+// Job status values stored on analysis_jobs.status. Jobs that exhaust their
+// attempts are kept with status "dead" as the dead-letter queue.
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobDead    = "dead"
+)
+
+var ErrNoJobs = errors.New("no analysis jobs available")
+
+type AnalysisJob struct {
+	ID          int            `json:"id"`
+	JournalID   int            `json:"journal_id"`
+	UserID      int            `json:"user_id"`
+	Status      string         `json:"status"`
+	Attempts    int            `json:"attempts"`
+	MaxAttempts int            `json:"max_attempts"`
+	BypassCache bool           `json:"bypass_cache"`
+	RunAt       time.Time      `json:"run_at"`
+	LastError   sql.NullString `json:"-"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// CreateEntryWithJob saves a pending journal entry and queues its analysis in
+// one transaction, so an entry is never left without a job.
+func CreateEntryWithJob(ctx context.Context, db *sql.DB, entry *JournalEntry, maxAttempts int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	entry.AnalysisStatus = AnalysisPending
+	if err := entry.insert(ctx, tx); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO analysis_jobs (journal_id, user_id, max_attempts) VALUES ($1, $2, $3)`,
+		entry.ID, entry.UserID, maxAttempts,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ClaimAnalysisJob locks the next runnable job for this worker. Jobs left
+// running for longer than staleAfter (e.g. after a crash) are reclaimed.
+func ClaimAnalysisJob(ctx context.Context, db *sql.DB, staleAfter time.Duration) (*AnalysisJob, error) {
+	query := `
+		UPDATE analysis_jobs
+		SET status = 'running', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM analysis_jobs
+			WHERE (status = 'queued' AND run_at <= NOW())
+			   OR (status = 'running' AND locked_at < NOW() - $1 * INTERVAL '1 second')
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, journal_id, user_id, status, attempts, max_attempts, bypass_cache, run_at, last_error, created_at, updated_at`
+
+	var job AnalysisJob
+	err := db.QueryRowContext(ctx, query, staleAfter.Seconds()).Scan(
+		&job.ID, &job.JournalID, &job.UserID, &job.Status, &job.Attempts, &job.MaxAttempts, &job.BypassCache,
+		&job.RunAt, &job.LastError, &job.CreatedAt, &job.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNoJobs
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ClaimAnalysisJobForEntry locks the queued job of a specific entry, e.g. so
+// a streaming request can run it in place of a worker.
+func ClaimAnalysisJobForEntry(ctx context.Context, db *sql.DB, journalID int) (*AnalysisJob, error) {
+	query := `
+		UPDATE analysis_jobs
+		SET status = 'running', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM analysis_jobs
+			WHERE journal_id = $1 AND status = 'queued'
+			ORDER BY id DESC
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, journal_id, user_id, status, attempts, max_attempts, bypass_cache, run_at, last_error, created_at, updated_at`
+
+	var job AnalysisJob
+	err := db.QueryRowContext(ctx, query, journalID).Scan(
+		&job.ID, &job.JournalID, &job.UserID, &job.Status, &job.Attempts, &job.MaxAttempts, &job.BypassCache,
+		&job.RunAt, &job.LastError, &job.CreatedAt, &job.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNoJobs
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ReleaseAnalysisJob returns a claimed job to the queue without counting the
+// attempt, for work that was abandoned rather than failed.
+func ReleaseAnalysisJob(ctx context.Context, db *sql.DB, jobID int) error {
+	query := `
+		UPDATE analysis_jobs
+		SET status = 'queued', attempts = GREATEST(attempts - 1, 0), run_at = NOW(), locked_at = NULL, updated_at = NOW()
+		WHERE id = $1`
+	_, err := db.ExecContext(ctx, query, jobID)
+	return err
+}
+
+func CompleteAnalysisJob(ctx context.Context, db *sql.DB, jobID int) error {
+	query := `UPDATE analysis_jobs SET status = 'done', locked_at = NULL, updated_at = NOW() WHERE id = $1`
+	_, err := db.ExecContext(ctx, query, jobID)
+	return err
+}
+
+// RetryAnalysisJob puts a failed job back in the queue to run at runAt.
+func RetryAnalysisJob(ctx context.Context, db *sql.DB, jobID int, runAt time.Time, lastError string) error {
+	query := `
+		UPDATE analysis_jobs
+		SET status = 'queued', run_at = $2, last_error = $3, locked_at = NULL, updated_at = NOW()
+		WHERE id = $1`
+	_, err := db.ExecContext(ctx, query, jobID, runAt, lastError)
+	return err
+}
+
+// DeadLetterAnalysisJob parks a job that exhausted its attempts.
+func DeadLetterAnalysisJob(ctx context.Context, db *sql.DB, jobID int, lastError string) error {
+	query := `
+		UPDATE analysis_jobs
+		SET status = 'dead', last_error = $2, locked_at = NULL, updated_at = NOW()
+		WHERE id = $1`
+	_, err := db.ExecContext(ctx, query, jobID, lastError)
+	return err
+}
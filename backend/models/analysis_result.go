@@ -0,0 +1,42 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+type EmotionIntensity struct {
+	Emotion   string  `json:"emotion"`
+	Intensity float64 `json:"intensity"`
+}
+
+// AnalysisResult is the structured output the model is asked to produce for
+// a journal entry. It is stored as JSONB in journals.structured_analysis.
+type AnalysisResult struct {
+	SentimentScore        float64            `json:"sentiment_score"`
+	PrimaryEmotions       []EmotionIntensity `json:"primary_emotions"`
+	Themes                []string           `json:"themes"`
+	SupportiveMessage     string             `json:"supportive_message"`
+	ReflectionSuggestions []string           `json:"reflection_suggestions"`
+}
+
+// Value implements driver.Valuer so results can be written to JSONB columns.
+func (r *AnalysisResult) Value() (driver.Value, error) {
+	if r == nil {
+		return nil, nil
+	}
+	return json.Marshal(r)
+}
+
+// Scan implements sql.Scanner for JSONB columns.
+func (r *AnalysisResult) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	default:
+		return errors.New("unsupported type for AnalysisResult")
+	}
+}
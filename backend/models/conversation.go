@@ -0,0 +1,223 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Conversation is either a user's analysis conversation (JournalID nil) or
+// a chat about one journal entry. Summary condenses the messages up to and
+// including SummarizedThrough, which have dropped out of the context window.
+type Conversation struct {
+	ID                int       `json:"id"`
+	UserID            int       `json:"user_id"`
+	JournalID         *int      `json:"journal_id,omitempty"`
+	Summary           string    `json:"summary,omitempty"`
+	SummarizedThrough int       `json:"-"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+type ConversationMessage struct {
+	ID             int       `json:"id"`
+	ConversationID int       `json:"conversation_id"`
+	Role           string    `json:"role"`
+	Content        string    `json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// conversationColumns is the column list read by scanConversation.
+const conversationColumns = `id, user_id, journal_id, summary, summarized_through, created_at, updated_at`
+
+func scanConversation(row rowScanner) (*Conversation, error) {
+	var conv Conversation
+	var journalID sql.NullInt64
+	var summary sql.NullString
+	err := row.Scan(&conv.ID, &conv.UserID, &journalID, &summary, &conv.SummarizedThrough,
+		&conv.CreatedAt, &conv.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if journalID.Valid {
+		id := int(journalID.Int64)
+		conv.JournalID = &id
+	}
+	conv.Summary = summary.String
+	return &conv, nil
+}
+
+// GetOrCreateConversation returns the user's conversation thread, creating it
+// on first use.
+func GetOrCreateConversation(ctx context.Context, db *sql.DB, userID int) (*Conversation, error) {
+	query := `
+		INSERT INTO conversations (user_id, created_at, updated_at)
+		VALUES ($1, NOW(), NOW())
+		ON CONFLICT (user_id) WHERE journal_id IS NULL DO UPDATE SET updated_at = NOW()
+		RETURNING ` + conversationColumns
+
+	return scanConversation(db.QueryRowContext(ctx, query, userID))
+}
+
+func GetConversationByUser(ctx context.Context, db *sql.DB, userID int) (*Conversation, error) {
+	query := `SELECT ` + conversationColumns + ` FROM conversations WHERE user_id = $1 AND journal_id IS NULL`
+
+	conv, err := scanConversation(db.QueryRowContext(ctx, query, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("conversation not found")
+		}
+		return nil, err
+	}
+	return conv, nil
+}
+
+// GetOrCreateEntryConversation returns the chat about a journal entry,
+// creating it on first use.
+func GetOrCreateEntryConversation(ctx context.Context, db *sql.DB, userID, journalID int) (*Conversation, error) {
+	query := `
+		INSERT INTO conversations (user_id, journal_id, created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW())
+		ON CONFLICT (journal_id) WHERE journal_id IS NOT NULL DO UPDATE SET updated_at = NOW()
+		RETURNING ` + conversationColumns
+
+	return scanConversation(db.QueryRowContext(ctx, query, userID, journalID))
+}
+
+func GetEntryConversation(ctx context.Context, db *sql.DB, journalID int) (*Conversation, error) {
+	query := `SELECT ` + conversationColumns + ` FROM conversations WHERE journal_id = $1`
+
+	conv, err := scanConversation(db.QueryRowContext(ctx, query, journalID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("conversation not found")
+		}
+		return nil, err
+	}
+	return conv, nil
+}
+
+// UpdateConversationSummary replaces the summary, which now covers every
+// message up to and including throughID.
+func UpdateConversationSummary(ctx context.Context, db *sql.DB, conversationID int, summary string, throughID int) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE conversations SET summary = $1, summarized_through = $2, updated_at = NOW()
+		WHERE id = $3`,
+		summary, throughID, conversationID,
+	)
+	return err
+}
+
+// GetRecentMessages returns the newest limit messages of a conversation in
+// chronological order.
+func GetRecentMessages(ctx context.Context, db *sql.DB, conversationID, limit int) ([]ConversationMessage, error) {
+	query := `
+		SELECT id, conversation_id, role, content, created_at
+		FROM (
+			SELECT id, conversation_id, role, content, created_at
+			FROM messages
+			WHERE conversation_id = $1
+			ORDER BY id DESC
+			LIMIT $2
+		) recent
+		ORDER BY id ASC`
+
+	rows, err := db.QueryContext(ctx, query, conversationID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []ConversationMessage
+	for rows.Next() {
+		var m ConversationMessage
+		if err := rows.Scan(&m.ID, &m.ConversationID, &m.Role, &m.Content, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// GetMessagesAfter returns the messages of a conversation with an ID
+// greater than afterID, in chronological order.
+func GetMessagesAfter(ctx context.Context, db *sql.DB, conversationID, afterID int) ([]ConversationMessage, error) {
+	query := `
+		SELECT id, conversation_id, role, content, created_at
+		FROM messages
+		WHERE conversation_id = $1 AND id > $2
+		ORDER BY id ASC`
+
+	rows, err := db.QueryContext(ctx, query, conversationID, afterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []ConversationMessage
+	for rows.Next() {
+		var m ConversationMessage
+		if err := rows.Scan(&m.ID, &m.ConversationID, &m.Role, &m.Content, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// AppendMessages stores messages and, when keep is positive, trims the
+// conversation to its newest keep messages in a single transaction. The
+// stored messages are returned with their IDs and timestamps.
+func AppendMessages(ctx context.Context, db *sql.DB, conversationID, keep int, messages ...ConversationMessage) ([]ConversationMessage, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stored := make([]ConversationMessage, 0, len(messages))
+	for _, m := range messages {
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO messages (conversation_id, role, content, created_at) VALUES ($1, $2, $3, NOW())
+			RETURNING id, created_at`,
+			conversationID, m.Role, m.Content,
+		).Scan(&m.ID, &m.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		m.ConversationID = conversationID
+		stored = append(stored, m)
+	}
+
+	if keep > 0 {
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM messages
+			WHERE conversation_id = $1 AND id NOT IN (
+				SELECT id FROM messages WHERE conversation_id = $1 ORDER BY id DESC LIMIT $2
+			)`, conversationID, keep)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE conversations SET updated_at = NOW() WHERE id = $1`, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// ClearUserConversation deletes every message in the user's analysis
+// conversation. Chats about individual entries are kept.
+func ClearUserConversation(ctx context.Context, db *sql.DB, userID int) error {
+	query := `
+		DELETE FROM messages
+		WHERE conversation_id IN (SELECT id FROM conversations WHERE user_id = $1 AND journal_id IS NULL)`
+	_, err := db.ExecContext(ctx, query, userID)
+	return err
+}
@@ -0,0 +1,267 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Digest periods.
+const (
+	DigestWeekly  = "weekly"
+	DigestMonthly = "monthly"
+)
+
+// Digest statuses. A pending row is a claim by the scheduler instance
+// generating it, so restarts and concurrent instances never produce two
+// digests for the same user and period. A failed row counts its attempts
+// and is claimed again until they run out.
+const (
+	DigestPending = "pending"
+	DigestDone    = "done"
+	DigestFailed  = "failed"
+)
+
+// ErrDigestClaimed is returned when a digest already exists or another
+// scheduler is generating it.
+var ErrDigestClaimed = errors.New("digest already claimed")
+
+// DigestContent is the reflection the model writes for a period. It is
+// stored as JSONB in digests.content.
+type DigestContent struct {
+	Summary        string   `json:"summary"`
+	Themes         []string `json:"themes"`
+	MoodTrajectory string   `json:"mood_trajectory"`
+	Wins           []string `json:"wins"`
+	Suggestions    []string `json:"suggestions"`
+}
+
+// Value implements driver.Valuer so content can be written to JSONB columns.
+func (c *DigestContent) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return json.Marshal(c)
+}
+
+// Scan implements sql.Scanner for JSONB columns.
+func (c *DigestContent) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	default:
+		return errors.New("unsupported type for DigestContent")
+	}
+}
+
+// Digest is a reflection on a user's entries over a week or month.
+// PeriodEnd is the last day covered.
+type Digest struct {
+	ID               int            `json:"id"`
+	UserID           int            `json:"user_id"`
+	Period           string         `json:"period"`
+	PeriodStart      time.Time      `json:"period_start"`
+	PeriodEnd        time.Time      `json:"period_end"`
+	Content          *DigestContent `json:"content"`
+	EntryCount       int            `json:"entry_count"`
+	AverageSentiment *float64       `json:"average_sentiment,omitempty"`
+	PromptVersion    string         `json:"prompt_version,omitempty"`
+	Model            string         `json:"model,omitempty"`
+	CreatedAt        time.Time      `json:"created_at"`
+}
+
+const digestColumns = `id, user_id, period, period_start, period_end, content, entry_count,
+	average_sentiment, prompt_version, model, created_at`
+
+func scanDigest(row rowScanner) (*Digest, error) {
+	var d Digest
+	var promptVersion, model sql.NullString
+	err := row.Scan(&d.ID, &d.UserID, &d.Period, &d.PeriodStart, &d.PeriodEnd, &d.Content,
+		&d.EntryCount, &d.AverageSentiment, &promptVersion, &model, &d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	d.PromptVersion = promptVersion.String
+	d.Model = model.String
+	return &d, nil
+}
+
+// ClaimDigest reserves the digest of a user's period, counting an attempt,
+// and returns its ID. A failed digest with attempts left, or a pending
+// claim older than staleAfter left by a scheduler that stopped mid-way, is
+// taken over; otherwise ErrDigestClaimed is returned.
+func ClaimDigest(ctx context.Context, db *sql.DB, userID int, period string, start, end time.Time, staleAfter time.Duration, maxAttempts int) (int, error) {
+	var id int
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO digests (user_id, period, period_start, period_end, status, attempts, claimed_at, created_at)
+		VALUES ($1, $2, $3, $4, 'pending', 1, NOW(), NOW())
+		ON CONFLICT (user_id, period, period_start) DO UPDATE
+		SET status = 'pending', attempts = digests.attempts + 1, claimed_at = NOW()
+		WHERE digests.attempts < $6
+		  AND (digests.status = 'failed'
+		       OR (digests.status = 'pending' AND digests.claimed_at < NOW() - $5 * INTERVAL '1 second'))
+		RETURNING id`,
+		userID, period, start, end, staleAfter.Seconds(), maxAttempts,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrDigestClaimed
+	}
+	return id, err
+}
+
+// CompleteDigest stores the generated content of a claimed digest.
+func CompleteDigest(ctx context.Context, db *sql.DB, d *Digest) error {
+	return db.QueryRowContext(ctx, `
+		UPDATE digests
+		SET content = $2, entry_count = $3, average_sentiment = $4,
+		    prompt_version = $5, model = $6, status = 'done', created_at = NOW()
+		WHERE id = $1
+		RETURNING created_at`,
+		d.ID, d.Content, d.EntryCount, d.AverageSentiment,
+		nullString(d.PromptVersion), nullString(d.Model),
+	).Scan(&d.CreatedAt)
+}
+
+// FailDigest records that a claimed digest couldn't be written. It is
+// tried again on the next run while it has attempts left.
+func FailDigest(ctx context.Context, db *sql.DB, id int, lastError string) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE digests SET status = 'failed', last_error = $2
+		WHERE id = $1 AND status = 'pending'`,
+		id, lastError,
+	)
+	return err
+}
+
+// ReleaseDigest gives up a claim without counting the attempt, for work
+// that was abandoned rather than failed, so the next run picks it up.
+func ReleaseDigest(ctx context.Context, db *sql.DB, id int) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE digests SET status = 'failed', attempts = GREATEST(attempts - 1, 0)
+		WHERE id = $1 AND status = 'pending'`,
+		id,
+	)
+	return err
+}
+
+// FindDigestCandidates returns users who haven't opted out of digests,
+// wrote at least minEntries entries in [start, end) that weren't answered
+// with crisis resources, and have no digest for the period yet nor one that
+// used up maxAttempts.
+func FindDigestCandidates(ctx context.Context, db *sql.DB, period string, start, end time.Time, minEntries, maxAttempts int) ([]int, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT j.user_id
+		FROM journals j
+		JOIN users u ON u.id = j.user_id
+		WHERE j.created_at >= $2 AND j.created_at < $3
+		  AND j.risk_source IS DISTINCT FROM 'entry'
+		  AND NOT u.digest_opt_out
+		  AND NOT EXISTS (
+		      SELECT 1 FROM digests d
+		      WHERE d.user_id = j.user_id AND d.period = $1 AND d.period_start = $2
+		        AND (d.status = 'done' OR d.attempts >= $5))
+		GROUP BY j.user_id
+		HAVING COUNT(*) >= $4
+		ORDER BY j.user_id`,
+		period, start, end, minEntries, maxAttempts,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, id)
+	}
+	return userIDs, rows.Err()
+}
+
+// GetEntriesInRange returns the user's entries written in [start, end),
+// oldest first.
+func GetEntriesInRange(ctx context.Context, db *sql.DB, userID int, start, end time.Time) ([]JournalEntry, error) {
+	query := `
+		SELECT ` + journalColumns + `
+		FROM journals
+		WHERE user_id = $1 AND created_at >= $2 AND created_at < $3
+		ORDER BY created_at`
+
+	return queryEntries(ctx, db, query, userID, start, end)
+}
+
+// GetDigestsByUser returns the user's finished digests, newest period first.
+func GetDigestsByUser(ctx context.Context, db *sql.DB, userID, limit, offset int) ([]Digest, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+digestColumns+`
+		FROM digests
+		WHERE user_id = $1 AND status = 'done'
+		ORDER BY period_start DESC, period
+		LIMIT $2 OFFSET $3`,
+		userID, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	digests := []Digest{}
+	for rows.Next() {
+		d, err := scanDigest(rows)
+		if err != nil {
+			return nil, err
+		}
+		digests = append(digests, *d)
+	}
+	return digests, rows.Err()
+}
+
+func GetDigestByID(ctx context.Context, db *sql.DB, digestID, userID int) (*Digest, error) {
+	row := db.QueryRowContext(ctx, `
+		SELECT `+digestColumns+`
+		FROM digests
+		WHERE id = $1 AND user_id = $2 AND status = 'done'`,
+		digestID, userID,
+	)
+	d, err := scanDigest(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("digest not found")
+		}
+		return nil, err
+	}
+	return d, nil
+}
+
+// GetDigestOptOut reports whether the user has opted out of digests.
+func GetDigestOptOut(ctx context.Context, db *sql.DB, userID int) (bool, error) {
+	var optOut bool
+	err := db.QueryRowContext(ctx, `SELECT digest_opt_out FROM users WHERE id = $1`, userID).Scan(&optOut)
+	if err == sql.ErrNoRows {
+		return false, errors.New("user not found")
+	}
+	return optOut, err
+}
+
+func SetDigestOptOut(ctx context.Context, db *sql.DB, userID int, optOut bool) error {
+	result, err := db.ExecContext(ctx, `UPDATE users SET digest_opt_out = $2, updated_at = NOW() WHERE id = $1`, userID, optOut)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/lib/pq"
+)
+
+// EntryEmbedding is the stored vector of one journal entry.
+type EntryEmbedding struct {
+	JournalID int
+	Vector    []float32
+}
+
+// SaveEntryEmbedding stores the vector of an entry, replacing any earlier
+// one.
+func SaveEntryEmbedding(ctx context.Context, db *sql.DB, journalID, userID int, model string, vector []float32) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO journal_embeddings (journal_id, user_id, model, dimensions, vector, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (journal_id) DO UPDATE
+		SET model = EXCLUDED.model, dimensions = EXCLUDED.dimensions,
+		    vector = EXCLUDED.vector, created_at = EXCLUDED.created_at`,
+		journalID, userID, model, len(vector), encodeVector(vector),
+	)
+	return err
+}
+
+// HasEntryEmbedding reports whether the entry has a vector from model.
+func HasEntryEmbedding(ctx context.Context, db *sql.DB, journalID int, model string) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM journal_embeddings WHERE journal_id = $1 AND model = $2)`,
+		journalID, model,
+	).Scan(&exists)
+	return exists, err
+}
+
+// GetUserEmbeddings returns the vectors of every entry of the user that was
+// embedded with model.
+func GetUserEmbeddings(ctx context.Context, db *sql.DB, userID int, model string) ([]EntryEmbedding, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT journal_id, vector FROM journal_embeddings
+		WHERE user_id = $1 AND model = $2`,
+		userID, model,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var embeddings []EntryEmbedding
+	for rows.Next() {
+		var e EntryEmbedding
+		var raw []byte
+		if err := rows.Scan(&e.JournalID, &raw); err != nil {
+			return nil, err
+		}
+		if e.Vector, err = decodeVector(raw); err != nil {
+			return nil, fmt.Errorf("entry %d: %v", e.JournalID, err)
+		}
+		embeddings = append(embeddings, e)
+	}
+	return embeddings, rows.Err()
+}
+
+// GetEntriesWithoutEmbedding returns up to limit of the user's entries that
+// have no vector from model, newest first.
+func GetEntriesWithoutEmbedding(ctx context.Context, db *sql.DB, userID int, model string, limit int) ([]JournalEntry, error) {
+	query := `
+		SELECT ` + journalColumns + `
+		FROM journals j
+		WHERE user_id = $1
+		  AND NOT EXISTS (
+		      SELECT 1 FROM journal_embeddings e
+		      WHERE e.journal_id = j.id AND e.model = $2)
+		ORDER BY created_at DESC
+		LIMIT $3`
+
+	return queryEntries(ctx, db, query, userID, model, limit)
+}
+
+// GetEntriesByIDs returns the user's entries with the given IDs, with their
+// emotions, in no particular order. IDs of other users' entries are ignored.
+func GetEntriesByIDs(ctx context.Context, db *sql.DB, userID int, ids []int) ([]JournalEntry, error) {
+	query := `
+		SELECT ` + journalColumns + `
+		FROM journals
+		WHERE user_id = $1 AND id = ANY($2)`
+
+	entries, err := queryEntries(ctx, db, query, userID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	if err := LoadEntryEmotions(ctx, db, entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func queryEntries(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]JournalEntry, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []JournalEntry
+	for rows.Next() {
+		entry, err := scanJournalEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, rows.Err()
+}
+
+// Vectors are stored as little-endian float32s.
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) ([]float32, error) {
+	if len(buf)%4 != 0 {
+		return nil, fmt.Errorf("invalid vector length %d", len(buf))
+	}
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return v, nil
+}
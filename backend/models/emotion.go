@@ -0,0 +1,125 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Where an entry's emotion scores came from: the offline lexicon alone, or
+// the lexicon refined with the emotions the model named in its analysis.
+const (
+	EmotionSourceLexicon = "lexicon"
+	EmotionSourceModel   = "model"
+)
+
+// EntryEmotion is the intensity, from 0 to 1, of one Plutchik emotion in an
+// entry. Rows are stored in entry_emotions.
+type EntryEmotion struct {
+	Emotion   string  `json:"emotion"`
+	Intensity float64 `json:"intensity"`
+	Source    string  `json:"source"`
+}
+
+// EmotionFilter selects entries expressing any of Emotions at
+// MinIntensity or above.
+type EmotionFilter struct {
+	Emotions     []string
+	MinIntensity float64
+}
+
+// insertEntryEmotions replaces the entry's emotion rows inside tx.
+func insertEntryEmotions(ctx context.Context, tx *sql.Tx, entryID int, emotions []EntryEmotion) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM entry_emotions WHERE journal_id = $1`, entryID); err != nil {
+		return err
+	}
+
+	for _, e := range emotions {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO entry_emotions (journal_id, emotion, intensity, source)
+			VALUES ($1, $2, $3, $4)`,
+			entryID, e.Emotion, e.Intensity, e.Source,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveEntryEmotions replaces the stored emotion scores of an entry.
+func SaveEntryEmotions(ctx context.Context, db *sql.DB, entryID int, emotions []EntryEmotion) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertEntryEmotions(ctx, tx, entryID, emotions); err != nil {
+		return fmt.Errorf("error saving emotions for entry %d: %v", entryID, err)
+	}
+	return tx.Commit()
+}
+
+// LoadEntryEmotions fills in the Emotions of each entry, strongest first.
+func LoadEntryEmotions(ctx context.Context, db *sql.DB, entries []JournalEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(entries))
+	byID := make(map[int]*JournalEntry, len(entries))
+	for i := range entries {
+		ids[i] = int64(entries[i].ID)
+		byID[entries[i].ID] = &entries[i]
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT journal_id, emotion, intensity, source
+		FROM entry_emotions
+		WHERE journal_id = ANY($1)
+		ORDER BY journal_id, intensity DESC, emotion`,
+		pq.Array(ids),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entryID int
+		var e EntryEmotion
+		if err := rows.Scan(&entryID, &e.Emotion, &e.Intensity, &e.Source); err != nil {
+			return err
+		}
+		if entry, ok := byID[entryID]; ok {
+			entry.Emotions = append(entry.Emotions, e)
+		}
+	}
+	return rows.Err()
+}
+
+// GetEntriesByEmotion returns a page of the user's entries, newest first,
+// that express any of the filter's emotions strongly enough.
+func GetEntriesByEmotion(ctx context.Context, db *sql.DB, userID int, filter EmotionFilter, limit, offset int) ([]JournalEntry, error) {
+	query := `
+		SELECT ` + journalColumns + `
+		FROM journals
+		WHERE user_id = $1 AND EXISTS (
+			SELECT 1 FROM entry_emotions e
+			WHERE e.journal_id = journals.id AND e.emotion = ANY($2) AND e.intensity >= $3
+		)
+		ORDER BY created_at DESC
+		LIMIT $4 OFFSET $5`
+
+	entries, err := queryEntries(ctx, db, query, userID, pq.Array(filter.Emotions), filter.MinIntensity, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	if err := LoadEntryEmotions(ctx, db, entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrAnalysisQueued is returned when an entry already has an analysis
+// queued or running.
+var ErrAnalysisQueued = errors.New("analysis already queued")
+
+// JournalAnalysis is one completed analysis of an entry. Entries keep every
+// analysis; Current marks the one shown on the entry.
+type JournalAnalysis struct {
+	ID                 int             `json:"id"`
+	JournalID          int             `json:"journal_id"`
+	Analysis           string          `json:"analysis"`
+	StructuredAnalysis *AnalysisResult `json:"structured_analysis,omitempty"`
+	SentimentScore     *float64        `json:"sentiment_score,omitempty"`
+	PromptVersion      string          `json:"prompt_version,omitempty"`
+	Model              string          `json:"model,omitempty"`
+	Current            bool            `json:"current"`
+	CreatedAt          time.Time       `json:"created_at"`
+}
+
+const journalAnalysisColumns = `a.id, a.journal_id, a.analysis, a.structured_analysis, a.sentiment_score,
+	a.prompt_version, a.model, a.id = j.current_analysis_id, a.created_at`
+
+func scanJournalAnalysis(row rowScanner) (*JournalAnalysis, error) {
+	var a JournalAnalysis
+	var analysis, promptVersion, model sql.NullString
+	var current sql.NullBool
+	err := row.Scan(&a.ID, &a.JournalID, &analysis, &a.StructuredAnalysis, &a.SentimentScore,
+		&promptVersion, &model, &current, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	a.Analysis = analysis.String
+	a.PromptVersion = promptVersion.String
+	a.Model = model.String
+	a.Current = current.Bool
+	return &a, nil
+}
+
+// GetEntryAnalyses returns the analyses of an entry, newest first.
+func GetEntryAnalyses(ctx context.Context, db *sql.DB, journalID int) ([]JournalAnalysis, error) {
+	query := `
+		SELECT ` + journalAnalysisColumns + `
+		FROM journal_analyses a
+		JOIN journals j ON j.id = a.journal_id
+		WHERE a.journal_id = $1
+		ORDER BY a.created_at DESC, a.id DESC`
+
+	rows, err := db.QueryContext(ctx, query, journalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	analyses := []JournalAnalysis{}
+	for rows.Next() {
+		a, err := scanJournalAnalysis(rows)
+		if err != nil {
+			return nil, err
+		}
+		analyses = append(analyses, *a)
+	}
+	return analyses, rows.Err()
+}
+
+func GetEntryAnalysis(ctx context.Context, db *sql.DB, journalID, analysisID int) (*JournalAnalysis, error) {
+	query := `
+		SELECT ` + journalAnalysisColumns + `
+		FROM journal_analyses a
+		JOIN journals j ON j.id = a.journal_id
+		WHERE a.journal_id = $1 AND a.id = $2`
+
+	a, err := scanJournalAnalysis(db.QueryRowContext(ctx, query, journalID, analysisID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("analysis not found")
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
+// QueueReanalysis queues a fresh analysis of an existing entry. The entry
+// keeps its current analysis until the new one completes. With bypassCache
+// the job ignores any cached analysis and replaces it with the new one. It
+// returns ErrAnalysisQueued if the entry already has a queued or running
+// job.
+func QueueReanalysis(ctx context.Context, db *sql.DB, entry *JournalEntry, maxAttempts int, bypassCache bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The partial unique index on active jobs makes this a no-op when one exists
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO analysis_jobs (journal_id, user_id, max_attempts, bypass_cache) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (journal_id) WHERE status IN ('queued', 'running') DO NOTHING`,
+		entry.ID, entry.UserID, maxAttempts, bypassCache,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrAnalysisQueued
+	}
+
+	entry.AnalysisStatus = AnalysisPending
+	_, err = tx.ExecContext(ctx, `UPDATE journals SET analysis_status = $2, updated_at = NOW() WHERE id = $1`,
+		entry.ID, entry.AnalysisStatus)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReanalysisFilter selects entries for bulk re-analysis. Zero values don't
+// filter.
+type ReanalysisFilter struct {
+	UserID int
+	Since  time.Time
+
+	// StalePromptVersion and StaleModel select entries whose current
+	// analysis came from a different prompt version or model. Language
+	// variants of the prompt version ("analysis@v3.es") count as current.
+	StalePromptVersion string
+	StaleModel         string
+
+	Limit int
+}
+
+// FindEntriesForReanalysis returns entries matching filter that have been
+// analyzed, or skipped or failed, and aren't waiting on a job already.
+// Entries answered with crisis resources are never sent to the model.
+func FindEntriesForReanalysis(ctx context.Context, db *sql.DB, filter ReanalysisFilter) ([]JournalEntry, error) {
+	query := `
+		SELECT ` + journalColumns + `
+		FROM journals
+		WHERE analysis_status IN ('done', 'failed', 'skipped')
+		  AND risk_source IS DISTINCT FROM 'entry'
+		  AND ($1 = 0 OR user_id = $1)
+		  AND ($2::timestamp IS NULL OR created_at >= $2)
+		  AND (($3 = '' AND $4 = '')
+		       OR ($3 <> '' AND prompt_version IS DISTINCT FROM $3 AND COALESCE(prompt_version, '') NOT LIKE $3 || '.%')
+		       OR ($4 <> '' AND model IS DISTINCT FROM $4))
+		ORDER BY id
+		LIMIT $5`
+
+	var since sql.NullTime
+	if !filter.Since.IsZero() {
+		since = sql.NullTime{Time: filter.Since, Valid: true}
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 1000
+	}
+
+	rows, err := db.QueryContext(ctx, query, filter.UserID, since, filter.StalePromptVersion, filter.StaleModel, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []JournalEntry
+	for rows.Next() {
+		entry, err := scanJournalEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, rows.Err()
+}
@@ -1,53 +1,180 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type JournalEntry struct {
-	ID        int       `json:"id"`
-	Content   string    `json:"content"`
-	UserID    int       `json:"user_id"`
-	Analysis  string    `json:"analysis,omitempty"`
-	Sentiment string    `json:"sentiment,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                 int                 `json:"id"`
+	Content            string              `json:"content"`
+	UserID             int                 `json:"user_id"`
+	Language           string              `json:"language,omitempty"`
+	Analysis           string              `json:"analysis,omitempty"`
+	Sentiment          string              `json:"sentiment,omitempty"`
+	SentimentScores    *SentimentBreakdown `json:"sentiment_scores,omitempty"`
+	SentimentScore     *float64            `json:"sentiment_score,omitempty"`
+	StructuredAnalysis *AnalysisResult     `json:"structured_analysis,omitempty"`
+	AnalysisStatus     string              `json:"analysis_status"`
+	RiskFlagged        bool                `json:"risk_flagged"`
+	RiskLevel          string              `json:"risk_level,omitempty"`
+	RiskReasons        []string            `json:"risk_reasons,omitempty"`
+	RiskSource         string              `json:"risk_source,omitempty"`
+	InjectionFlags     []string            `json:"injection_flags,omitempty"`
+	PromptVersion      string              `json:"prompt_version,omitempty"`
+	Model              string              `json:"model,omitempty"`
+	CurrentAnalysisID  *int                `json:"current_analysis_id,omitempty"`
+	Emotions           []EntryEmotion      `json:"emotions,omitempty"`
+	CreatedAt          time.Time           `json:"created_at"`
+	UpdatedAt          time.Time           `json:"updated_at"`
 }
 
 type JournalEntryResponse struct {
-	ID        int       `json:"id"`
-	Content   string    `json:"content"`
-	Analysis  string    `json:"analysis,omitempty"`
-	Sentiment string    `json:"sentiment,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
+	ID                 int                 `json:"id"`
+	Content            string              `json:"content"`
+	Language           string              `json:"language,omitempty"`
+	Analysis           string              `json:"analysis,omitempty"`
+	Sentiment          string              `json:"sentiment,omitempty"`
+	SentimentScores    *SentimentBreakdown `json:"sentiment_scores,omitempty"`
+	SentimentScore     *float64            `json:"sentiment_score,omitempty"`
+	StructuredAnalysis *AnalysisResult     `json:"structured_analysis,omitempty"`
+	AnalysisStatus     string              `json:"analysis_status"`
+	RiskFlagged        bool                `json:"risk_flagged"`
+	PromptVersion      string              `json:"prompt_version,omitempty"`
+	Model              string              `json:"model,omitempty"`
+	CurrentAnalysisID  *int                `json:"current_analysis_id,omitempty"`
+	Emotions           []EntryEmotion      `json:"emotions,omitempty"`
+	CreatedAt          time.Time           `json:"created_at"`
+}
+
+// SentimentBreakdown holds the offline lexicon scores computed from the
+// entry's own text: a compound score in [-1, 1] and the positive, negative
+// and neutral proportions.
+type SentimentBreakdown struct {
+	Compound float64 `json:"compound"`
+	Positive float64 `json:"pos"`
+	Negative float64 `json:"neg"`
+	Neutral  float64 `json:"neu"`
+}
+
+// journalColumns is the column list read by scanJournalEntry.
+const journalColumns = `id, content, user_id, language, analysis, sentiment,
+	sentiment_compound, sentiment_pos, sentiment_neg, sentiment_neu, sentiment_score,
+	structured_analysis, analysis_status,
+	risk_flagged, risk_level, risk_reasons, risk_source, injection_flags,
+	prompt_version, model, current_analysis_id, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJournalEntry(row rowScanner) (*JournalEntry, error) {
+	var entry JournalEntry
+	var compound, pos, neg, neu sql.NullFloat64
+	var language, riskLevel, riskSource, promptVersion, model sql.NullString
+	var currentAnalysisID sql.NullInt64
+	err := row.Scan(
+		&entry.ID, &entry.Content, &entry.UserID, &language,
+		&entry.Analysis, &entry.Sentiment,
+		&compound, &pos, &neg, &neu, &entry.SentimentScore,
+		&entry.StructuredAnalysis, &entry.AnalysisStatus,
+		&entry.RiskFlagged, &riskLevel, pq.Array(&entry.RiskReasons), &riskSource,
+		pq.Array(&entry.InjectionFlags),
+		&promptVersion, &model, &currentAnalysisID,
+		&entry.CreatedAt, &entry.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	entry.Language = language.String
+	entry.RiskLevel = riskLevel.String
+	entry.RiskSource = riskSource.String
+	entry.PromptVersion = promptVersion.String
+	entry.Model = model.String
+	if currentAnalysisID.Valid {
+		id := int(currentAnalysisID.Int64)
+		entry.CurrentAnalysisID = &id
+	}
+
+	// Entries written before lexicon scoring existed have no breakdown
+	if compound.Valid {
+		entry.SentimentScores = &SentimentBreakdown{
+			Compound: compound.Float64,
+			Positive: pos.Float64,
+			Negative: neg.Float64,
+			Neutral:  neu.Float64,
+		}
+	}
+	return &entry, nil
+}
+
+// sentimentColumnValues returns the breakdown as nullable column values.
+func (entry *JournalEntry) sentimentColumnValues() (compound, pos, neg, neu interface{}) {
+	if entry.SentimentScores == nil {
+		return nil, nil, nil, nil
+	}
+	b := entry.SentimentScores
+	return b.Compound, b.Positive, b.Negative, b.Neutral
 }
 
-func (entry *JournalEntry) CreateEntry(db *sql.DB) error {
+func (entry *JournalEntry) CreateEntry(ctx context.Context, db *sql.DB) error {
+	if entry.AnalysisStatus == "" {
+		entry.AnalysisStatus = AnalysisDone
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := entry.insert(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insert writes a new entry and its emotion scores inside tx.
+func (entry *JournalEntry) insert(ctx context.Context, tx *sql.Tx) error {
 	query := `
-		INSERT INTO journals (content, user_id, analysis, sentiment, created_at, updated_at) 
-		VALUES ($1, $2, $3, $4, NOW(), NOW()) 
+		INSERT INTO journals (content, user_id, analysis, sentiment,
+			sentiment_compound, sentiment_pos, sentiment_neg, sentiment_neu,
+			analysis_status, risk_flagged, risk_level, risk_reasons, risk_source,
+			injection_flags, language, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NOW(), NOW())
 		RETURNING id, created_at, updated_at`
-	
-	err := db.QueryRow(query, entry.Content, entry.UserID, entry.Analysis, entry.Sentiment).Scan(
-		&entry.ID, &entry.CreatedAt, &entry.UpdatedAt,
-	)
+
+	compound, pos, neg, neu := entry.sentimentColumnValues()
+	err := tx.QueryRowContext(ctx, query,
+		entry.Content, entry.UserID, entry.Analysis, entry.Sentiment,
+		compound, pos, neg, neu, entry.AnalysisStatus,
+		entry.RiskFlagged, nullString(entry.RiskLevel), pq.Array(entry.RiskReasons), nullString(entry.RiskSource),
+		pq.Array(entry.InjectionFlags), nullString(entry.Language),
+	).Scan(&entry.ID, &entry.CreatedAt, &entry.UpdatedAt)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	return insertEntryEmotions(ctx, tx, entry.ID, entry.Emotions)
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
 }
 
-func GetEntriesByUser(db *sql.DB, userID int, limit, offset int) ([]JournalEntry, error) {
+func GetEntriesByUser(ctx context.Context, db *sql.DB, userID int, limit, offset int) ([]JournalEntry, error) {
 	query := `
-		SELECT id, content, user_id, analysis, sentiment, created_at, updated_at 
-		FROM journals 
-		WHERE user_id = $1 
-		ORDER BY created_at DESC 
+		SELECT ` + journalColumns + `
+		FROM journals
+		WHERE user_id = $1
+		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3`
-	
-	rows, err := db.Query(query, userID, limit, offset)
+
+	rows, err := db.QueryContext(ctx, query, userID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -55,49 +182,168 @@ func GetEntriesByUser(db *sql.DB, userID int, limit, offset int) ([]JournalEntry
 
 	var entries []JournalEntry
 	for rows.Next() {
-		var entry JournalEntry
-		err := rows.Scan(
-			&entry.ID, &entry.Content, &entry.UserID, 
-			&entry.Analysis, &entry.Sentiment, 
-			&entry.CreatedAt, &entry.UpdatedAt,
-		)
+		entry, err := scanJournalEntry(rows)
 		if err != nil {
 			return nil, err
 		}
-		entries = append(entries, entry)
+		entries = append(entries, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := LoadEntryEmotions(ctx, db, entries); err != nil {
+		return nil, err
 	}
 	return entries, nil
 }
 
-func GetEntryByID(db *sql.DB, entryID, userID int) (*JournalEntry, error) {
+// GetRecentEntriesBefore returns up to limit of the user's entries written
+// in [since, before), newest first, leaving out excludeID.
+func GetRecentEntriesBefore(ctx context.Context, db *sql.DB, userID, excludeID int, since, before time.Time, limit int) ([]JournalEntry, error) {
 	query := `
-		SELECT id, content, user_id, analysis, sentiment, created_at, updated_at 
-		FROM journals 
+		SELECT ` + journalColumns + `
+		FROM journals
+		WHERE user_id = $1 AND id <> $2 AND created_at >= $3 AND created_at < $4
+		ORDER BY created_at DESC
+		LIMIT $5`
+
+	return queryEntries(ctx, db, query, userID, excludeID, since, before, limit)
+}
+
+func GetEntryByID(ctx context.Context, db *sql.DB, entryID, userID int) (*JournalEntry, error) {
+	query := `
+		SELECT ` + journalColumns + `
+		FROM journals
 		WHERE id = $1 AND user_id = $2`
-	
-	row := db.QueryRow(query, entryID, userID)
-	
-	var entry JournalEntry
-	err := row.Scan(
-		&entry.ID, &entry.Content, &entry.UserID, 
-		&entry.Analysis, &entry.Sentiment, 
-		&entry.CreatedAt, &entry.UpdatedAt,
-	)
+
+	entry, err := scanJournalEntry(db.QueryRowContext(ctx, query, entryID, userID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("journal entry not found")
 		}
 		return nil, err
 	}
-	return &entry, nil
+
+	entries := []JournalEntry{*entry}
+	if err := LoadEntryEmotions(ctx, db, entries); err != nil {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+// GetEntryForAnalysis loads an entry by ID without scoping it to a user; it
+// is only used by the analysis workers, which get the ID from a queued job.
+func GetEntryForAnalysis(ctx context.Context, db *sql.DB, entryID int) (*JournalEntry, error) {
+	query := `
+		SELECT ` + journalColumns + `
+		FROM journals
+		WHERE id = $1`
+
+	entry, err := scanJournalEntry(db.QueryRowContext(ctx, query, entryID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("journal entry not found")
+		}
+		return nil, err
+	}
+	return entry, nil
+}
+
+func UpdateAnalysisStatus(ctx context.Context, db *sql.DB, entryID int, status string) error {
+	query := `UPDATE journals SET analysis_status = $2, updated_at = NOW() WHERE id = $1`
+	_, err := db.ExecContext(ctx, query, entryID, status)
+	return err
+}
+
+// SaveAnalysis stores the analysis on the entry with its final status. A
+// completed analysis is also added to the entry's analysis history and
+// becomes its current analysis.
+func (entry *JournalEntry) SaveAnalysis(ctx context.Context, db *sql.DB, status string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if status == AnalysisDone {
+		var analysisID int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO journal_analyses (journal_id, analysis, structured_analysis, sentiment_score, prompt_version, model, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, NOW())
+			RETURNING id`,
+			entry.ID, entry.Analysis, entry.StructuredAnalysis, entry.SentimentScore,
+			nullString(entry.PromptVersion), nullString(entry.Model),
+		).Scan(&analysisID)
+		if err != nil {
+			return err
+		}
+		entry.CurrentAnalysisID = &analysisID
+	}
+
+	entry.AnalysisStatus = status
+	query := `
+		UPDATE journals
+		SET analysis = $2, sentiment_score = $3, structured_analysis = $4,
+			analysis_status = $5, prompt_version = $6, model = $7,
+			current_analysis_id = COALESCE($8, current_analysis_id), updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at`
+	err = tx.QueryRowContext(ctx, query,
+		entry.ID, entry.Analysis, entry.SentimentScore,
+		entry.StructuredAnalysis, entry.AnalysisStatus,
+		nullString(entry.PromptVersion), nullString(entry.Model), entry.CurrentAnalysisID,
+	).Scan(&entry.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RecordRisk stores a safety flag raised after the entry was created, e.g.
+// by screening the model's reply. The reasons are added to the ones already
+// stored, and the level and source only change when level is higher than
+// the stored one; an entry answered with crisis resources keeps its source.
+func RecordRisk(ctx context.Context, db *sql.DB, entryID int, flagged bool, level string, reasons []string, source string) error {
+	query := `
+		WITH current AS (
+			SELECT id,
+			       COALESCE(array_position(ARRAY['none', 'moderate', 'high'], risk_level::text), 0)
+			           < COALESCE(array_position(ARRAY['none', 'moderate', 'high'], $3::text), 0) AS raises
+			FROM journals
+			WHERE id = $1
+			FOR UPDATE
+		)
+		UPDATE journals j
+		SET risk_flagged = j.risk_flagged OR $2,
+		    risk_level = CASE WHEN c.raises THEN $3 ELSE j.risk_level END,
+		    risk_reasons = COALESCE(j.risk_reasons, '{}') || ARRAY(
+		        SELECT r FROM unnest($4::text[]) AS r WHERE r <> ALL(COALESCE(j.risk_reasons, '{}'))),
+		    risk_source = CASE WHEN c.raises AND j.risk_source IS DISTINCT FROM 'entry' THEN $5 ELSE j.risk_source END,
+		    updated_at = NOW()
+		FROM current c
+		WHERE j.id = c.id`
+	_, err := db.ExecContext(ctx, query, entryID, flagged, level, pq.Array(reasons), source)
+	return err
 }
 
 func (entry *JournalEntry) ToResponse() JournalEntryResponse {
 	return JournalEntryResponse{
-		ID:        entry.ID,
-		Content:   entry.Content,
-		Analysis:  entry.Analysis,
-		Sentiment: entry.Sentiment,
-		CreatedAt: entry.CreatedAt,
+		ID:                 entry.ID,
+		Content:            entry.Content,
+		Language:           entry.Language,
+		Analysis:           entry.Analysis,
+		Sentiment:          entry.Sentiment,
+		SentimentScores:    entry.SentimentScores,
+		SentimentScore:     entry.SentimentScore,
+		StructuredAnalysis: entry.StructuredAnalysis,
+		AnalysisStatus:     entry.AnalysisStatus,
+		RiskFlagged:        entry.RiskFlagged,
+		PromptVersion:      entry.PromptVersion,
+		Model:              entry.Model,
+		CurrentAnalysisID:  entry.CurrentAnalysisID,
+		Emotions:           entry.Emotions,
+		CreatedAt:          entry.CreatedAt,
 	}
-}
\ No newline at end of file
+}
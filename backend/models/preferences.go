@@ -0,0 +1,76 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Response styles a user can choose for the companion.
+const (
+	StyleConcise         = "concise"
+	StyleWarm            = "warm"
+	StyleCBT             = "cbt"
+	StyleSolutionFocused = "solution_focused"
+	StyleSocratic        = "socratic"
+)
+
+// Response lengths and tones a user can choose.
+const (
+	LengthShort  = "short"
+	LengthMedium = "medium"
+	LengthLong   = "long"
+
+	ToneGentle  = "gentle"
+	ToneNeutral = "neutral"
+	ToneUpbeat  = "upbeat"
+	ToneDirect  = "direct"
+)
+
+var (
+	ResponseStyles  = []string{StyleConcise, StyleWarm, StyleCBT, StyleSolutionFocused, StyleSocratic}
+	ResponseLengths = []string{LengthShort, LengthMedium, LengthLong}
+	ResponseTones   = []string{ToneGentle, ToneNeutral, ToneUpbeat, ToneDirect}
+)
+
+// ResponsePreferences is how a user would like analyses and chat replies
+// written. An empty field keeps the default companion for that aspect.
+type ResponsePreferences struct {
+	Style  string `json:"style"`
+	Length string `json:"length"`
+	Tone   string `json:"tone"`
+}
+
+// GetUserResponsePreferences returns the user's response preferences.
+func GetUserResponsePreferences(ctx context.Context, db *sql.DB, userID int) (ResponsePreferences, error) {
+	var style, length, tone sql.NullString
+	err := db.QueryRowContext(ctx,
+		`SELECT response_style, response_length, response_tone FROM users WHERE id = $1`, userID,
+	).Scan(&style, &length, &tone)
+	if err != nil && err != sql.ErrNoRows {
+		return ResponsePreferences{}, err
+	}
+	return ResponsePreferences{Style: style.String, Length: length.String, Tone: tone.String}, nil
+}
+
+// SetUserResponsePreferences stores the user's response preferences. Empty
+// fields are cleared.
+func SetUserResponsePreferences(ctx context.Context, db *sql.DB, userID int, prefs ResponsePreferences) error {
+	result, err := db.ExecContext(ctx, `
+		UPDATE users
+		SET response_style = $2, response_length = $3, response_tone = $4, updated_at = NOW()
+		WHERE id = $1`,
+		userID, nullString(prefs.Style), nullString(prefs.Length), nullString(prefs.Tone),
+	)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// GetUserRedaction returns whether PII redaction is on for the user, or nil
+// when they haven't chosen and the deployment default applies.
+func GetUserRedaction(ctx context.Context, db *sql.DB, userID int) (*bool, error) {
+	var enabled sql.NullBool
+	err := db.QueryRowContext(ctx, `SELECT redact_pii FROM users WHERE id = $1`, userID).Scan(&enabled)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if !enabled.Valid {
+		return nil, nil
+	}
+	return &enabled.Bool, nil
+}
+
+// SetUserRedaction stores the user's redaction preference. nil clears it.
+func SetUserRedaction(ctx context.Context, db *sql.DB, userID int, enabled *bool) error {
+	result, err := db.ExecContext(ctx, `UPDATE users SET redact_pii = $2, updated_at = NOW() WHERE id = $1`, userID, enabled)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
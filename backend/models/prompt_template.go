@@ -0,0 +1,43 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type PromptTemplate struct {
+	Name      string    `json:"name"`
+	Version   string    `json:"version"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func GetPromptTemplates(ctx context.Context, db *sql.DB) ([]PromptTemplate, error) {
+	rows, err := db.QueryContext(ctx, `SELECT name, version, body, created_at FROM prompt_templates ORDER BY name, version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []PromptTemplate
+	for rows.Next() {
+		var t PromptTemplate
+		if err := rows.Scan(&t.Name, &t.Version, &t.Body, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// GetUserPromptVersion returns the prompt version selected for the user, or
+// an empty string when the deployment default applies.
+func GetUserPromptVersion(ctx context.Context, db *sql.DB, userID int) (string, error) {
+	var version sql.NullString
+	err := db.QueryRowContext(ctx, `SELECT prompt_version FROM users WHERE id = $1`, userID).Scan(&version)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+	return version.String, nil
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrRefreshTokenInvalid is returned for a refresh token that is
+	// unknown, expired or revoked.
+	ErrRefreshTokenInvalid = errors.New("refresh token invalid")
+
+	// ErrRefreshTokenReused is returned when a refresh token that was
+	// already rotated is presented again. Its whole family is revoked,
+	// since either the client or an attacker holds a stolen copy.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// RefreshToken is a stored refresh token. Only the hash of the token is
+// kept; the token itself is handed to the client once.
+type RefreshToken struct {
+	ID        int
+	UserID    int
+	FamilyID  string
+	ExpiresAt time.Time
+}
+
+// CreateRefreshToken stores the hash of a new refresh token. familyID
+// starts a new family on login; rotation keeps the family of the token it
+// replaces.
+func CreateRefreshToken(ctx context.Context, db *sql.DB, userID int, familyID, tokenHash string, expiresAt time.Time) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW())`,
+		userID, familyID, tokenHash, expiresAt,
+	)
+	return err
+}
+
+// RotateRefreshToken spends the refresh token with tokenHash and stores
+// newHash in its place, in the same family. It returns the spent token. A
+// token that was already spent revokes its family and returns
+// ErrRefreshTokenReused.
+func RotateRefreshToken(ctx context.Context, db *sql.DB, tokenHash, newHash string, expiresAt time.Time) (*RefreshToken, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var token RefreshToken
+	var usedAt, revokedAt sql.NullTime
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, user_id, family_id, expires_at, used_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE`,
+		tokenHash,
+	).Scan(&token.ID, &token.UserID, &token.FamilyID, &token.ExpiresAt, &usedAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case revokedAt.Valid:
+		return nil, ErrRefreshTokenInvalid
+	case usedAt.Valid:
+		if err := revokeRefreshTokenFamily(ctx, tx, token.FamilyID); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	case time.Now().After(token.ExpiresAt):
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1`, token.ID); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW())`,
+		token.UserID, token.FamilyID, newHash, expiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &token, tx.Commit()
+}
+
+func revokeRefreshTokenFamily(ctx context.Context, tx *sql.Tx, familyID string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE family_id = $1 AND revoked_at IS NULL`,
+		familyID,
+	)
+	return err
+}
+
+// RevokeRefreshTokenFamily revokes the family of the refresh token with
+// tokenHash, as on logout. An unknown token is ignored.
+func RevokeRefreshTokenFamily(ctx context.Context, db *sql.DB, tokenHash string) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE revoked_at IS NULL
+		  AND family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $1)`,
+		tokenHash,
+	)
+	return err
+}
+
+// RevokeUserRefreshTokens revokes every refresh token of the user.
+func RevokeUserRefreshTokens(ctx context.Context, db *sql.DB, userID int) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL`,
+		userID,
+	)
+	return err
+}
+
+// DeleteExpiredRefreshTokens removes refresh tokens past their expiry,
+// which can no longer be used or reused, and returns how many it removed.
+func DeleteExpiredRefreshTokens(ctx context.Context, db *sql.DB) (int64, error) {
+	result, err := db.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// RevokedToken is an access token revoked before its expiry.
+type RevokedToken struct {
+	JTI       string
+	RevokedAt time.Time
+}
+
+// RevokeToken records the access token with jti as revoked until it
+// expires.
+func RevokeToken(ctx context.Context, db *sql.DB, jti string, userID int, expiresAt time.Time) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO revoked_tokens (jti, user_id, expires_at, revoked_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (jti) DO NOTHING`,
+		jti, userID, expiresAt,
+	)
+	return err
+}
+
+// IsTokenRevoked reports whether the access token with jti was revoked.
+func IsTokenRevoked(ctx context.Context, db *sql.DB, jti string) (bool, error) {
+	var revoked bool
+	err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`, jti).Scan(&revoked)
+	return revoked, err
+}
+
+// GetRevokedTokensSince returns the unexpired tokens revoked after since.
+func GetRevokedTokensSince(ctx context.Context, db *sql.DB, since time.Time) ([]RevokedToken, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT jti, revoked_at FROM revoked_tokens
+		WHERE revoked_at > $1 AND expires_at > NOW()`,
+		since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []RevokedToken
+	for rows.Next() {
+		var t RevokedToken
+		if err := rows.Scan(&t.JTI, &t.RevokedAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteExpiredRevocations removes revocations of tokens that have expired
+// anyway and returns how many it removed.
+func DeleteExpiredRevocations(ctx context.Context, db *sql.DB) (int64, error) {
+	result, err := db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RevokeUserTokens revokes every access token the user was issued before
+// cutoff.
+func RevokeUserTokens(ctx context.Context, db *sql.DB, userID int, cutoff time.Time) error {
+	result, err := db.ExecContext(ctx, `UPDATE users SET tokens_revoked_at = $2 WHERE id = $1`, userID, cutoff)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+// GetTokenCutoffsSince returns the cutoffs set by RevokeUserTokens after
+// since, by user.
+func GetTokenCutoffsSince(ctx context.Context, db *sql.DB, since time.Time) (map[int]time.Time, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, tokens_revoked_at FROM users
+		WHERE tokens_revoked_at > $1`,
+		since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cutoffs := make(map[int]time.Time)
+	for rows.Next() {
+		var userID int
+		var cutoff time.Time
+		if err := rows.Scan(&userID, &cutoff); err != nil {
+			return nil, err
+		}
+		cutoffs[userID] = cutoff
+	}
+	return cutoffs, rows.Err()
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Usage outcomes recorded for each analysis.
+const (
+	UsageSuccess       = "success"
+	UsageCached        = "cached"
+	UsageInvalidOutput = "invalid_output"
+	UsageUnavailable   = "unavailable"
+	UsageCancelled     = "cancelled"
+	UsageError         = "error"
+)
+
+// UsageRecord is one analysis request and the tokens it consumed, summed
+// over retries and repair calls.
+type UsageRecord struct {
+	ID               int       `json:"id"`
+	UserID           int       `json:"-"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	PromptVersion    string    `json:"prompt_version,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Estimated        bool      `json:"estimated"`
+	LatencyMs        int64     `json:"latency_ms"`
+	Outcome          string    `json:"outcome"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// UsageTotals sums usage over a period. Cached analyses count towards
+// tokens (always zero) but not calls.
+type UsageTotals struct {
+	Calls  int64 `json:"calls"`
+	Tokens int64 `json:"tokens"`
+}
+
+// QuotaOverride holds per-user limits; nil fields fall back to the plan.
+type QuotaOverride struct {
+	DailyCalls    *int64
+	MonthlyCalls  *int64
+	DailyTokens   *int64
+	MonthlyTokens *int64
+}
+
+func (r *UsageRecord) Create(ctx context.Context, db *sql.DB) error {
+	query := `
+		INSERT INTO llm_usage (user_id, provider, model, prompt_version, prompt_tokens,
+			completion_tokens, estimated, latency_ms, outcome, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+		RETURNING id, created_at`
+
+	return db.QueryRowContext(ctx, query,
+		r.UserID, r.Provider, r.Model, nullString(r.PromptVersion), r.PromptTokens,
+		r.CompletionTokens, r.Estimated, r.LatencyMs, r.Outcome,
+	).Scan(&r.ID, &r.CreatedAt)
+}
+
+// GetUsageTotals returns the user's usage for the current day and the
+// current calendar month.
+func GetUsageTotals(ctx context.Context, db *sql.DB, userID int) (day, month UsageTotals, err error) {
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE outcome <> 'cached' AND created_at >= date_trunc('day', NOW())),
+			COALESCE(SUM(prompt_tokens + completion_tokens) FILTER (WHERE created_at >= date_trunc('day', NOW())), 0),
+			COUNT(*) FILTER (WHERE outcome <> 'cached'),
+			COALESCE(SUM(prompt_tokens + completion_tokens), 0)
+		FROM llm_usage
+		WHERE user_id = $1 AND created_at >= date_trunc('month', NOW())`
+
+	err = db.QueryRowContext(ctx, query, userID).Scan(&day.Calls, &day.Tokens, &month.Calls, &month.Tokens)
+	return day, month, err
+}
+
+func GetRecentUsage(ctx context.Context, db *sql.DB, userID, limit int) ([]UsageRecord, error) {
+	query := `
+		SELECT id, user_id, provider, model, prompt_version, prompt_tokens,
+			completion_tokens, estimated, latency_ms, outcome, created_at
+		FROM llm_usage
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2`
+
+	rows, err := db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []UsageRecord
+	for rows.Next() {
+		var r UsageRecord
+		var promptVersion sql.NullString
+		err := rows.Scan(&r.ID, &r.UserID, &r.Provider, &r.Model, &promptVersion, &r.PromptTokens,
+			&r.CompletionTokens, &r.Estimated, &r.LatencyMs, &r.Outcome, &r.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		r.PromptVersion = promptVersion.String
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// GetUserPlan returns the user's plan, or an empty string for the default.
+func GetUserPlan(ctx context.Context, db *sql.DB, userID int) (string, error) {
+	var plan sql.NullString
+	err := db.QueryRowContext(ctx, `SELECT plan FROM users WHERE id = $1`, userID).Scan(&plan)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+	return plan.String, nil
+}
+
+// GetUserQuotaOverride returns the user's quota overrides, or nil if none
+// are set.
+func GetUserQuotaOverride(ctx context.Context, db *sql.DB, userID int) (*QuotaOverride, error) {
+	var daily, monthly, dailyTokens, monthlyTokens sql.NullInt64
+	err := db.QueryRowContext(ctx, `
+		SELECT daily_calls, monthly_calls, daily_tokens, monthly_tokens
+		FROM user_quotas WHERE user_id = $1`, userID,
+	).Scan(&daily, &monthly, &dailyTokens, &monthlyTokens)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &QuotaOverride{
+		DailyCalls:    nullInt64Ptr(daily),
+		MonthlyCalls:  nullInt64Ptr(monthly),
+		DailyTokens:   nullInt64Ptr(dailyTokens),
+		MonthlyTokens: nullInt64Ptr(monthlyTokens),
+	}, nil
+}
+
+func nullInt64Ptr(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int64
+}
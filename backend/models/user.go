@@ -1,8 +1,10 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"strings"
 	"time"
 )
 
@@ -20,13 +22,13 @@ type UserResponse struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
-func (user *User) CreateUser(db *sql.DB) error {
+func (user *User) CreateUser(ctx context.Context, db *sql.DB) error {
 	query := `
 		INSERT INTO users (email, password, created_at, updated_at) 
 		VALUES ($1, $2, NOW(), NOW()) 
 		RETURNING id, created_at, updated_at`
 	
-	err := db.QueryRow(query, user.Email, user.Password).Scan(
+	err := db.QueryRowContext(ctx, query, user.Email, user.Password).Scan(
 		&user.ID, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err != nil {
@@ -38,9 +40,9 @@ func (user *User) CreateUser(db *sql.DB) error {
 	return nil
 }
 
-func GetUserByEmail(db *sql.DB, email string) (*User, error) {
+func GetUserByEmail(ctx context.Context, db *sql.DB, email string) (*User, error) {
 	query := `SELECT id, email, password, created_at, updated_at FROM users WHERE email = $1`
-	row := db.QueryRow(query, email)
+	row := db.QueryRowContext(ctx, query, email)
 	
 	var user User
 	err := row.Scan(&user.ID, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
@@ -53,9 +55,9 @@ func GetUserByEmail(db *sql.DB, email string) (*User, error) {
 	return &user, nil
 }
 
-func GetUserByID(db *sql.DB, userID int) (*User, error) {
+func GetUserByID(ctx context.Context, db *sql.DB, userID int) (*User, error) {
 	query := `SELECT id, email, created_at, updated_at FROM users WHERE id = $1`
-	row := db.QueryRow(query, userID)
+	row := db.QueryRowContext(ctx, query, userID)
 	
 	var user User
 	err := row.Scan(&user.ID, &user.Email, &user.CreatedAt, &user.UpdatedAt)
@@ -0,0 +1,70 @@
+package promptguard
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var clinicianClaim = regexp.MustCompile(`(?i)\b(i\s*(am|'m)\s+(a|an|your)\s+(licensed\s+|certified\s+|qualified\s+)?(doctor|physician|therapist|psychologist|psychiatrist|counsel+or|clinician|nurse|medical\s+professional)|as\s+(a|an|your)\s+(doctor|physician|therapist|psychologist|psychiatrist|counsel+or|clinician)|i\s+(can\s+)?(diagnose|prescribe)|my\s+(clinical\s+)?diagnosis)\b`)
+
+var sentenceEnd = regexp.MustCompile(`[^.!?]+[.!?]*\s*`)
+
+// OutputFilter removes sentences from model replies that repeat the system
+// instructions or claim clinical credentials.
+type OutputFilter struct {
+	shingles map[string]bool
+}
+
+// shingleSize is the run of consecutive words treated as an echo of the
+// instructions; short enough to catch paraphrase-free leaks, long enough to
+// allow common phrases.
+const shingleSize = 8
+
+func NewOutputFilter(instructions string) *OutputFilter {
+	f := &OutputFilter{shingles: make(map[string]bool)}
+	words := normalizedWords(instructions)
+	for i := 0; i+shingleSize <= len(words); i++ {
+		f.shingles[strings.Join(words[i:i+shingleSize], " ")] = true
+	}
+	return f
+}
+
+// Filter returns text without offending sentences, and why any were removed.
+func (f *OutputFilter) Filter(text string) (string, []string) {
+	var kept []string
+	reasons := map[string]bool{}
+
+	for _, sentence := range sentenceEnd.FindAllString(text, -1) {
+		switch {
+		case clinicianClaim.MatchString(sentence):
+			reasons["clinician_claim"] = true
+		case f.echoes(sentence):
+			reasons["instruction_echo"] = true
+		default:
+			kept = append(kept, sentence)
+		}
+	}
+
+	var list []string
+	for r := range reasons {
+		list = append(list, r)
+	}
+	return strings.TrimSpace(strings.Join(kept, "")), list
+}
+
+func (f *OutputFilter) echoes(sentence string) bool {
+	words := normalizedWords(sentence)
+	for i := 0; i+shingleSize <= len(words); i++ {
+		if f.shingles[strings.Join(words[i:i+shingleSize], " ")] {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizedWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\''
+	})
+}
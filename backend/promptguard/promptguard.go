@@ -0,0 +1,71 @@
+// Package promptguard keeps untrusted journal text from being interpreted as
+// instructions by the model, and keeps the model's reply from leaking those
+// instructions or overstepping its role.
+package promptguard
+
+import (
+	"regexp"
+	"strings"
+)
+
+// EntryTag delimits the user's entry inside prompts.
+const EntryTag = "journal_entry"
+
+var (
+	// Opening or closing delimiter tags, in any case or spacing
+	delimiterTag = regexp.MustCompile(`(?i)<\s*/?\s*` + EntryTag + `[^>]*>`)
+
+	// Chat-template control tokens such as <|im_start|> or <|eot_id|>
+	specialToken = regexp.MustCompile(`<\|[^|>]*\|>`)
+
+	// Lines that imitate a role or section marker, e.g. "Assistant:" or "JSON:"
+	roleMarker = regexp.MustCompile(`(?im)^(\s*)(system|user|assistant|response|json|instructions?)\s*:`)
+
+	controlChars = regexp.MustCompile(`[\x00-\x08\x0b\x0c\x0e-\x1f\x7f]`)
+)
+
+// Escape neutralises delimiter tags, control tokens and role markers in
+// untrusted text while leaving it readable to the model.
+func Escape(text string) string {
+	text = controlChars.ReplaceAllString(text, "")
+	text = delimiterTag.ReplaceAllStringFunc(text, func(tag string) string {
+		return strings.NewReplacer("<", "&lt;", ">", "&gt;").Replace(tag)
+	})
+	text = specialToken.ReplaceAllStringFunc(text, func(tok string) string {
+		return strings.NewReplacer("<|", "&lt;|", "|>", "|&gt;").Replace(tok)
+	})
+	text = strings.ReplaceAll(text, "[INST]", "(INST)")
+	text = strings.ReplaceAll(text, "[/INST]", "(/INST)")
+	return roleMarker.ReplaceAllString(text, "$1> $2:")
+}
+
+// Delimit escapes text and wraps it in entry tags.
+func Delimit(text string) string {
+	return "<" + EntryTag + ">\n" + Escape(text) + "\n</" + EntryTag + ">"
+}
+
+type pattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+var injectionPatterns = []pattern{
+	{"ignore_instructions", regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\b.{0,30}\b(previous|prior|above|earlier|all|your|the)\b.{0,20}\b(instructions?|prompts?|rules|directions)\b`)},
+	{"role_reassignment", regexp.MustCompile(`(?i)\b(you\s+are\s+now|from\s+now\s+on\s+you\s+are|pretend\s+(to\s+be|you\s+are)|act\s+as\s+(an?\s+)?(unrestricted|different|new))\b`)},
+	{"system_prompt_probe", regexp.MustCompile(`(?i)\b(system\s+prompt|initial\s+instructions|reveal\s+your\s+(instructions|prompt)|print\s+your\s+(instructions|prompt))\b`)},
+	{"new_instructions", regexp.MustCompile(`(?i)\bnew\s+instructions?\s*:`)},
+	{"delimiter_spoof", delimiterTag},
+	{"control_token", regexp.MustCompile(`<\|[^|>]*\|>|\[/?INST\]`)},
+	{"role_marker", roleMarker},
+}
+
+// Detect returns the names of injection patterns found in text.
+func Detect(text string) []string {
+	var found []string
+	for _, p := range injectionPatterns {
+		if p.re.MatchString(text) {
+			found = append(found, p.name)
+		}
+	}
+	return found
+}
@@ -0,0 +1,208 @@
+// Package prompts holds named, versioned text/template prompts. Templates
+// ship embedded under templates/<name>/<version>.tmpl and can be added to or
+// overridden from the prompt_templates table. A version written for another
+// language is registered as "<version>.<language>", e.g. v3.es.
+package prompts
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+
+	"go_health_sentiment/models"
+)
+
+//go:embed templates
+var embedded embed.FS
+
+// Names of the prompts the application uses.
+const (
+	// Analysis is the journal analysis prompt
+	Analysis = "analysis"
+
+	// EntryChat is the system prompt for a conversation about one entry
+	EntryChat = "entry_chat"
+
+	// ChatSummary condenses older turns of an entry conversation
+	ChatSummary = "chat_summary"
+
+	// Digest is the weekly or monthly reflection on a user's entries
+	Digest = "digest"
+
+	// ResponseStyle carries a user's chosen style, length and tone; it is
+	// added to the analysis and entry chat instructions
+	ResponseStyle = "response_style"
+)
+
+type Template struct {
+	Name    string
+	Version string
+	tmpl    *template.Template
+}
+
+// ID identifies the template as "<name>@<version>", the form recorded on
+// journal entries.
+func (t *Template) ID() string {
+	return t.Name + "@" + t.Version
+}
+
+func (t *Template) Render(data interface{}) (string, error) {
+	return t.execute(t.tmpl, data)
+}
+
+// Rendered is a prompt split into the instructions and the user turn.
+type Rendered struct {
+	System string
+	User   string
+}
+
+// RenderMessages renders role-separated templates, which define "system" and
+// "user" blocks. Older single-block templates render entirely as the user
+// turn.
+func (t *Template) RenderMessages(data interface{}) (Rendered, error) {
+	system, user := t.tmpl.Lookup("system"), t.tmpl.Lookup("user")
+	if system == nil || user == nil {
+		text, err := t.Render(data)
+		return Rendered{User: text}, err
+	}
+
+	var r Rendered
+	var err error
+	if r.System, err = t.execute(system, data); err != nil {
+		return Rendered{}, err
+	}
+	if r.User, err = t.execute(user, data); err != nil {
+		return Rendered{}, err
+	}
+	return r, nil
+}
+
+func (t *Template) execute(tmpl *template.Template, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("error rendering prompt %s: %v", t.ID(), err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+type Registry struct {
+	mu        sync.RWMutex
+	templates map[string]map[string]*Template
+	defaults  map[string]string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		templates: make(map[string]map[string]*Template),
+		defaults:  make(map[string]string),
+	}
+}
+
+// LoadEmbedded returns a registry with every template shipped in the binary.
+func LoadEmbedded() (*Registry, error) {
+	r := NewRegistry()
+	err := fs.WalkDir(embedded, "templates", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(p) != ".tmpl" {
+			return err
+		}
+
+		body, err := embedded.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		name := path.Base(path.Dir(p))
+		version := strings.TrimSuffix(path.Base(p), ".tmpl")
+		return r.Register(name, version, string(body))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error loading embedded prompts: %v", err)
+	}
+	return r, nil
+}
+
+// LoadFromDB registers templates stored in the prompt_templates table,
+// replacing embedded templates with the same name and version.
+func (r *Registry) LoadFromDB(ctx context.Context, db *sql.DB) error {
+	stored, err := models.GetPromptTemplates(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, t := range stored {
+		if err := r.Register(t.Name, t.Version, t.Body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Registry) Register(name, version, body string) error {
+	tmpl, err := template.New(name + "@" + version).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return fmt.Errorf("error parsing prompt %s@%s: %v", name, version, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.templates[name] == nil {
+		r.templates[name] = make(map[string]*Template)
+	}
+	r.templates[name][version] = &Template{Name: name, Version: version, tmpl: tmpl}
+	return nil
+}
+
+// SetDefault selects the version used when none is requested.
+func (r *Registry) SetDefault(name, version string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.templates[name][version]; !ok {
+		return fmt.Errorf("unknown prompt %s@%s", name, version)
+	}
+	r.defaults[name] = version
+	return nil
+}
+
+// Resolve returns the requested version of a template. An empty or unknown
+// version falls back to the default, so a stale per-user selection never
+// breaks analysis.
+func (r *Registry) Resolve(name, version string) (*Template, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if version != "" {
+		if t, ok := r.templates[name][version]; ok {
+			return t, nil
+		}
+		log.Printf("Prompt %s@%s not found, using default", name, version)
+	}
+
+	t, ok := r.templates[name][r.defaults[name]]
+	if !ok {
+		return nil, fmt.Errorf("no default version for prompt %s", name)
+	}
+	return t, nil
+}
+
+// ResolveLanguage resolves a version like Resolve, then returns its variant
+// for language if there is one. Without a variant the resolved template is
+// returned as is.
+func (r *Registry) ResolveLanguage(name, version, language string) (*Template, error) {
+	t, err := r.Resolve(name, version)
+	if err != nil || language == "" {
+		return t, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if variant, ok := r.templates[name][t.Version+"."+language]; ok {
+		return variant, nil
+	}
+	return t, nil
+}
@@ -0,0 +1,154 @@
+package redact
+
+import (
+	"bufio"
+	_ "embed"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Kinds of PII the built-in detectors report.
+const (
+	KindName        = "name"
+	KindLocation    = "location"
+	KindEmail       = "email"
+	KindPhone       = "phone"
+	KindSSN         = "ssn"
+	KindCard        = "card"
+	KindAddress     = "address"
+	KindMedicalID   = "medical_id"
+	KindDateOfBirth = "date_of_birth"
+)
+
+// PatternDetector reports matches of a regular expression. Validate, if
+// set, can reject a match, e.g. a card number that fails its checksum.
+type PatternDetector struct {
+	Kind     string
+	Pattern  *regexp.Regexp
+	Validate func(match string) bool
+}
+
+func (p *PatternDetector) Name() string { return p.Kind }
+
+func (p *PatternDetector) Detect(text string) []Match {
+	var matches []Match
+	for _, loc := range p.Pattern.FindAllStringIndex(text, -1) {
+		if p.Validate != nil && !p.Validate(text[loc[0]:loc[1]]) {
+			continue
+		}
+		matches = append(matches, Match{Start: loc[0], End: loc[1], Kind: p.Kind})
+	}
+	return matches
+}
+
+// Patterns returns the built-in regular expression detectors. Health
+// conditions themselves are left in place; they are what the entry is
+// analyzed for. Identifiers that tie them to a person are not.
+func Patterns() []Detector {
+	return []Detector{
+		&PatternDetector{Kind: KindEmail, Pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+		&PatternDetector{Kind: KindSSN, Pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+		&PatternDetector{Kind: KindCard, Pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), Validate: luhn},
+		&PatternDetector{Kind: KindPhone, Pattern: regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?(?:\(\d{3}\)\s?|\b\d{3}[\s.-])\d{3}[\s.-]\d{4}\b|\b\d{10}\b`)},
+		&PatternDetector{Kind: KindAddress, Pattern: regexp.MustCompile(`\b\d{1,5}\s+(?:[A-Z][a-z]+\s+){1,3}(?:Street|St|Avenue|Ave|Road|Rd|Boulevard|Blvd|Lane|Ln|Drive|Dr|Court|Ct|Way|Place|Pl|Terrace|Crescent|Close)\b`)},
+		&PatternDetector{Kind: KindMedicalID, Pattern: regexp.MustCompile(`(?i)\b(?:MRN|medical record(?: number)?|patient (?:id|number)|NHS number|insurance (?:id|number))[\s:#]*[A-Z0-9][A-Z0-9-]{4,}`)},
+		&PatternDetector{Kind: KindDateOfBirth, Pattern: regexp.MustCompile(`(?i)\b(?:born on|DOB|date of birth)[\s:]*\d{1,2}[/.-]\d{1,2}[/.-]\d{2,4}`)},
+		// A title followed by a capitalized word is a name even if it isn't
+		// in the dictionary
+		&PatternDetector{Kind: KindName, Pattern: regexp.MustCompile(`\b(?:Dr|Mr|Mrs|Ms|Mx|Prof)\.?\s+[A-Z][a-z]+(?:-[A-Z][a-z]+)?`)},
+	}
+}
+
+// luhn reports whether the digits in s pass the Luhn checksum used by card
+// numbers.
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+// DictionaryDetector reports whole-word, case-sensitive occurrences of a
+// fixed list of terms, which may span several words.
+type DictionaryDetector struct {
+	kind    string
+	pattern *regexp.Regexp
+}
+
+// NewDictionaryDetector builds a detector for terms. Blank terms are
+// ignored; an empty list matches nothing.
+func NewDictionaryDetector(kind string, terms []string) *DictionaryDetector {
+	quoted := make([]string, 0, len(terms))
+	for _, t := range terms {
+		if t = strings.TrimSpace(t); t != "" {
+			quoted = append(quoted, regexp.QuoteMeta(t))
+		}
+	}
+	d := &DictionaryDetector{kind: kind}
+	if len(quoted) == 0 {
+		return d
+	}
+
+	// Longer terms first, so "New York City" wins over "New York"
+	sort.Slice(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+	d.pattern = regexp.MustCompile(`\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	return d
+}
+
+func (d *DictionaryDetector) Name() string { return d.kind + "_dictionary" }
+
+func (d *DictionaryDetector) Detect(text string) []Match {
+	if d.pattern == nil {
+		return nil
+	}
+	var matches []Match
+	for _, loc := range d.pattern.FindAllStringIndex(text, -1) {
+		matches = append(matches, Match{Start: loc[0], End: loc[1], Kind: d.kind})
+	}
+	return matches
+}
+
+//go:embed dictionaries/names.txt
+var namesList string
+
+//go:embed dictionaries/places.txt
+var placesList string
+
+// Names detects common first names. Names that are also everyday words,
+// such as Will or Hope, are left out of the list.
+func Names() *DictionaryDetector {
+	return NewDictionaryDetector(KindName, ParseTerms(namesList))
+}
+
+// Places detects well-known cities, regions and countries.
+func Places() *DictionaryDetector {
+	return NewDictionaryDetector(KindLocation, ParseTerms(placesList))
+}
+
+// ParseTerms reads one term per line, skipping blank lines and lines
+// starting with #.
+func ParseTerms(list string) []string {
+	var terms []string
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			terms = append(terms, line)
+		}
+	}
+	return terms
+}
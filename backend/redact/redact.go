@@ -0,0 +1,177 @@
+// Package redact replaces personal information in text with placeholders
+// before it is sent to a third-party model, and restores it in the reply.
+//
+// Detectors find spans of PII; a Session assigns each distinct value a
+// placeholder such as [NAME_1] that stays the same for every text redacted
+// in that session, so the model can still tell people and places apart.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Match is a span of text a detector identified as PII.
+type Match struct {
+	Start, End int
+	Kind       string
+}
+
+// Detector finds PII of one or more kinds in text.
+type Detector interface {
+	Name() string
+	Detect(text string) []Match
+}
+
+// Redactor runs a set of detectors. It is safe for concurrent use; per-text
+// state lives in Sessions.
+type Redactor struct {
+	detectors []Detector
+}
+
+func New(detectors ...Detector) *Redactor {
+	return &Redactor{detectors: detectors}
+}
+
+// Detectors returns the names of the configured detectors.
+func (r *Redactor) Detectors() []string {
+	names := make([]string, len(r.detectors))
+	for i, d := range r.detectors {
+		names[i] = d.Name()
+	}
+	return names
+}
+
+// NewSession starts a set of texts that share placeholders.
+func (r *Redactor) NewSession() *Session {
+	return &Session{
+		redactor: r,
+		byValue:  make(map[string]string),
+		values:   make(map[string]string),
+		next:     make(map[string]int),
+	}
+}
+
+// find returns the non-overlapping matches of every detector in text,
+// preferring the earliest and then the longest span.
+func (r *Redactor) find(text string) []Match {
+	var matches []Match
+	for _, d := range r.detectors {
+		matches = append(matches, d.Detect(text)...)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Start != matches[j].Start {
+			return matches[i].Start < matches[j].Start
+		}
+		return matches[i].End > matches[j].End
+	})
+
+	kept := matches[:0]
+	end := 0
+	for _, m := range matches {
+		if m.Start >= end && m.End > m.Start {
+			kept = append(kept, m)
+			end = m.End
+		}
+	}
+	return kept
+}
+
+// Counts is the number of spans redacted per kind.
+type Counts map[string]int
+
+// Add merges other into c.
+func (c Counts) Add(other Counts) {
+	for kind, n := range other {
+		c[kind] += n
+	}
+}
+
+// String lists the counts as "kind=n" pairs in a stable order. It never
+// includes the redacted values, so it is safe to log.
+func (c Counts) String() string {
+	kinds := make([]string, 0, len(c))
+	for kind := range c {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	parts := make([]string, len(kinds))
+	for i, kind := range kinds {
+		parts[i] = fmt.Sprintf("%s=%d", kind, c[kind])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Session redacts related texts, such as the messages of one model request,
+// with consistent placeholders, and restores them in the model's reply.
+type Session struct {
+	redactor *Redactor
+
+	mu      sync.Mutex
+	byValue map[string]string // "kind\x00value" -> placeholder
+	values  map[string]string // placeholder -> value
+	next    map[string]int
+}
+
+// Redact replaces every detected span in text with its placeholder.
+func (s *Session) Redact(text string) (string, Counts) {
+	matches := s.redactor.find(text)
+	counts := Counts{}
+	if len(matches) == 0 {
+		return text, counts
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m.Start])
+		b.WriteString(s.placeholder(m.Kind, text[m.Start:m.End]))
+		counts[m.Kind]++
+		last = m.End
+	}
+	b.WriteString(text[last:])
+	return b.String(), counts
+}
+
+func (s *Session) placeholder(kind, value string) string {
+	key := kind + "\x00" + value
+	if p, ok := s.byValue[key]; ok {
+		return p
+	}
+	s.next[kind]++
+	p := "[" + strings.ToUpper(kind) + "_" + strconv.Itoa(s.next[kind]) + "]"
+	s.byValue[key] = p
+	s.values[p] = value
+	return p
+}
+
+var placeholderPattern = regexp.MustCompile(`\[[A-Z_]+_\d+\]`)
+
+// MaxPlaceholderLen bounds the length of a placeholder, for callers that
+// restore streamed text and must hold back a partial one.
+const MaxPlaceholderLen = 32
+
+// Rehydrate restores the original values of placeholders this session
+// issued. Anything else in brackets is left alone.
+func (s *Session) Rehydrate(text string) string {
+	if !strings.Contains(text, "[") {
+		return text
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return placeholderPattern.ReplaceAllStringFunc(text, func(p string) string {
+		if value, ok := s.values[p]; ok {
+			return value
+		}
+		return p
+	})
+}
@@ -0,0 +1,104 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func newRedactor() *Redactor {
+	return New(append(Patterns(), Names(), Places())...)
+}
+
+func TestRedactRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		counts Counts
+		hidden []string
+	}{
+		{
+			name:   "names and places",
+			text:   "Had lunch with Sarah in London, then Sarah called John.",
+			counts: Counts{KindName: 3, KindLocation: 1},
+			hidden: []string{"Sarah", "London", "John"},
+		},
+		{
+			name:   "contact details",
+			text:   "Email me at jo.doe@example.com or call (555) 123-4567.",
+			counts: Counts{KindEmail: 1, KindPhone: 1},
+			hidden: []string{"jo.doe@example.com", "123-4567"},
+		},
+		{
+			name:   "identifiers",
+			text:   "SSN 123-45-6789, card 4111 1111 1111 1111, MRN: AB12345, DOB 04/12/1990.",
+			counts: Counts{KindSSN: 1, KindCard: 1, KindMedicalID: 1, KindDateOfBirth: 1},
+			hidden: []string{"123-45-6789", "4111 1111 1111 1111", "AB12345", "04/12/1990"},
+		},
+		{
+			name:   "title and address",
+			text:   "Saw Dr. Okafor at 221 Baker Street today.",
+			counts: Counts{KindName: 1, KindAddress: 1},
+			hidden: []string{"Okafor", "221 Baker Street"},
+		},
+		{
+			name:   "nothing to redact",
+			text:   "Slept badly and felt anxious all morning.",
+			counts: Counts{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := newRedactor().NewSession()
+			redacted, counts := session.Redact(tt.text)
+
+			if counts.String() != tt.counts.String() {
+				t.Errorf("counts = %v, want %v", counts, tt.counts)
+			}
+			for _, value := range tt.hidden {
+				if strings.Contains(redacted, value) {
+					t.Errorf("redacted text %q still contains %q", redacted, value)
+				}
+			}
+			if got := session.Rehydrate(redacted); got != tt.text {
+				t.Errorf("Rehydrate = %q, want %q", got, tt.text)
+			}
+		})
+	}
+}
+
+func TestSessionPlaceholders(t *testing.T) {
+	session := newRedactor().NewSession()
+
+	first, _ := session.Redact("Maria and John went to Madrid.")
+	if want := "[NAME_1] and [NAME_2] went to [LOCATION_1]."; first != want {
+		t.Fatalf("Redact = %q, want %q", first, want)
+	}
+
+	// A later text in the same session reuses the placeholders
+	second, _ := session.Redact("John missed Maria.")
+	if want := "[NAME_2] missed [NAME_1]."; second != want {
+		t.Errorf("Redact = %q, want %q", second, want)
+	}
+
+	// Placeholders the session didn't issue are left alone
+	reply := "[NAME_2] and [NAME_9] should talk, see [LINK]."
+	if got, want := session.Rehydrate(reply), "John and [NAME_9] should talk, see [LINK]."; got != want {
+		t.Errorf("Rehydrate = %q, want %q", got, want)
+	}
+}
+
+func TestCardRequiresChecksum(t *testing.T) {
+	_, counts := newRedactor().NewSession().Redact("Order 4111 1111 1111 1112 shipped.")
+	if counts[KindCard] != 0 {
+		t.Errorf("number failing the Luhn check redacted as a card: %v", counts)
+	}
+}
+
+func TestParseTerms(t *testing.T) {
+	got := ParseTerms("# names\nAda\n\n  Grace Hopper  \n#Linus\n")
+	want := []string{"Ada", "Grace Hopper"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("ParseTerms = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,97 @@
+package safety
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+//go:embed resources.json
+var defaultResources []byte
+
+type Resource struct {
+	Name        string `json:"name"`
+	Phone       string `json:"phone,omitempty"`
+	Text        string `json:"text,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// CrisisResources is the payload returned instead of the AI reply when an
+// entry is flagged.
+type CrisisResources struct {
+	Locale    string     `json:"locale"`
+	Message   string     `json:"message"`
+	Resources []Resource `json:"resources"`
+}
+
+// ResourceDirectory maps locales such as "en-US" or "de" to resources.
+type ResourceDirectory struct {
+	byLocale      map[string]CrisisResources
+	defaultLocale string
+}
+
+// LoadResources reads the directory from path, or uses the embedded
+// defaults when path is empty.
+func LoadResources(path, defaultLocale string) (*ResourceDirectory, error) {
+	data := defaultResources
+	if path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading crisis resources: %v", err)
+		}
+	}
+
+	var byLocale map[string]CrisisResources
+	if err := json.Unmarshal(data, &byLocale); err != nil {
+		return nil, fmt.Errorf("error decoding crisis resources: %v", err)
+	}
+
+	normalized := make(map[string]CrisisResources, len(byLocale))
+	for locale, res := range byLocale {
+		res.Locale = locale
+		normalized[strings.ToLower(locale)] = res
+	}
+
+	if _, ok := normalized[strings.ToLower(defaultLocale)]; !ok {
+		return nil, fmt.Errorf("no crisis resources for default locale %s", defaultLocale)
+	}
+
+	return &ResourceDirectory{byLocale: normalized, defaultLocale: strings.ToLower(defaultLocale)}, nil
+}
+
+// ForLocale returns the resources for locale, falling back to its base
+// language and then to the default locale.
+func (d *ResourceDirectory) ForLocale(locale string) CrisisResources {
+	if res, ok := d.lookup(locale); ok {
+		return res
+	}
+	return d.byLocale[d.defaultLocale]
+}
+
+// ForAcceptLanguage picks resources for the first supported language in an
+// Accept-Language header.
+func (d *ResourceDirectory) ForAcceptLanguage(header string) CrisisResources {
+	for _, part := range strings.Split(header, ",") {
+		tag := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		if res, ok := d.lookup(tag); ok {
+			return res
+		}
+	}
+	return d.byLocale[d.defaultLocale]
+}
+
+func (d *ResourceDirectory) lookup(locale string) (CrisisResources, bool) {
+	locale = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	if res, ok := d.byLocale[locale]; ok {
+		return res, true
+	}
+	if i := strings.Index(locale, "-"); i > 0 {
+		res, ok := d.byLocale[locale[:i]]
+		return res, ok
+	}
+	return CrisisResources{}, false
+}
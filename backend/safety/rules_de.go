@@ -0,0 +1,22 @@
+package safety
+
+// GermanInputRules screen German entries. Go's \b only knows ASCII word
+// characters, so patterns starting or ending in an umlaut leave it off.
+var GermanInputRules = []Rule{
+	rule("suicidal_intent", LevelHigh, `\b(mich\s+umbringen|bringe\s+mich\s+um|mich\s+töten|mir\s+das\s+leben\s+nehmen|mein\s+leben\s+beenden)`),
+	rule("suicide_mention", LevelHigh, `\b(suizid|selbstmord)`),
+	rule("wish_to_die", LevelHigh, `\b(will|möchte|wünsche\s+mir)\s+(zu\s+)?sterben\b|\b(will|möchte)\s+tot\s+sein\b|\blieber\s+tot\b`),
+	rule("not_wanting_to_live", LevelHigh, `\b(will|möchte)\s+nicht\s+mehr\s+(leben|aufwachen)\b|\bnicht\s+mehr\s+leben\s+(will|möchte)|\bkeinen\s+grund\s+(mehr\s+)?zu\s+leben\b`),
+	rule("self_harm", LevelHigh, `\b(mich\s+ritzen|ritze\s+mich|mich\s+verletzen|verletze\s+mich|selbstverletz)`),
+	rule("overdose", LevelHigh, `überdosis`),
+	rule("hopelessness", LevelModerate, `\b(hoffnungslos|kann\s+nicht\s+mehr|keinen\s+ausweg)`),
+	rule("burden", LevelModerate, `\b(bin\s+(nur\s+)?eine\s+last|ohne\s+mich\s+besser\s+dran)`),
+	rule("disappear", LevelModerate, `\b(will|möchte)\s+(einfach\s+)?verschwinden\b`),
+}
+
+// GermanOutputRules screen replies written in German.
+var GermanOutputRules = []Rule{
+	rule("encourages_harm", LevelHigh, `\bdu\s+solltest\s+(dich\s+(umbringen|t[öo]ten|verletzen|ritzen)|dir\s+(etwas|was)\s+antun)`),
+	rule("method_details", LevelHigh, `\b(t[öo]dliche\s+dosis|wie\s+man\s+sich\s+(erh[äa]ngt|umbringt|ritzt)|wie\s+man\s+eine\s+[üu]berdosis)`),
+	rule("dismisses_risk", LevelModerate, `\b(du\s+[üu]bertreibst|stell\s+dich\s+nicht\s+so\s+an|rei(ß|ss)\s+dich\s+(einfach\s+)?zusammen)`),
+}
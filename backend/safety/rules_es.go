@@ -0,0 +1,22 @@
+package safety
+
+// SpanishInputRules screen Spanish entries. Go's \b only knows ASCII word
+// characters, so patterns ending in an accented letter leave it off.
+var SpanishInputRules = []Rule{
+	rule("suicidal_intent", LevelHigh, `\b(matarme|suicidarme|quitarme\s+la\s+vida|acabar\s+con\s+mi\s+vida)\b`),
+	rule("suicide_mention", LevelHigh, `\bsuicid(io|a|as|arme)\b`),
+	rule("wish_to_die", LevelHigh, `\b(quiero|quisiera|deseo)\s+(morir(me)?|estar\s+muert[oa])\b|\bmejor\s+muert[oa]\b`),
+	rule("not_wanting_to_live", LevelHigh, `\bno\s+quiero\s+(vivir|seguir\s+viviendo|despertar(me)?)\b|\bsin\s+raz[oó]n\s+para\s+vivir\b`),
+	rule("self_harm", LevelHigh, `\b(cortarme|me\s+corto|lastimarme|hacerme\s+da[ñn]o|autolesi)`),
+	rule("overdose", LevelHigh, `\bsobredosis\b`),
+	rule("hopelessness", LevelModerate, `\b(sin\s+esperanza|no\s+puedo\s+m[aá]s|no\s+hay\s+salida)`),
+	rule("burden", LevelModerate, `\b(soy\s+una\s+carga|estar[ií]an\s+mejor\s+sin\s+m)`),
+	rule("disappear", LevelModerate, `\b(quiero|quisiera)\s+desaparecer\b`),
+}
+
+// SpanishOutputRules screen replies written in Spanish.
+var SpanishOutputRules = []Rule{
+	rule("encourages_harm", LevelHigh, `\b(deber[ií]as|adelante,?)\s+(matarte|suicidarte|lastimarte|cortarte|hacerte\s+da[ñn]o)\b`),
+	rule("method_details", LevelHigh, `\b(dosis\s+letal|c[oó]mo\s+(ahorcarse|ahorcarte|cortarse|cortarte|suicidarse|tomar\s+una\s+sobredosis))`),
+	rule("dismisses_risk", LevelModerate, `\b(est[aá]s\s+exagerando|simplemente\s+sup[eé]ralo|sup[eé]ralo\s+ya)`),
+}
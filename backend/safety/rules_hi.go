@@ -0,0 +1,23 @@
+package safety
+
+// HindiInputRules screen Hindi entries written in Devanagari. Go's \b only
+// knows ASCII word characters, so these patterns match on phrases alone,
+// and list spellings with and without the nukta.
+var HindiInputRules = []Rule{
+	rule("suicidal_intent", LevelHigh, `(खुद|ख़ुद|अपने\s*आप)\s*को\s*(मार|खत्म|ख़त्म)|अपनी\s*जान\s*(ले|दे)`),
+	rule("suicide_mention", LevelHigh, `आत्महत्या|खुदकुशी|ख़ुदकुशी`),
+	rule("wish_to_die", LevelHigh, `मरना\s*चाहत|मर\s*जाना\s*चाहत|मर\s*जाऊं|मर\s*जाऊँ`),
+	rule("not_wanting_to_live", LevelHigh, `जीना\s*नहीं\s*चाहत|जीने\s*की\s*(इच्छा|चाह)\s*नहीं|जीने\s*का\s*कोई\s*(मतलब|कारण|वजह)\s*नहीं`),
+	rule("self_harm", LevelHigh, `(खुद|ख़ुद|अपने\s*आप)\s*को\s*(चोट|नुकसान|नुक़सान|काट)`),
+	rule("overdose", LevelHigh, `ओवरडोज`),
+	rule("hopelessness", LevelModerate, `कोई\s*उम्मीद\s*नहीं|नाउम्मीद|अब\s*और\s*(नहीं\s*सह|सहन\s*नहीं)|कोई\s*रास्ता\s*नहीं`),
+	rule("burden", LevelModerate, `(सब|सबके|परिवार)\s*(पर|के\s*लिए)\s*बोझ|मैं\s*बोझ\s*हूँ`),
+	rule("disappear", LevelModerate, `गायब\s*हो\s*जाना\s*चाहत`),
+}
+
+// HindiOutputRules screen replies written in Hindi.
+var HindiOutputRules = []Rule{
+	rule("encourages_harm", LevelHigh, `(तुम्हें|आपको)\s*(खुद|ख़ुद|अपने\s*आप)\s*को\s*(मार|चोट|नुकसान|नुक़सान|काट)\S*\s*(\S+\s*)?चाहिए`),
+	rule("method_details", LevelHigh, `घातक\s*खुराक|कैसे\s*(फांसी|फाँसी|ओवरडोज)|(फांसी|फाँसी|ओवरडोज)\s*कैसे`),
+	rule("dismisses_risk", LevelModerate, `(तुम|आप)\s*(बहुत\s*)?(ज़्यादा|ज्यादा)\s*सोच\s*रहे|बस\s*भूल\s*जाओ|इससे\s*उबर\s*जाओ`),
+}
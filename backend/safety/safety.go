@@ -0,0 +1,238 @@
+// Package safety screens journal entries and model replies for suicidal
+// ideation and self-harm language.
+package safety
+
+import (
+	"log"
+	"regexp"
+	"sort"
+)
+
+// Risk levels in increasing order of severity
+const (
+	LevelNone     = "none"
+	LevelModerate = "moderate"
+	LevelHigh     = "high"
+)
+
+var levelRank = map[string]int{LevelNone: 0, LevelModerate: 1, LevelHigh: 2}
+
+// Assessment is the outcome of screening one piece of text. Reasons name the
+// rules that matched; they never contain the text itself.
+type Assessment struct {
+	Level   string   `json:"level"`
+	Flagged bool     `json:"flagged"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Classifier assigns a risk level to text. Model-based classifiers plug in
+// through this interface alongside the built-in rules.
+type Classifier interface {
+	Classify(text string) (Assessment, error)
+}
+
+// ClassifierFunc adapts a function, e.g. a call to a moderation model, to
+// the Classifier interface.
+type ClassifierFunc func(text string) (Assessment, error)
+
+func (f ClassifierFunc) Classify(text string) (Assessment, error) {
+	return f(text)
+}
+
+// Rule flags text matching Pattern at Level.
+type Rule struct {
+	Name    string
+	Level   string
+	Pattern *regexp.Regexp
+}
+
+// RuleClassifier is a lexicon/regex classifier that works offline.
+type RuleClassifier struct {
+	rules []Rule
+}
+
+func NewRuleClassifier(rules []Rule) *RuleClassifier {
+	return &RuleClassifier{rules: rules}
+}
+
+func (c *RuleClassifier) Classify(text string) (Assessment, error) {
+	a := Assessment{Level: LevelNone}
+	for _, rule := range c.rules {
+		if rule.Pattern.MatchString(text) {
+			a.Reasons = append(a.Reasons, rule.Name)
+			if levelRank[rule.Level] > levelRank[a.Level] {
+				a.Level = rule.Level
+			}
+		}
+	}
+	return a, nil
+}
+
+// Checker runs every classifier and keeps the most severe result. Text is
+// flagged when that result reaches the threshold level.
+type Checker struct {
+	classifiers []Classifier
+	threshold   string
+}
+
+func NewChecker(threshold string, classifiers ...Classifier) *Checker {
+	if _, ok := levelRank[threshold]; !ok || threshold == LevelNone {
+		threshold = LevelHigh
+	}
+	return &Checker{classifiers: classifiers, threshold: threshold}
+}
+
+// Check screens text. A classifier that errors is logged and skipped so an
+// unavailable model hook never disables the built-in rules.
+func (c *Checker) Check(text string) Assessment {
+	result := Assessment{Level: LevelNone}
+	for _, classifier := range c.classifiers {
+		a, err := classifier.Classify(text)
+		if err != nil {
+			log.Printf("Safety classifier error: %v", err)
+			continue
+		}
+		result.Reasons = append(result.Reasons, a.Reasons...)
+		if levelRank[a.Level] > levelRank[result.Level] {
+			result.Level = a.Level
+		}
+	}
+	result.Flagged = c.Flags(result.Level)
+	return result
+}
+
+// Flags reports whether text assessed at level is flagged.
+func (c *Checker) Flags(level string) bool {
+	return levelRank[level] >= levelRank[c.threshold]
+}
+
+// IsLevel reports whether level is a known risk level.
+func IsLevel(level string) bool {
+	_, ok := levelRank[level]
+	return ok
+}
+
+func rule(name, level, pattern string) Rule {
+	return Rule{Name: name, Level: level, Pattern: regexp.MustCompile(`(?i)` + pattern)}
+}
+
+// InputRules screen what the user wrote.
+var InputRules = []Rule{
+	rule("suicidal_intent", LevelHigh, `\b(kill(ing)?|end(ing)?|take|taking)\s+(my|my own)\s+(self|life)\b|\bkill\s+myself\b`),
+	rule("suicide_mention", LevelHigh, `\bsuicid(e|al)\b`),
+	rule("wish_to_die", LevelHigh, `\b(want|wanna|wish|ready)\s+(to\s+)?(die|be dead)\b|\bbetter\s+off\s+dead\b`),
+	rule("not_wanting_to_live", LevelHigh, `\b(don'?t|do not)\s+want\s+to\s+(live|be alive|wake up)\b|\bno\s+reason\s+to\s+live\b`),
+	rule("self_harm", LevelHigh, `\b(cut(ting)?|hurt(ing)?|harm(ing)?|burn(ing)?)\s+myself\b|\bself[-\s]?harm`),
+	rule("overdose", LevelHigh, `\boverdos(e|ing)\b`),
+	rule("hopelessness", LevelModerate, `\b(hopeless|can'?t\s+go\s+on|can'?t\s+take\s+it\s+anymore|no\s+way\s+out)\b`),
+	rule("burden", LevelModerate, `\b(burden\s+to\s+(everyone|everybody|my family)|everyone\s+would\s+be\s+better\s+off\s+without\s+me)\b`),
+	rule("disappear", LevelModerate, `\b(want|wish)\s+(i\s+could\s+)?(to\s+)?disappear\b`),
+}
+
+// languageInputRules holds the rules of each language other than English,
+// keyed by ISO 639-1 code.
+var languageInputRules = map[string][]Rule{
+	"es": SpanishInputRules,
+	"de": GermanInputRules,
+	"hi": HindiInputRules,
+}
+
+// languageOutputRules holds the output rules of each language other than
+// English, for replies written in the entry's language.
+var languageOutputRules = map[string][]Rule{
+	"es": SpanishOutputRules,
+	"de": GermanOutputRules,
+	"hi": HindiOutputRules,
+}
+
+// InputRulesFor returns the input rules for a language code: the English
+// rules plus the language's own. English always applies, since entries
+// mix languages. A language without rules of its own, including "" for
+// text too short or mixed to detect, gets every language's rules.
+func InputRulesFor(lang string) []Rule {
+	return rulesFor(InputRules, languageInputRules, lang)
+}
+
+// OutputRulesFor returns the output rules for a language code, combined
+// the same way as InputRulesFor.
+func OutputRulesFor(lang string) []Rule {
+	return rulesFor(OutputRules, languageOutputRules, lang)
+}
+
+func rulesFor(english []Rule, byLanguage map[string][]Rule, lang string) []Rule {
+	rules := append([]Rule{}, english...)
+	if lang == "en" {
+		return rules
+	}
+	if own, ok := byLanguage[lang]; ok {
+		return append(rules, own...)
+	}
+	for _, code := range sortedLanguages(byLanguage) {
+		rules = append(rules, byLanguage[code]...)
+	}
+	return rules
+}
+
+// sortedLanguages returns the language codes of byLanguage in order, so
+// rules are combined the same way on every run.
+func sortedLanguages(byLanguage map[string][]Rule) []string {
+	codes := make([]string, 0, len(byLanguage))
+	for code := range byLanguage {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// LanguageCheckers holds a Checker for each language with rules.
+type LanguageCheckers struct {
+	checkers map[string]*Checker
+	fallback *Checker
+}
+
+// NewInputCheckers builds a Checker for each language from InputRulesFor,
+// adding classifiers, such as a moderation model, to every one.
+func NewInputCheckers(threshold string, classifiers ...Classifier) *LanguageCheckers {
+	return newLanguageCheckers(threshold, InputRulesFor, languageInputRules, classifiers)
+}
+
+// NewOutputCheckers builds a Checker for each language from OutputRulesFor.
+func NewOutputCheckers(threshold string, classifiers ...Classifier) *LanguageCheckers {
+	return newLanguageCheckers(threshold, OutputRulesFor, languageOutputRules, classifiers)
+}
+
+func newLanguageCheckers(threshold string, rulesFor func(lang string) []Rule, byLanguage map[string][]Rule, classifiers []Classifier) *LanguageCheckers {
+	build := func(lang string) *Checker {
+		return NewChecker(threshold, append([]Classifier{NewRuleClassifier(rulesFor(lang))}, classifiers...)...)
+	}
+	c := &LanguageCheckers{checkers: make(map[string]*Checker), fallback: build("")}
+	c.checkers["en"] = build("en")
+	for lang := range byLanguage {
+		c.checkers[lang] = build(lang)
+	}
+	return c
+}
+
+// ForLanguage returns the checker for a language code. Text in a language
+// without rules of its own, or whose language wasn't detected, is checked
+// against the rules of every language.
+func (c *LanguageCheckers) ForLanguage(lang string) *Checker {
+	if checker, ok := c.checkers[lang]; ok {
+		return checker
+	}
+	return c.fallback
+}
+
+// AnyLanguage returns the checker with the rules of every language, for
+// text that may be written in any of them.
+func (c *LanguageCheckers) AnyLanguage() *Checker {
+	return c.fallback
+}
+
+// OutputRules screen the model's reply for content that must never reach a
+// user in crisis.
+var OutputRules = []Rule{
+	rule("encourages_harm", LevelHigh, `\b(you\s+should|go\s+ahead\s+and)\s+(kill|hurt|harm|cut)\s+yourself\b`),
+	rule("method_details", LevelHigh, `\b(lethal\s+dose|how\s+to\s+(overdose|hang|cut))\b`),
+	rule("dismisses_risk", LevelModerate, `\b(you('re|\s+are)\s+overreacting|just\s+get\s+over\s+it)\b`),
+}
@@ -0,0 +1,87 @@
+package safety
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestInputCheckers(t *testing.T) {
+	checkers := NewInputCheckers(LevelModerate)
+
+	tests := []struct {
+		name   string
+		lang   string
+		text   string
+		level  string
+		reason string
+	}{
+		{"english wish to die", "en", "Some days I just want to die.", LevelHigh, "wish_to_die"},
+		{"english hopelessness", "en", "Everything feels hopeless lately.", LevelModerate, "hopelessness"},
+		{"english calm", "en", "Went for a walk and felt calm afterwards.", LevelNone, ""},
+		{"spanish wish to die", "es", "Quiero morir, no aguanto esto.", LevelHigh, "wish_to_die"},
+		{"spanish accented ending", "es", "Ya no puedo más con todo.", LevelModerate, "hopelessness"},
+		{"spanish calm", "es", "Hoy fue un buen día en el trabajo.", LevelNone, ""},
+		{"german not wanting to live", "de", "Ich will nicht mehr leben.", LevelHigh, "not_wanting_to_live"},
+		{"german umlaut start", "de", "Ich habe an eine Überdosis gedacht.", LevelHigh, "overdose"},
+		{"hindi suicide mention", "hi", "मैं आत्महत्या के बारे में सोचता हूँ", LevelHigh, "suicide_mention"},
+		{"english rules apply to other languages", "es", "I want to die", LevelHigh, "wish_to_die"},
+		{"undetected gets every language", "", "Quiero morir.", LevelHigh, "wish_to_die"},
+		{"undetected hindi", "", "आत्महत्या", LevelHigh, "suicide_mention"},
+		{"unknown language gets every language", "fr", "Ich will sterben.", LevelHigh, "wish_to_die"},
+		{"other language rules stay out", "en", "Quiero morir.", LevelNone, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := checkers.ForLanguage(tt.lang).Check(tt.text)
+			if a.Level != tt.level {
+				t.Fatalf("level = %q, want %q (reasons %v)", a.Level, tt.level, a.Reasons)
+			}
+			if a.Flagged != (tt.level != LevelNone) {
+				t.Errorf("flagged = %v at level %q", a.Flagged, a.Level)
+			}
+			if tt.reason != "" && !slices.Contains(a.Reasons, tt.reason) {
+				t.Errorf("reasons = %v, want %q among them", a.Reasons, tt.reason)
+			}
+		})
+	}
+}
+
+func TestOutputCheckers(t *testing.T) {
+	checkers := NewOutputCheckers(LevelHigh)
+
+	tests := []struct {
+		name    string
+		lang    string
+		text    string
+		level   string
+		flagged bool
+	}{
+		{"english encourages harm", "en", "You should hurt yourself.", LevelHigh, true},
+		{"english dismisses risk", "en", "Honestly, you're overreacting.", LevelModerate, false},
+		{"english supportive", "en", "It sounds like a hard week. Be gentle with yourself.", LevelNone, false},
+		{"spanish method details", "es", "La dosis letal es alta.", LevelHigh, true},
+		{"spanish dismisses risk", "es", "Estás exagerando.", LevelModerate, false},
+		{"german encourages harm", "de", "Du solltest dich verletzen.", LevelHigh, true},
+		{"hindi method details", "hi", "घातक खुराक", LevelHigh, true},
+		{"undetected gets every language", "", "Du solltest dich verletzen.", LevelHigh, true},
+		{"other language rules stay out", "en", "Du solltest dich verletzen.", LevelNone, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := checkers.ForLanguage(tt.lang).Check(tt.text)
+			if a.Level != tt.level || a.Flagged != tt.flagged {
+				t.Errorf("got level %q flagged %v, want %q flagged %v (reasons %v)",
+					a.Level, a.Flagged, tt.level, tt.flagged, a.Reasons)
+			}
+		})
+	}
+}
+
+func TestAnyLanguageMatchesFallback(t *testing.T) {
+	checkers := NewOutputCheckers(LevelHigh)
+	if checkers.AnyLanguage() != checkers.ForLanguage("") {
+		t.Error("AnyLanguage should be the checker for undetected text")
+	}
+}
@@ -0,0 +1,145 @@
+package sentiment
+
+import (
+	"math"
+	"sort"
+)
+
+// Plutchik's eight basic emotions.
+const (
+	Joy          = "joy"
+	Trust        = "trust"
+	Fear         = "fear"
+	Surprise     = "surprise"
+	Sadness      = "sadness"
+	Disgust      = "disgust"
+	Anger        = "anger"
+	Anticipation = "anticipation"
+)
+
+// EmotionLabels lists the emotions in Plutchik's wheel order.
+var EmotionLabels = []string{Joy, Trust, Fear, Surprise, Sadness, Disgust, Anger, Anticipation}
+
+// opposites pairs each emotion with the one across the wheel from it. A
+// negated emotion word partly expresses its opposite ("not happy").
+var opposites = map[string]string{
+	Joy: Sadness, Sadness: Joy,
+	Trust: Disgust, Disgust: Trust,
+	Fear: Anger, Anger: Fear,
+	Surprise: Anticipation, Anticipation: Surprise,
+}
+
+const (
+	// emotionAlpha controls how quickly summed evidence saturates: one
+	// word naming an emotion outright scores about 0.58, three about 0.9
+	emotionAlpha = 2.0
+
+	// negatedOppositeWeight is how much of a negated word's weight moves
+	// to the opposite emotion
+	negatedOppositeWeight = 0.5
+
+	// MinEmotionIntensity is the lowest intensity reported
+	MinEmotionIntensity = 0.1
+)
+
+// EmotionScore is the intensity, from 0 to 1, of one emotion in a text.
+type EmotionScore struct {
+	Emotion   string  `json:"emotion"`
+	Intensity float64 `json:"intensity"`
+}
+
+// IsEmotion reports whether label is one of the eight Plutchik emotions.
+func IsEmotion(label string) bool {
+	_, ok := opposites[label]
+	return ok
+}
+
+// ClassifyEmotions scores text against the English lexicon.
+func ClassifyEmotions(text string) []EmotionScore {
+	return defaultAnalyzer.Emotions(text)
+}
+
+// Emotions scores every emotion the text expresses, strongest first. Each
+// emotion word contributes its weight, scaled by intensifiers and moved to
+// the opposite emotion when negated; the sums are normalised to [0, 1] and
+// emotions below MinEmotionIntensity are left out.
+func (a *Analyzer) Emotions(text string) []EmotionScore {
+	tokens := tokenize(text)
+	if len(tokens) == 0 || a.lexicon.Emotions == nil {
+		return nil
+	}
+
+	contrastAt := -1
+	for i, tok := range tokens {
+		if a.lexicon.Contrast[tok.lower] {
+			contrastAt = i
+			break
+		}
+	}
+
+	sums := make(map[string]float64)
+	for i, tok := range tokens {
+		weights, ok := a.lexicon.Emotions[tok.lower]
+		if !ok {
+			continue
+		}
+
+		scale := 1.0
+		for dist := 1; dist <= 3 && i-dist >= 0; dist++ {
+			if inc, ok := a.lexicon.Boosters[tokens[i-dist].lower]; ok {
+				scale += inc * []float64{1, 0.95, 0.9}[dist-1]
+			}
+		}
+
+		negated := a.negated(tokens, i)
+
+		// As with valence, what follows "but" outweighs what precedes it
+		if contrastAt >= 0 && i < contrastAt {
+			scale *= 0.5
+		} else if contrastAt >= 0 && i > contrastAt {
+			scale *= 1.5
+		}
+
+		for emotion, weight := range weights {
+			if negated {
+				sums[opposites[emotion]] += weight * scale * negatedOppositeWeight
+				continue
+			}
+			sums[emotion] += weight * scale
+		}
+	}
+
+	var scores []EmotionScore
+	for _, emotion := range EmotionLabels {
+		sum := math.Max(0, sums[emotion])
+		intensity := round4(sum / math.Sqrt(sum*sum+emotionAlpha))
+		if intensity < MinEmotionIntensity {
+			continue
+		}
+		scores = append(scores, EmotionScore{Emotion: emotion, Intensity: intensity})
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Intensity > scores[j].Intensity
+	})
+	return scores
+}
+
+// EmotionWeights maps a free-form emotion word, such as one named by the
+// model ("anxious", "overwhelmed"), onto Plutchik emotions. It returns nil
+// for words the lexicon doesn't know.
+func (a *Analyzer) EmotionWeights(word string) map[string]float64 {
+	tokens := tokenize(word)
+	if len(tokens) != 1 {
+		return nil
+	}
+	if IsEmotion(tokens[0].lower) {
+		return map[string]float64{tokens[0].lower: 1}
+	}
+	return a.lexicon.Emotions[tokens[0].lower]
+}
+
+// EmotionWeights maps a free-form emotion word using the English lexicon.
+func EmotionWeights(word string) map[string]float64 {
+	return defaultAnalyzer.EmotionWeights(word)
+}
@@ -0,0 +1,222 @@
+package sentiment
+
+// englishEmotions is a journaling-oriented subset of the NRC Emotion Lexicon
+// with hand-set weights: 1 for words that name the emotion outright, lower
+// for words that only suggest it.
+var englishEmotions = map[string]map[string]float64{
+	// Joy
+	"happy":        {Joy: 1},
+	"happier":      {Joy: 1},
+	"happiest":     {Joy: 1},
+	"happiness":    {Joy: 1},
+	"joy":          {Joy: 1},
+	"joyful":       {Joy: 1},
+	"glad":         {Joy: 0.8},
+	"cheerful":     {Joy: 0.8},
+	"delighted":    {Joy: 1, Surprise: 0.3},
+	"excited":      {Joy: 0.8, Anticipation: 0.6},
+	"exciting":     {Joy: 0.6, Anticipation: 0.5},
+	"thrilled":     {Joy: 1, Surprise: 0.4},
+	"love":         {Joy: 0.8, Trust: 0.6},
+	"loved":        {Joy: 0.8, Trust: 0.6},
+	"loving":       {Joy: 0.7, Trust: 0.6},
+	"enjoy":        {Joy: 0.7},
+	"enjoyed":      {Joy: 0.7},
+	"fun":          {Joy: 0.7},
+	"great":        {Joy: 0.5},
+	"wonderful":    {Joy: 0.8, Surprise: 0.2},
+	"amazing":      {Joy: 0.8, Surprise: 0.4},
+	"awesome":      {Joy: 0.7, Surprise: 0.3},
+	"good":         {Joy: 0.4},
+	"better":       {Joy: 0.4},
+	"calm":         {Joy: 0.4, Trust: 0.4},
+	"peaceful":     {Joy: 0.6, Trust: 0.4},
+	"relaxed":      {Joy: 0.5, Trust: 0.3},
+	"relieved":     {Joy: 0.6, Trust: 0.3},
+	"relief":       {Joy: 0.6, Trust: 0.3},
+	"content":      {Joy: 0.5},
+	"grateful":     {Joy: 0.7, Trust: 0.6},
+	"thankful":     {Joy: 0.7, Trust: 0.6},
+	"gratitude":    {Joy: 0.7, Trust: 0.6},
+	"proud":        {Joy: 0.7, Anticipation: 0.2},
+	"accomplished": {Joy: 0.7},
+	"smile":        {Joy: 0.6},
+	"smiled":       {Joy: 0.6},
+	"laugh":        {Joy: 0.7, Surprise: 0.2},
+	"laughed":      {Joy: 0.7, Surprise: 0.2},
+	"celebrate":    {Joy: 0.8, Anticipation: 0.3},
+	"celebrated":   {Joy: 0.8},
+	"energized":    {Joy: 0.6, Anticipation: 0.4},
+	"motivated":    {Joy: 0.4, Anticipation: 0.7},
+	"optimistic":   {Joy: 0.6, Anticipation: 0.7, Trust: 0.4},
+	"hopeful":      {Joy: 0.5, Anticipation: 0.8, Trust: 0.4},
+	"hope":         {Joy: 0.4, Anticipation: 0.8, Trust: 0.3},
+
+	// Trust
+	"trust":      {Trust: 1},
+	"trusted":    {Trust: 1},
+	"safe":       {Trust: 0.7, Joy: 0.3},
+	"secure":     {Trust: 0.7},
+	"supported":  {Trust: 0.8, Joy: 0.4},
+	"support":    {Trust: 0.6},
+	"supportive": {Trust: 0.8, Joy: 0.3},
+	"confident":  {Trust: 0.7, Joy: 0.4},
+	"reassured":  {Trust: 0.8, Joy: 0.3},
+	"understood": {Trust: 0.7, Joy: 0.3},
+	"accepted":   {Trust: 0.7, Joy: 0.4},
+	"belong":     {Trust: 0.7, Joy: 0.3},
+	"friend":     {Trust: 0.5, Joy: 0.3},
+	"friends":    {Trust: 0.5, Joy: 0.3},
+	"family":     {Trust: 0.4},
+	"honest":     {Trust: 0.6},
+	"rely":       {Trust: 0.7},
+	"connected":  {Trust: 0.6, Joy: 0.4},
+	"admire":     {Trust: 0.7, Joy: 0.3},
+
+	// Fear
+	"afraid":      {Fear: 1},
+	"scared":      {Fear: 1},
+	"fear":        {Fear: 1},
+	"frightened":  {Fear: 1, Surprise: 0.3},
+	"terrified":   {Fear: 1, Surprise: 0.3},
+	"anxious":     {Fear: 0.9, Anticipation: 0.4},
+	"anxiety":     {Fear: 0.9, Anticipation: 0.4},
+	"nervous":     {Fear: 0.8, Anticipation: 0.4},
+	"worried":     {Fear: 0.8, Anticipation: 0.4},
+	"worry":       {Fear: 0.8, Anticipation: 0.4},
+	"worrying":    {Fear: 0.8, Anticipation: 0.4},
+	"panic":       {Fear: 1, Surprise: 0.4},
+	"panicked":    {Fear: 1, Surprise: 0.4},
+	"dread":       {Fear: 0.9, Anticipation: 0.5},
+	"stressed":    {Fear: 0.6, Anger: 0.2, Sadness: 0.2},
+	"stress":      {Fear: 0.6, Anger: 0.2, Sadness: 0.2},
+	"overwhelmed": {Fear: 0.7, Sadness: 0.5},
+	"tense":       {Fear: 0.6, Anger: 0.3},
+	"uneasy":      {Fear: 0.6},
+	"insecure":    {Fear: 0.6, Sadness: 0.3},
+	"unsafe":      {Fear: 0.8},
+	"threatened":  {Fear: 0.8, Anger: 0.3},
+	"uncertain":   {Fear: 0.5, Anticipation: 0.3},
+	"vulnerable":  {Fear: 0.6, Sadness: 0.3},
+	"deadline":    {Fear: 0.3, Anticipation: 0.5},
+	"deadlines":   {Fear: 0.3, Anticipation: 0.5},
+
+	// Surprise
+	"surprised":  {Surprise: 1},
+	"surprise":   {Surprise: 1},
+	"surprising": {Surprise: 0.9},
+	"shocked":    {Surprise: 1, Fear: 0.3},
+	"shock":      {Surprise: 1, Fear: 0.3},
+	"astonished": {Surprise: 1},
+	"amazed":     {Surprise: 0.9, Joy: 0.5},
+	"unexpected": {Surprise: 0.8},
+	"suddenly":   {Surprise: 0.6},
+	"sudden":     {Surprise: 0.6},
+	"stunned":    {Surprise: 0.9},
+	"confused":   {Surprise: 0.5, Fear: 0.2},
+	"realized":   {Surprise: 0.4},
+
+	// Sadness
+	"sad":           {Sadness: 1},
+	"sadness":       {Sadness: 1},
+	"unhappy":       {Sadness: 0.9},
+	"depressed":     {Sadness: 1},
+	"depression":    {Sadness: 1},
+	"down":          {Sadness: 0.5},
+	"blue":          {Sadness: 0.4},
+	"lonely":        {Sadness: 0.9, Fear: 0.2},
+	"alone":         {Sadness: 0.6, Fear: 0.2},
+	"isolated":      {Sadness: 0.7, Fear: 0.2},
+	"hopeless":      {Sadness: 1, Fear: 0.3},
+	"helpless":      {Sadness: 0.8, Fear: 0.5},
+	"cry":           {Sadness: 0.9},
+	"cried":         {Sadness: 0.9},
+	"crying":        {Sadness: 0.9},
+	"tears":         {Sadness: 0.8},
+	"grief":         {Sadness: 1},
+	"grieving":      {Sadness: 1},
+	"loss":          {Sadness: 0.8},
+	"lost":          {Sadness: 0.6, Fear: 0.2},
+	"miss":          {Sadness: 0.6},
+	"missed":        {Sadness: 0.5},
+	"missing":       {Sadness: 0.6},
+	"heartbroken":   {Sadness: 1},
+	"hurt":          {Sadness: 0.7, Anger: 0.3},
+	"disappointed":  {Sadness: 0.8, Anger: 0.3},
+	"disappointing": {Sadness: 0.7, Anger: 0.3},
+	"empty":         {Sadness: 0.7},
+	"numb":          {Sadness: 0.6},
+	"tired":         {Sadness: 0.4},
+	"exhausted":     {Sadness: 0.6},
+	"drained":       {Sadness: 0.6},
+	"miserable":     {Sadness: 1, Disgust: 0.2},
+	"guilty":        {Sadness: 0.7, Fear: 0.3},
+	"guilt":         {Sadness: 0.7, Fear: 0.3},
+	"regret":        {Sadness: 0.8},
+	"sorry":         {Sadness: 0.5},
+	"worthless":     {Sadness: 0.9, Disgust: 0.4},
+	"failure":       {Sadness: 0.7, Fear: 0.3},
+	"failed":        {Sadness: 0.6, Fear: 0.2},
+
+	// Disgust
+	"disgusted":   {Disgust: 1, Anger: 0.3},
+	"disgusting":  {Disgust: 1, Anger: 0.3},
+	"gross":       {Disgust: 0.8},
+	"sick":        {Disgust: 0.5, Sadness: 0.3},
+	"ashamed":     {Disgust: 0.6, Sadness: 0.6},
+	"shame":       {Disgust: 0.6, Sadness: 0.6},
+	"embarrassed": {Disgust: 0.4, Sadness: 0.4, Fear: 0.3},
+	"awful":       {Disgust: 0.6, Sadness: 0.4},
+	"terrible":    {Disgust: 0.5, Sadness: 0.4, Fear: 0.3},
+	"horrible":    {Disgust: 0.6, Fear: 0.4},
+	"hate":        {Disgust: 0.7, Anger: 0.8},
+	"hated":       {Disgust: 0.7, Anger: 0.8},
+	"repulsed":    {Disgust: 1},
+	"toxic":       {Disgust: 0.8, Anger: 0.3},
+	"fake":        {Disgust: 0.5, Anger: 0.3},
+
+	// Anger
+	"angry":       {Anger: 1},
+	"anger":       {Anger: 1},
+	"mad":         {Anger: 0.9},
+	"furious":     {Anger: 1, Disgust: 0.3},
+	"rage":        {Anger: 1},
+	"annoyed":     {Anger: 0.7, Disgust: 0.3},
+	"annoying":    {Anger: 0.6, Disgust: 0.3},
+	"irritated":   {Anger: 0.7, Disgust: 0.3},
+	"frustrated":  {Anger: 0.8, Sadness: 0.3},
+	"frustrating": {Anger: 0.7, Sadness: 0.2},
+	"frustration": {Anger: 0.8, Sadness: 0.3},
+	"resent":      {Anger: 0.8, Disgust: 0.4},
+	"resentful":   {Anger: 0.8, Disgust: 0.4},
+	"bitter":      {Anger: 0.6, Sadness: 0.4, Disgust: 0.3},
+	"unfair":      {Anger: 0.7, Disgust: 0.3},
+	"betrayed":    {Anger: 0.8, Sadness: 0.7, Disgust: 0.4},
+	"argued":      {Anger: 0.7},
+	"argument":    {Anger: 0.7},
+	"fight":       {Anger: 0.8, Fear: 0.3},
+	"yelled":      {Anger: 0.8},
+	"snapped":     {Anger: 0.6},
+	"jealous":     {Anger: 0.6, Sadness: 0.3, Disgust: 0.3},
+
+	// Anticipation
+	"anticipate":   {Anticipation: 1},
+	"anticipation": {Anticipation: 1},
+	"expect":       {Anticipation: 0.7},
+	"expecting":    {Anticipation: 0.7},
+	"waiting":      {Anticipation: 0.6},
+	"eager":        {Anticipation: 0.9, Joy: 0.4},
+	"curious":      {Anticipation: 0.8, Surprise: 0.3},
+	"planning":     {Anticipation: 0.6},
+	"plan":         {Anticipation: 0.5},
+	"plans":        {Anticipation: 0.5},
+	"goal":         {Anticipation: 0.6},
+	"goals":        {Anticipation: 0.6},
+	"tomorrow":     {Anticipation: 0.4},
+	"soon":         {Anticipation: 0.4},
+	"upcoming":     {Anticipation: 0.6},
+	"determined":   {Anticipation: 0.8, Trust: 0.3},
+	"ready":        {Anticipation: 0.6, Trust: 0.3},
+	"interview":    {Anticipation: 0.6, Fear: 0.3},
+	"exam":         {Anticipation: 0.5, Fear: 0.4},
+}
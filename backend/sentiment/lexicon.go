@@ -0,0 +1,109 @@
+package sentiment
+
+import "strings"
+
+// Lexicon holds the word lists for one language. Valence values use the
+// VADER scale from -4 (most negative) to +4 (most positive).
+type Lexicon struct {
+	Valence   map[string]float64
+	Boosters  map[string]float64
+	Negations map[string]bool
+	Contrast  map[string]bool
+
+	// Emotions maps words to the Plutchik emotions they express, each with
+	// a weight from 0 to 1
+	Emotions map[string]map[string]float64
+
+	// NegationSuffix marks contracted negations such as "n't"
+	NegationSuffix string
+
+	// NegationFollows is set for languages such as Hindi where a negation
+	// can also come after the word it negates ("खुश नहीं")
+	NegationFollows bool
+}
+
+func (l *Lexicon) isNegation(word string) bool {
+	if l.Negations[word] {
+		return true
+	}
+	return l.NegationSuffix != "" && strings.HasSuffix(word, l.NegationSuffix)
+}
+
+// known counts the tokens the lexicon has any use for.
+func (l *Lexicon) known(tokens []token) int {
+	n := 0
+	for _, tok := range tokens {
+		_, valence := l.Valence[tok.lower]
+		_, booster := l.Boosters[tok.lower]
+		if valence || booster || l.Contrast[tok.lower] || l.isNegation(tok.lower) {
+			n++
+		}
+	}
+	return n
+}
+
+// English is a journaling-oriented subset of the VADER lexicon.
+var English = &Lexicon{
+	Valence: map[string]float64{
+		// Positive
+		"accomplished": 1.9, "amazing": 2.8, "appreciate": 1.7, "appreciated": 2.3,
+		"awesome": 3.1, "beautiful": 2.9, "best": 3.2, "better": 1.9, "blessed": 2.9,
+		"brave": 2.4, "bright": 1.9, "calm": 1.3, "capable": 1.6, "care": 2.2,
+		"celebrate": 2.7, "cheerful": 2.5, "comfort": 1.5, "comfortable": 1.6,
+		"confident": 2.2, "content": 1.4, "cool": 1.3, "delighted": 2.9, "energized": 2.0,
+		"enjoy": 2.2, "enjoyed": 2.3, "excited": 1.4, "excellent": 2.7, "fantastic": 2.6,
+		"fine": 0.8, "free": 2.3, "friendly": 2.2, "fun": 2.3, "glad": 2.0, "good": 1.9,
+		"grateful": 2.0, "great": 3.1, "happy": 2.7, "healthy": 1.7, "helpful": 1.8,
+		"hope": 1.9, "hopeful": 2.3, "inspired": 2.2, "joy": 2.8, "joyful": 2.9,
+		"kind": 2.4, "laugh": 2.6, "laughed": 2.0, "like": 1.5, "love": 3.2, "loved": 2.9,
+		"lovely": 2.8, "lucky": 1.8, "motivated": 1.8, "nice": 1.8, "okay": 0.9,
+		"ok": 1.2, "optimistic": 2.3, "peace": 2.5, "peaceful": 2.2, "perfect": 2.7,
+		"pleasant": 2.3, "pleased": 1.9, "positive": 2.6, "productive": 1.7,
+		"progress": 1.5, "proud": 2.1, "refreshed": 2.1, "relaxed": 2.2, "relief": 2.1,
+		"relieved": 1.5, "rested": 1.5, "safe": 1.9, "satisfied": 1.8, "smile": 1.5,
+		"smiled": 2.5, "strong": 2.3, "success": 2.7, "successful": 2.8, "support": 1.7,
+		"supported": 1.3, "thankful": 2.7, "thanks": 1.9, "win": 2.8, "wonderful": 2.7,
+		"worth": 0.9, "yay": 2.4,
+
+		// Negative
+		"abandoned": -2.1, "afraid": -2.2, "alone": -1.0, "angry": -2.3, "anxiety": -0.7,
+		"anxious": -1.0, "annoyed": -1.6, "ashamed": -2.1, "awful": -2.0, "bad": -2.5,
+		"bored": -1.1, "broken": -2.1, "burden": -1.9, "burnout": -2.0, "cry": -2.1,
+		"cried": -1.6, "crying": -2.1, "depressed": -2.3, "depression": -2.7,
+		"desperate": -1.3, "disappointed": -1.9, "disappointing": -2.2, "down": -0.8,
+		"drained": -1.5, "dread": -2.0, "empty": -0.8, "exhausted": -1.5, "fail": -2.5,
+		"failed": -2.3, "failure": -2.3, "fear": -2.2, "frustrated": -2.4,
+		"frustrating": -1.9, "grief": -2.2, "guilty": -1.8, "hate": -2.7, "hated": -3.2,
+		"hopeless": -2.0, "hurt": -2.4, "hurts": -2.1, "irritated": -2.0,
+		"lonely": -1.5, "lost": -1.3, "miserable": -2.2, "miss": -0.6, "nervous": -1.1,
+		"numb": -1.4, "overwhelmed": -1.5, "pain": -2.3, "painful": -2.4, "panic": -2.3,
+		"regret": -1.8, "rejected": -2.3, "sad": -2.1, "scared": -1.9, "sick": -2.3,
+		"sorry": -0.3, "stress": -1.8, "stressed": -1.4, "stressful": -2.3,
+		"struggle": -1.3, "struggling": -1.4, "suffer": -2.5, "terrible": -2.1,
+		"tired": -1.9, "trapped": -2.4, "ugly": -2.3, "unhappy": -1.8, "upset": -1.6,
+		"useless": -1.8, "weak": -1.9, "worried": -1.2, "worry": -1.9, "worse": -2.1,
+		"worst": -3.1, "worthless": -1.9,
+	},
+	Boosters: map[string]float64{
+		"absolutely": boosterIncrement, "completely": boosterIncrement,
+		"deeply": boosterIncrement, "especially": boosterIncrement,
+		"extremely": boosterIncrement, "incredibly": boosterIncrement,
+		"really": boosterIncrement, "so": boosterIncrement, "super": boosterIncrement,
+		"totally": boosterIncrement, "truly": boosterIncrement, "very": boosterIncrement,
+		"barely": -boosterIncrement, "kinda": -boosterIncrement,
+		"slightly": -boosterIncrement, "somewhat": -boosterIncrement,
+		"little": -boosterIncrement, "partly": -boosterIncrement,
+	},
+	Negations: map[string]bool{
+		"not": true, "no": true, "never": true, "nothing": true, "nobody": true,
+		"none": true, "neither": true, "nor": true, "nowhere": true, "without": true,
+		"cannot": true, "cant": true, "dont": true, "isnt": true, "wasnt": true,
+		"arent": true, "wont": true, "didnt": true, "doesnt": true, "hardly": true,
+		"rarely": true, "seldom": true,
+	},
+	Contrast: map[string]bool{
+		"but": true, "however": true, "although": true, "though": true,
+	},
+	Emotions:       englishEmotions,
+	NegationSuffix: "n't",
+}
@@ -0,0 +1,126 @@
+package sentiment
+
+// German is a journaling-oriented German lexicon, scored on the VADER scale
+// by analogy with the English entries. Common inflected adjective forms are
+// listed alongside the base form.
+var German = &Lexicon{
+	Valence: map[string]float64{
+		// Positiv
+		"glücklich": 2.7, "glückliche": 2.7, "glücklichen": 2.7, "froh": 2.0,
+		"fröhlich": 2.5, "freude": 2.8, "freue": 2.3, "gut": 1.9, "gute": 1.9,
+		"guten": 1.9, "gutes": 1.9, "besser": 1.9, "toll": 2.8, "tolle": 2.8,
+		"super": 2.9, "wunderbar": 2.8, "schön": 2.5, "schöne": 2.5, "schönen": 2.5,
+		"großartig": 3.1, "ruhig": 1.3, "entspannt": 1.8, "erleichtert": 1.6,
+		"erleichterung": 1.6, "dankbar": 2.0, "danke": 1.9, "stolz": 2.1,
+		"liebe": 3.2, "hoffnung": 1.9, "hoffnungsvoll": 2.3, "aufgeregt": 1.4,
+		"motiviert": 1.8, "sicher": 1.5, "spaß": 2.3, "genossen": 2.3,
+		"geschafft": 1.9, "erfolg": 2.7, "zufrieden": 2.0, "gelacht": 2.1,
+		"unterstützt": 1.7, "frieden": 2.5,
+
+		// Negativ
+		"traurig": -2.1, "traurige": -2.1, "trauer": -2.1, "schlecht": -2.5,
+		"schlechte": -2.5, "schlechten": -2.5, "schlimm": -2.1, "schrecklich": -2.1,
+		"schrecklichen": -2.1, "furchtbar": -2.5, "müde": -1.9, "erschöpft": -2.1,
+		"einsam": -1.5, "einsamkeit": -1.5, "allein": -1.2, "angst": -2.2,
+		"ängstlich": -1.7, "nervös": -1.1, "besorgt": -1.2, "sorgen": -1.9,
+		"stress": -1.8, "gestresst": -1.4, "panik": -2.3, "wütend": -2.0,
+		"wut": -2.7, "sauer": -1.8, "ärger": -2.0, "genervt": -1.8,
+		"frustriert": -1.9, "enttäuscht": -1.9, "deprimiert": -2.3,
+		"depression": -2.5, "geweint": -2.1, "weinen": -2.1, "schmerz": -2.3,
+		"schmerzen": -2.3, "schuld": -1.8, "schuldig": -1.8, "scham": -1.9,
+		"schäme": -1.9, "hass": -2.7, "hasse": -2.7, "nutzlos": -1.8,
+		"versagt": -2.2, "versager": -2.4, "überfordert": -1.5, "verloren": -1.3,
+		"hoffnungslos": -2.0, "schwer": -1.3, "schwierig": -1.5, "problem": -1.7,
+		"streit": -1.9, "verletzt": -2.4,
+	},
+	Boosters: map[string]float64{
+		"sehr": boosterIncrement, "so": boosterIncrement, "total": boosterIncrement,
+		"wirklich": boosterIncrement, "extrem": boosterIncrement, "echt": boosterIncrement,
+		"richtig": boosterIncrement, "besonders": boosterIncrement,
+		"unglaublich": boosterIncrement, "völlig": boosterIncrement,
+		"absolut": boosterIncrement, "ziemlich": boosterIncrement,
+		"etwas": -boosterIncrement, "bisschen": -boosterIncrement,
+		"kaum": -boosterIncrement, "leicht": -boosterIncrement, "wenig": -boosterIncrement,
+	},
+	Negations: map[string]bool{
+		"nicht": true, "kein": true, "keine": true, "keinen": true, "keinem": true,
+		"keiner": true, "nie": true, "niemals": true, "nichts": true, "ohne": true,
+		"niemand": true,
+	},
+	Contrast: map[string]bool{
+		"aber": true, "jedoch": true, "obwohl": true, "trotzdem": true, "sondern": true,
+	},
+	Emotions: germanEmotions,
+}
+
+var germanEmotions = map[string]map[string]float64{
+	"glücklich":     {Joy: 1},
+	"glückliche":    {Joy: 1},
+	"glücklichen":   {Joy: 1},
+	"froh":          {Joy: 0.8},
+	"fröhlich":      {Joy: 0.9},
+	"freude":        {Joy: 1},
+	"freue":         {Joy: 0.7, Anticipation: 0.6},
+	"spaß":          {Joy: 0.7},
+	"stolz":         {Joy: 0.7},
+	"zufrieden":     {Joy: 0.6},
+	"dankbar":       {Joy: 0.7, Trust: 0.6},
+	"erleichtert":   {Joy: 0.6, Trust: 0.3},
+	"entspannt":     {Joy: 0.5, Trust: 0.3},
+	"ruhig":         {Joy: 0.4, Trust: 0.4},
+	"liebe":         {Joy: 0.8, Trust: 0.6},
+	"hoffnung":      {Anticipation: 0.8, Joy: 0.4, Trust: 0.3},
+	"hoffnungsvoll": {Anticipation: 0.8, Joy: 0.5, Trust: 0.4},
+	"aufgeregt":     {Joy: 0.6, Anticipation: 0.6, Fear: 0.2},
+	"vertrauen":     {Trust: 1},
+	"sicher":        {Trust: 0.7},
+	"unterstützt":   {Trust: 0.8, Joy: 0.4},
+	"freunde":       {Trust: 0.5, Joy: 0.3},
+	"angst":         {Fear: 1},
+	"ängstlich":     {Fear: 0.9, Anticipation: 0.4},
+	"panik":         {Fear: 1, Surprise: 0.4},
+	"nervös":        {Fear: 0.8, Anticipation: 0.4},
+	"besorgt":       {Fear: 0.8, Anticipation: 0.4},
+	"sorgen":        {Fear: 0.8, Anticipation: 0.4},
+	"stress":        {Fear: 0.6, Anger: 0.2, Sadness: 0.2},
+	"gestresst":     {Fear: 0.6, Anger: 0.2, Sadness: 0.2},
+	"überfordert":   {Fear: 0.7, Sadness: 0.5},
+	"überrascht":    {Surprise: 1},
+	"überraschung":  {Surprise: 1},
+	"plötzlich":     {Surprise: 0.6},
+	"schockiert":    {Surprise: 1, Fear: 0.3},
+	"traurig":       {Sadness: 1},
+	"traurige":      {Sadness: 1},
+	"trauer":        {Sadness: 1},
+	"deprimiert":    {Sadness: 1},
+	"einsam":        {Sadness: 0.9, Fear: 0.2},
+	"einsamkeit":    {Sadness: 0.9, Fear: 0.2},
+	"allein":        {Sadness: 0.6, Fear: 0.2},
+	"geweint":       {Sadness: 0.9},
+	"weinen":        {Sadness: 0.9},
+	"müde":          {Sadness: 0.4},
+	"erschöpft":     {Sadness: 0.6},
+	"enttäuscht":    {Sadness: 0.8, Anger: 0.3},
+	"hoffnungslos":  {Sadness: 1, Fear: 0.3},
+	"schuldig":      {Sadness: 0.7, Fear: 0.3},
+	"schuld":        {Sadness: 0.7, Fear: 0.3},
+	"scham":         {Disgust: 0.6, Sadness: 0.6},
+	"schäme":        {Disgust: 0.6, Sadness: 0.6},
+	"ekel":          {Disgust: 1},
+	"eklig":         {Disgust: 0.9},
+	"hass":          {Disgust: 0.7, Anger: 0.8},
+	"hasse":         {Disgust: 0.7, Anger: 0.8},
+	"wütend":        {Anger: 1},
+	"wut":           {Anger: 1},
+	"sauer":         {Anger: 0.8},
+	"ärger":         {Anger: 0.8},
+	"genervt":       {Anger: 0.7, Disgust: 0.3},
+	"frustriert":    {Anger: 0.8, Sadness: 0.3},
+	"streit":        {Anger: 0.8},
+	"unfair":        {Anger: 0.7, Disgust: 0.3},
+	"erwarte":       {Anticipation: 0.7},
+	"morgen":        {Anticipation: 0.4},
+	"pläne":         {Anticipation: 0.5},
+	"ziele":         {Anticipation: 0.6},
+	"gespannt":      {Anticipation: 0.9, Joy: 0.3},
+}
@@ -0,0 +1,149 @@
+package sentiment
+
+// Spanish is a journaling-oriented Spanish lexicon, scored on the VADER
+// scale by analogy with the English entries. Adjectives are listed in their
+// masculine and feminine forms.
+var Spanish = &Lexicon{
+	Valence: map[string]float64{
+		// Positivo
+		"feliz": 2.7, "felices": 2.7, "felicidad": 2.8, "contento": 2.2, "contenta": 2.2,
+		"alegre": 2.5, "alegría": 2.8, "bien": 1.5, "bueno": 1.9, "buena": 1.9,
+		"buen": 1.9, "genial": 2.8, "excelente": 2.7, "maravilloso": 2.8,
+		"maravillosa": 2.8, "increíble": 2.6, "fantástico": 2.6, "fantástica": 2.6,
+		"tranquilo": 1.3, "tranquila": 1.3, "calma": 1.3, "relajado": 1.8,
+		"relajada": 1.8, "aliviado": 1.6, "aliviada": 1.6, "alivio": 1.6,
+		"agradecido": 2.0, "agradecida": 2.0, "gracias": 1.9, "orgulloso": 2.1,
+		"orgullosa": 2.1, "amor": 3.2, "quiero": 1.5, "encanta": 2.5, "encantó": 2.5,
+		"esperanza": 1.9, "ilusión": 2.0, "ilusionado": 2.2, "ilusionada": 2.2,
+		"emocionado": 1.4, "emocionada": 1.4, "motivado": 1.8, "motivada": 1.8,
+		"seguro": 1.5, "segura": 1.5, "divertido": 2.3, "divertida": 2.3,
+		"disfruté": 2.3, "disfrutar": 2.2, "mejor": 1.9, "logré": 1.9, "éxito": 2.7,
+		"apoyo": 1.7, "paz": 2.5, "sonreí": 2.0, "reí": 2.1,
+
+		// Negativo
+		"triste": -2.1, "tristes": -2.1, "tristeza": -2.1, "mal": -2.0, "malo": -2.5,
+		"mala": -2.5, "peor": -2.1, "terrible": -2.1, "horrible": -2.5, "fatal": -2.1,
+		"cansado": -1.9, "cansada": -1.9, "agotado": -2.1, "agotada": -2.1,
+		"solo": -1.2, "sola": -1.2, "soledad": -1.5, "ansiedad": -2.2,
+		"ansioso": -1.7, "ansiosa": -1.7, "nervioso": -1.1, "nerviosa": -1.1,
+		"preocupado": -1.2, "preocupada": -1.2, "preocupación": -1.9,
+		"estrés": -1.8, "estresado": -1.4, "estresada": -1.4, "miedo": -2.2,
+		"asustado": -1.9, "asustada": -1.9, "pánico": -2.3, "enojado": -2.0,
+		"enojada": -2.0, "enfadado": -2.0, "enfadada": -2.0, "rabia": -2.7,
+		"furioso": -2.6, "furiosa": -2.6, "frustrado": -1.9, "frustrada": -1.9,
+		"decepcionado": -1.9, "decepcionada": -1.9, "deprimido": -2.3,
+		"deprimida": -2.3, "depresión": -2.5, "llorar": -2.1, "lloré": -2.1,
+		"dolor": -2.3, "sufrir": -2.5, "culpa": -1.8, "culpable": -1.8,
+		"vergüenza": -1.9, "odio": -2.7, "inútil": -1.8, "fracaso": -2.4,
+		"abrumado": -1.5, "abrumada": -1.5, "perdido": -1.3, "perdida": -1.3,
+		"desesperado": -2.0, "desesperada": -2.0, "difícil": -1.5, "problema": -1.7,
+		"discusión": -1.6, "pelea": -1.9, "herido": -2.4, "herida": -2.4,
+	},
+	Boosters: map[string]float64{
+		"muy": boosterIncrement, "tan": boosterIncrement, "realmente": boosterIncrement,
+		"súper": boosterIncrement, "super": boosterIncrement, "demasiado": boosterIncrement,
+		"totalmente": boosterIncrement, "completamente": boosterIncrement,
+		"increíblemente": boosterIncrement, "extremadamente": boosterIncrement,
+		"sumamente": boosterIncrement, "bastante": boosterIncrement,
+		"poco": -boosterIncrement, "algo": -boosterIncrement, "apenas": -boosterIncrement,
+		"ligeramente": -boosterIncrement,
+	},
+	Negations: map[string]bool{
+		"no": true, "nunca": true, "jamás": true, "ni": true, "tampoco": true,
+		"nada": true, "nadie": true, "ningún": true, "ninguna": true, "sin": true,
+	},
+	Contrast: map[string]bool{
+		"pero": true, "aunque": true, "sino": true, "embargo": true,
+	},
+	Emotions: spanishEmotions,
+}
+
+var spanishEmotions = map[string]map[string]float64{
+	"feliz":        {Joy: 1},
+	"felices":      {Joy: 1},
+	"felicidad":    {Joy: 1},
+	"contento":     {Joy: 0.8},
+	"contenta":     {Joy: 0.8},
+	"alegre":       {Joy: 0.9},
+	"alegría":      {Joy: 1},
+	"genial":       {Joy: 0.7, Surprise: 0.2},
+	"divertido":    {Joy: 0.7},
+	"divertida":    {Joy: 0.7},
+	"orgulloso":    {Joy: 0.7},
+	"orgullosa":    {Joy: 0.7},
+	"agradecido":   {Joy: 0.7, Trust: 0.6},
+	"agradecida":   {Joy: 0.7, Trust: 0.6},
+	"aliviado":     {Joy: 0.6, Trust: 0.3},
+	"aliviada":     {Joy: 0.6, Trust: 0.3},
+	"tranquilo":    {Joy: 0.4, Trust: 0.4},
+	"tranquila":    {Joy: 0.4, Trust: 0.4},
+	"amor":         {Joy: 0.8, Trust: 0.6},
+	"esperanza":    {Anticipation: 0.8, Joy: 0.4, Trust: 0.3},
+	"ilusión":      {Anticipation: 0.8, Joy: 0.6},
+	"ilusionado":   {Anticipation: 0.8, Joy: 0.6},
+	"ilusionada":   {Anticipation: 0.8, Joy: 0.6},
+	"emocionado":   {Joy: 0.8, Anticipation: 0.6},
+	"emocionada":   {Joy: 0.8, Anticipation: 0.6},
+	"confianza":    {Trust: 1},
+	"seguro":       {Trust: 0.7},
+	"segura":       {Trust: 0.7},
+	"apoyo":        {Trust: 0.7},
+	"amigos":       {Trust: 0.5, Joy: 0.3},
+	"miedo":        {Fear: 1},
+	"asustado":     {Fear: 1},
+	"asustada":     {Fear: 1},
+	"pánico":       {Fear: 1, Surprise: 0.4},
+	"ansiedad":     {Fear: 0.9, Anticipation: 0.4},
+	"ansioso":      {Fear: 0.9, Anticipation: 0.4},
+	"ansiosa":      {Fear: 0.9, Anticipation: 0.4},
+	"nervioso":     {Fear: 0.8, Anticipation: 0.4},
+	"nerviosa":     {Fear: 0.8, Anticipation: 0.4},
+	"preocupado":   {Fear: 0.8, Anticipation: 0.4},
+	"preocupada":   {Fear: 0.8, Anticipation: 0.4},
+	"estrés":       {Fear: 0.6, Anger: 0.2, Sadness: 0.2},
+	"estresado":    {Fear: 0.6, Anger: 0.2, Sadness: 0.2},
+	"estresada":    {Fear: 0.6, Anger: 0.2, Sadness: 0.2},
+	"abrumado":     {Fear: 0.7, Sadness: 0.5},
+	"abrumada":     {Fear: 0.7, Sadness: 0.5},
+	"sorprendido":  {Surprise: 1},
+	"sorprendida":  {Surprise: 1},
+	"sorpresa":     {Surprise: 1},
+	"inesperado":   {Surprise: 0.8},
+	"triste":       {Sadness: 1},
+	"tristes":      {Sadness: 1},
+	"tristeza":     {Sadness: 1},
+	"deprimido":    {Sadness: 1},
+	"deprimida":    {Sadness: 1},
+	"solo":         {Sadness: 0.5},
+	"sola":         {Sadness: 0.5},
+	"soledad":      {Sadness: 0.9, Fear: 0.2},
+	"llorar":       {Sadness: 0.9},
+	"lloré":        {Sadness: 0.9},
+	"cansado":      {Sadness: 0.4},
+	"cansada":      {Sadness: 0.4},
+	"agotado":      {Sadness: 0.6},
+	"agotada":      {Sadness: 0.6},
+	"decepcionado": {Sadness: 0.8, Anger: 0.3},
+	"decepcionada": {Sadness: 0.8, Anger: 0.3},
+	"culpa":        {Sadness: 0.7, Fear: 0.3},
+	"culpable":     {Sadness: 0.7, Fear: 0.3},
+	"vergüenza":    {Disgust: 0.6, Sadness: 0.6},
+	"asco":         {Disgust: 1},
+	"odio":         {Disgust: 0.7, Anger: 0.8},
+	"horrible":     {Disgust: 0.6, Fear: 0.4},
+	"enojado":      {Anger: 1},
+	"enojada":      {Anger: 1},
+	"enfadado":     {Anger: 1},
+	"enfadada":     {Anger: 1},
+	"rabia":        {Anger: 1},
+	"furioso":      {Anger: 1, Disgust: 0.3},
+	"furiosa":      {Anger: 1, Disgust: 0.3},
+	"frustrado":    {Anger: 0.8, Sadness: 0.3},
+	"frustrada":    {Anger: 0.8, Sadness: 0.3},
+	"discusión":    {Anger: 0.7},
+	"pelea":        {Anger: 0.8, Fear: 0.3},
+	"espero":       {Anticipation: 0.7},
+	"mañana":       {Anticipation: 0.4},
+	"planes":       {Anticipation: 0.5},
+	"metas":        {Anticipation: 0.6},
+}
@@ -0,0 +1,111 @@
+package sentiment
+
+// Hindi is a journaling-oriented Hindi lexicon in Devanagari, scored on the
+// VADER scale by analogy with the English entries. Words commonly written
+// both with and without a nukta are listed in both spellings.
+var Hindi = &Lexicon{
+	Valence: map[string]float64{
+		// सकारात्मक
+		"खुश": 2.7, "ख़ुश": 2.7, "खुशी": 2.8, "ख़ुशी": 2.8, "प्रसन्न": 2.5,
+		"आनंद": 2.8, "अच्छा": 1.9, "अच्छी": 1.9, "अच्छे": 1.9, "बढ़िया": 2.5,
+		"शानदार": 2.8, "बेहतर": 1.9, "शांत": 1.3, "सुकून": 2.0, "राहत": 1.6,
+		"आभारी": 2.0, "शुक्रगुज़ार": 2.0, "शुक्रगुजार": 2.0, "धन्यवाद": 1.9,
+		"गर्व": 2.1, "प्यार": 3.2, "उम्मीद": 1.9, "आशा": 1.9, "उत्साहित": 1.4,
+		"मज़ा": 2.3, "मजा": 2.3, "सुरक्षित": 1.5, "सफल": 2.5, "सफलता": 2.7,
+		"हँसी": 2.1, "हंसी": 2.1, "हँसा": 2.1, "हंसा": 2.1,
+
+		// नकारात्मक
+		"उदास": -2.1, "दुखी": -2.1, "दुख": -2.0, "दुःख": -2.0, "बुरा": -2.5,
+		"बुरी": -2.5, "बुरे": -2.5, "खराब": -2.1, "ख़राब": -2.1, "परेशान": -1.6,
+		"चिंता": -1.9, "चिंतित": -1.2, "तनाव": -1.8, "डर": -2.2, "डरा": -1.9,
+		"डरी": -1.9, "घबराहट": -1.5, "घबराया": -1.1, "घबराई": -1.1,
+		"अकेला": -1.5, "अकेली": -1.5, "अकेलापन": -1.5, "गुस्सा": -2.7,
+		"नाराज़": -2.0, "नाराज": -2.0, "थका": -1.9, "थकी": -1.9, "थकान": -1.9,
+		"निराश": -1.9, "निराशा": -1.9, "बेकार": -1.8, "शर्म": -1.9,
+		"शर्मिंदा": -1.9, "दर्द": -2.3, "रोया": -2.1, "रोई": -2.1, "रोना": -2.1,
+		"नफ़रत": -2.7, "नफरत": -2.7, "हताश": -2.0, "बेचैन": -1.6, "मुश्किल": -1.5,
+		"असफल": -2.0, "अपराधबोध": -1.8, "झगड़ा": -1.9, "डिप्रेशन": -2.5,
+	},
+	Boosters: map[string]float64{
+		"बहुत": boosterIncrement, "काफ़ी": boosterIncrement, "काफी": boosterIncrement,
+		"ज़्यादा": boosterIncrement, "ज्यादा": boosterIncrement, "बेहद": boosterIncrement,
+		"अत्यंत": boosterIncrement, "सच": boosterIncrement, "बिल्कुल": boosterIncrement,
+		"थोड़ा": -boosterIncrement, "थोड़ी": -boosterIncrement, "ज़रा": -boosterIncrement,
+		"जरा": -boosterIncrement,
+	},
+	Negations: map[string]bool{
+		"नहीं": true, "न": true, "ना": true, "मत": true, "बिना": true,
+	},
+	Contrast: map[string]bool{
+		"लेकिन": true, "मगर": true, "परंतु": true, "किंतु": true,
+	},
+	Emotions:        hindiEmotions,
+	NegationFollows: true,
+}
+
+var hindiEmotions = map[string]map[string]float64{
+	"खुश":      {Joy: 1},
+	"ख़ुश":     {Joy: 1},
+	"खुशी":     {Joy: 1},
+	"ख़ुशी":    {Joy: 1},
+	"प्रसन्न":  {Joy: 0.9},
+	"आनंद":     {Joy: 1},
+	"मज़ा":     {Joy: 0.7},
+	"मजा":      {Joy: 0.7},
+	"गर्व":     {Joy: 0.7},
+	"आभारी":    {Joy: 0.7, Trust: 0.6},
+	"राहत":     {Joy: 0.6, Trust: 0.3},
+	"सुकून":    {Joy: 0.6, Trust: 0.4},
+	"शांत":     {Joy: 0.4, Trust: 0.4},
+	"प्यार":    {Joy: 0.8, Trust: 0.6},
+	"उम्मीद":   {Anticipation: 0.8, Joy: 0.4, Trust: 0.3},
+	"आशा":      {Anticipation: 0.8, Joy: 0.4, Trust: 0.3},
+	"उत्साहित": {Joy: 0.8, Anticipation: 0.6},
+	"भरोसा":    {Trust: 1},
+	"विश्वास":  {Trust: 1},
+	"सुरक्षित": {Trust: 0.7},
+	"दोस्त":    {Trust: 0.5, Joy: 0.3},
+	"डर":       {Fear: 1},
+	"डरा":      {Fear: 1},
+	"डरी":      {Fear: 1},
+	"घबराहट":   {Fear: 0.9, Anticipation: 0.4},
+	"घबराया":   {Fear: 0.8, Anticipation: 0.4},
+	"घबराई":    {Fear: 0.8, Anticipation: 0.4},
+	"चिंता":    {Fear: 0.8, Anticipation: 0.4},
+	"चिंतित":   {Fear: 0.8, Anticipation: 0.4},
+	"तनाव":     {Fear: 0.6, Anger: 0.2, Sadness: 0.2},
+	"बेचैन":    {Fear: 0.6, Anticipation: 0.3},
+	"हैरान":    {Surprise: 1},
+	"आश्चर्य":  {Surprise: 1},
+	"अचानक":    {Surprise: 0.6},
+	"उदास":     {Sadness: 1},
+	"दुखी":     {Sadness: 1},
+	"दुख":      {Sadness: 1},
+	"दुःख":     {Sadness: 1},
+	"अकेला":    {Sadness: 0.8, Fear: 0.2},
+	"अकेली":    {Sadness: 0.8, Fear: 0.2},
+	"अकेलापन":  {Sadness: 0.9, Fear: 0.2},
+	"रोया":     {Sadness: 0.9},
+	"रोई":      {Sadness: 0.9},
+	"रोना":     {Sadness: 0.9},
+	"थका":      {Sadness: 0.4},
+	"थकी":      {Sadness: 0.4},
+	"थकान":     {Sadness: 0.5},
+	"निराश":    {Sadness: 0.8, Anger: 0.3},
+	"निराशा":   {Sadness: 0.8, Anger: 0.3},
+	"हताश":     {Sadness: 0.9, Fear: 0.3},
+	"अपराधबोध": {Sadness: 0.7, Fear: 0.3},
+	"शर्म":     {Disgust: 0.6, Sadness: 0.6},
+	"शर्मिंदा": {Disgust: 0.6, Sadness: 0.6},
+	"घृणा":     {Disgust: 1},
+	"नफ़रत":    {Disgust: 0.7, Anger: 0.8},
+	"नफरत":     {Disgust: 0.7, Anger: 0.8},
+	"गुस्सा":   {Anger: 1},
+	"नाराज़":   {Anger: 0.8},
+	"नाराज":    {Anger: 0.8},
+	"झगड़ा":    {Anger: 0.8},
+	"चिढ़":     {Anger: 0.7, Disgust: 0.3},
+	"इंतज़ार":  {Anticipation: 0.7},
+	"इंतजार":   {Anticipation: 0.7},
+	"लक्ष्य":   {Anticipation: 0.6},
+}
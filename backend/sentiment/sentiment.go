@@ -0,0 +1,306 @@
+// Package sentiment scores text offline with a VADER-style rule-based model:
+// a valence lexicon adjusted for negation, intensifiers, contrastive "but",
+// capitalisation and punctuation emphasis.
+package sentiment
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Empirically derived constants from the VADER paper (Hutto & Gilbert, 2014)
+const (
+	boosterIncrement  = 0.293
+	capsIncrement     = 0.733
+	negationScalar    = -0.74
+	normalizeAlpha    = 15.0
+	exclamationWeight = 0.292
+	questionWeight    = 0.18
+	maxExclamations   = 4
+	maxQuestionBoost  = 0.96
+)
+
+// Scores is the result of analysing a piece of text. Compound is normalised
+// to [-1, 1]; Positive, Negative and Neutral are proportions summing to 1.
+type Scores struct {
+	Compound float64 `json:"compound"`
+	Positive float64 `json:"pos"`
+	Negative float64 `json:"neg"`
+	Neutral  float64 `json:"neu"`
+}
+
+// Label maps the compound score to positive, negative or neutral using the
+// standard VADER thresholds.
+func (s Scores) Label() string {
+	switch {
+	case s.Compound >= 0.05:
+		return "positive"
+	case s.Compound <= -0.05:
+		return "negative"
+	default:
+		return "neutral"
+	}
+}
+
+// Analyzer scores text against a lexicon.
+type Analyzer struct {
+	lexicon *Lexicon
+}
+
+func NewAnalyzer(lexicon *Lexicon) *Analyzer {
+	return &Analyzer{lexicon: lexicon}
+}
+
+var defaultAnalyzer = NewAnalyzer(English)
+
+// analyzers holds an analyzer for each language with a lexicon, keyed by
+// ISO 639-1 code.
+var analyzers = map[string]*Analyzer{
+	"en": defaultAnalyzer,
+	"es": NewAnalyzer(Spanish),
+	"de": NewAnalyzer(German),
+	"hi": NewAnalyzer(Hindi),
+}
+
+// ForLanguage returns the analyzer for a language code, or the English one
+// when the language has no lexicon.
+func ForLanguage(lang string) *Analyzer {
+	if a, ok := analyzers[lang]; ok {
+		return a
+	}
+	return defaultAnalyzer
+}
+
+// ForText returns the analyzer for a language code. Short text often goes
+// undetected, so when lang is empty the analyzer is the one whose lexicon
+// knows the most words of text, and English on a tie: "no está mal" is too
+// short to detect but only the Spanish lexicon knows "mal".
+func ForText(lang, text string) *Analyzer {
+	if lang != "" {
+		return ForLanguage(lang)
+	}
+
+	tokens := tokenize(text)
+	best, bestKnown := defaultAnalyzer, defaultAnalyzer.lexicon.known(tokens)
+	langs := make([]string, 0, len(analyzers))
+	for l := range analyzers {
+		langs = append(langs, l)
+	}
+	sort.Strings(langs)
+	for _, l := range langs {
+		if known := analyzers[l].lexicon.known(tokens); known > bestKnown {
+			best, bestKnown = analyzers[l], known
+		}
+	}
+	return best
+}
+
+// Analyze scores text with the English lexicon.
+func Analyze(text string) Scores {
+	return defaultAnalyzer.Analyze(text)
+}
+
+type token struct {
+	raw   string
+	lower string
+}
+
+// Analyze scores text. Every lexicon word contributes its valence, adjusted
+// by the words around it, and the sum is normalised into a compound score.
+func (a *Analyzer) Analyze(text string) Scores {
+	tokens := tokenize(text)
+	if len(tokens) == 0 {
+		return Scores{Neutral: 1}
+	}
+
+	capsDiff := hasMixedCaps(tokens)
+	valences := make([]float64, len(tokens))
+
+	for i, tok := range tokens {
+		if _, isBooster := a.lexicon.Boosters[tok.lower]; isBooster {
+			continue
+		}
+
+		valence, ok := a.lexicon.Valence[tok.lower]
+		if !ok {
+			continue
+		}
+
+		// Emphasis through ALL CAPS when the rest of the text is not shouting
+		if capsDiff && isAllCaps(tok.raw) {
+			valence += math.Copysign(capsIncrement, valence)
+		}
+
+		// Intensifiers and dampeners up to three words back, decaying with distance
+		for dist := 1; dist <= 3 && i-dist >= 0; dist++ {
+			prev := tokens[i-dist]
+			if inc, ok := a.lexicon.Boosters[prev.lower]; ok {
+				scalar := inc
+				if capsDiff && isAllCaps(prev.raw) {
+					scalar += math.Copysign(capsIncrement, inc)
+				}
+				scalar *= []float64{1, 0.95, 0.9}[dist-1]
+				valence += math.Copysign(1, valence) * scalar
+			}
+		}
+
+		// Negation flips and dampens the valence
+		if a.negated(tokens, i) {
+			valence *= negationScalar
+		}
+
+		valences[i] = valence
+	}
+
+	a.applyContrast(tokens, valences)
+
+	sum := 0.0
+	for _, v := range valences {
+		sum += v
+	}
+
+	emphasis := punctuationEmphasis(text)
+	if sum > 0 {
+		sum += emphasis
+	} else if sum < 0 {
+		sum -= emphasis
+	}
+
+	scores := Scores{Compound: round4(normalize(sum))}
+	return scores.withProportions(valences, emphasis)
+}
+
+// negated reports whether the word at i is negated by one up to three words
+// before it or, in languages where negation can follow, two words after.
+func (a *Analyzer) negated(tokens []token, i int) bool {
+	for dist := 1; dist <= 3 && i-dist >= 0; dist++ {
+		if a.lexicon.isNegation(tokens[i-dist].lower) {
+			return true
+		}
+	}
+	if a.lexicon.NegationFollows {
+		for dist := 1; dist <= 2 && i+dist < len(tokens); dist++ {
+			if a.lexicon.isNegation(tokens[i+dist].lower) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyContrast halves sentiment before a contrastive conjunction such as
+// "but" and boosts sentiment after it by half.
+func (a *Analyzer) applyContrast(tokens []token, valences []float64) {
+	for i, tok := range tokens {
+		if !a.lexicon.Contrast[tok.lower] {
+			continue
+		}
+		for j := range valences {
+			if j < i {
+				valences[j] *= 0.5
+			} else if j > i {
+				valences[j] *= 1.5
+			}
+		}
+		return
+	}
+}
+
+func (s Scores) withProportions(valences []float64, emphasis float64) Scores {
+	var pos, neg, neu float64
+	for _, v := range valences {
+		switch {
+		case v > 0:
+			pos += v + 1
+		case v < 0:
+			neg += v - 1
+		default:
+			neu++
+		}
+	}
+
+	if pos > math.Abs(neg) {
+		pos += emphasis
+	} else if pos < math.Abs(neg) {
+		neg -= emphasis
+	}
+
+	total := pos + math.Abs(neg) + neu
+	if total == 0 {
+		s.Neutral = 1
+		return s
+	}
+	s.Positive = round4(pos / total)
+	s.Negative = round4(math.Abs(neg) / total)
+	s.Neutral = round4(neu / total)
+	return s
+}
+
+func tokenize(text string) []token {
+	fields := strings.Fields(text)
+	tokens := make([]token, 0, len(fields))
+	for _, f := range fields {
+		// Marks are kept so Devanagari vowel signs stay part of the word
+		word := strings.TrimFunc(f, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsMark(r) && !unicode.IsNumber(r) && r != '\''
+		})
+		word = strings.Trim(word, "'")
+		if word == "" {
+			continue
+		}
+		tokens = append(tokens, token{raw: word, lower: strings.ToLower(word)})
+	}
+	return tokens
+}
+
+// isAllCaps reports whether word is written in capitals. Words in scripts
+// without case, such as Devanagari, never are.
+func isAllCaps(word string) bool {
+	hasUpper := false
+	for _, r := range word {
+		if unicode.IsLower(r) {
+			return false
+		}
+		if unicode.IsUpper(r) {
+			hasUpper = true
+		}
+	}
+	return hasUpper && len([]rune(word)) > 1
+}
+
+// hasMixedCaps reports whether some but not all words are capitalised, which
+// is when capitalisation signals emphasis.
+func hasMixedCaps(tokens []token) bool {
+	caps := 0
+	for _, t := range tokens {
+		if isAllCaps(t.raw) {
+			caps++
+		}
+	}
+	return caps > 0 && caps < len(tokens)
+}
+
+func punctuationEmphasis(text string) float64 {
+	exclamations := strings.Count(text, "!")
+	if exclamations > maxExclamations {
+		exclamations = maxExclamations
+	}
+
+	questionBoost := 0.0
+	if questions := strings.Count(text, "?"); questions > 1 {
+		questionBoost = math.Min(float64(questions)*questionWeight, maxQuestionBoost)
+	}
+
+	return float64(exclamations)*exclamationWeight + questionBoost
+}
+
+func normalize(score float64) float64 {
+	n := score / math.Sqrt(score*score+normalizeAlpha)
+	return math.Max(-1, math.Min(1, n))
+}
+
+func round4(f float64) float64 {
+	return math.Round(f*10000) / 10000
+}
@@ -0,0 +1,50 @@
+package sentiment
+
+import "testing"
+
+func TestNegation(t *testing.T) {
+	tests := []struct {
+		lang string
+		text string
+		want string
+	}{
+		{"en", "I am happy", "positive"},
+		{"en", "I am not happy", "negative"},
+		{"en", "not bad at all", "positive"},
+		{"es", "estoy mal", "negative"},
+		{"es", "no está mal", "positive"},
+		{"es", "nunca estoy feliz", "negative"},
+		{"es", "ni feliz", "negative"},
+		{"es", "tampoco estoy triste", "positive"},
+		{"de", "nicht schlecht", "positive"},
+		{"hi", "खुश नहीं", "negative"},
+	}
+
+	for _, tt := range tests {
+		if got := ForLanguage(tt.lang).Analyze(tt.text).Label(); got != tt.want {
+			t.Errorf("%s %q = %s, want %s", tt.lang, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestForText(t *testing.T) {
+	tests := []struct {
+		lang string
+		text string
+		want *Lexicon
+	}{
+		{"es", "I feel good", Spanish},
+		{"", "no está mal", Spanish},
+		{"", "nicht schlecht", German},
+		{"", "खुश नहीं", Hindi},
+		{"", "not bad", English},
+		{"", "", English},
+		{"", "12345", English},
+	}
+
+	for _, tt := range tests {
+		if got := ForText(tt.lang, tt.text); got.lexicon != tt.want {
+			t.Errorf("ForText(%q, %q) picked the wrong lexicon", tt.lang, tt.text)
+		}
+	}
+}
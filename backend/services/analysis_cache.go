@@ -0,0 +1,303 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go_health_sentiment/models"
+)
+
+// CacheBackend stores analyses by cache key. Get returns nil on a miss.
+// Keys already include the user, but backends also take the user ID so a
+// lookup can never return another user's row.
+type CacheBackend interface {
+	Name() string
+	Get(ctx context.Context, userID int, key string) (*models.AnalysisResult, error)
+	Set(ctx context.Context, userID int, key string, result *models.AnalysisResult) error
+}
+
+// AnalysisCacheKey hashes everything that determines an analysis: the user,
+// the entry text with case and whitespace normalized, the notes on earlier
+// entries sent with it, the user's response style, the prompt version and
+// the model.
+func AnalysisCacheKey(userID int, content, history, style, promptVersion, model string) string {
+	normalized := normalizeContent(content)
+
+	h := sha256.New()
+	for _, part := range []string{strconv.Itoa(userID), normalized, history, style, promptVersion, model} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeContent folds case and whitespace, so entries that differ only
+// in them count as the same entry.
+func normalizeContent(content string) string {
+	return strings.ToLower(strings.Join(strings.Fields(content), " "))
+}
+
+// CacheStats are the cache's counters since startup.
+type CacheStats struct {
+	Backend string `json:"backend"`
+	Hits    int64  `json:"hits"`
+	Misses  int64  `json:"misses"`
+	Shared  int64  `json:"shared"`
+	Errors  int64  `json:"errors"`
+}
+
+// AnalysisCache fronts a backend with hit/miss counting and collapses
+// concurrent requests for the same key, such as a double-clicked submit,
+// into a single model call.
+type AnalysisCache struct {
+	backend CacheBackend
+
+	hits, misses, shared, errs atomic.Int64
+
+	mu       sync.Mutex
+	inflight map[string]*inflightAnalysis
+}
+
+type inflightAnalysis struct {
+	done   chan struct{}
+	result *models.AnalysisResult
+	err    error
+}
+
+func NewAnalysisCache(backend CacheBackend) *AnalysisCache {
+	return &AnalysisCache{
+		backend:  backend,
+		inflight: make(map[string]*inflightAnalysis),
+	}
+}
+
+// GetOrCompute returns the cached analysis for key, or calls compute and
+// caches its result. hit reports whether compute was skipped. Waiting on
+// another caller's computation of the same key stops when ctx is done.
+func (c *AnalysisCache) GetOrCompute(ctx context.Context, userID int, key string, compute func() (*models.AnalysisResult, error)) (result *models.AnalysisResult, hit bool, err error) {
+	cached, err := c.backend.Get(ctx, userID, key)
+	if err != nil {
+		c.errs.Add(1)
+		log.Printf("Analysis cache lookup failed: %v", err)
+	}
+	if cached != nil {
+		c.hits.Add(1)
+		return cached, true, nil
+	}
+	return c.compute(ctx, userID, key, compute)
+}
+
+// Recompute calls compute without looking up key and caches its result in
+// place of any stored one, for a re-analysis that must not reuse the old
+// answer. A computation of the same key already in flight is shared.
+func (c *AnalysisCache) Recompute(ctx context.Context, userID int, key string, compute func() (*models.AnalysisResult, error)) (result *models.AnalysisResult, hit bool, err error) {
+	return c.compute(ctx, userID, key, compute)
+}
+
+// compute runs compute once for concurrent callers of the same key and
+// stores its result.
+func (c *AnalysisCache) compute(ctx context.Context, userID int, key string, compute func() (*models.AnalysisResult, error)) (result *models.AnalysisResult, hit bool, err error) {
+	c.mu.Lock()
+	for {
+		call, ok := c.inflight[key]
+		if !ok {
+			break
+		}
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+		if call.err == nil {
+			c.shared.Add(1)
+			return cloneResult(call.result), true, nil
+		}
+		// The shared call failed; try again rather than reporting its error
+		c.mu.Lock()
+	}
+	call := &inflightAnalysis{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	c.misses.Add(1)
+	call.result, call.err = compute()
+	if call.err == nil {
+		if err := c.backend.Set(ctx, userID, key, call.result); err != nil {
+			c.errs.Add(1)
+			log.Printf("Analysis cache store failed: %v", err)
+		}
+	}
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.result, false, call.err
+}
+
+// cloneResult deep-copies a result shared between callers, each of which
+// may go on to modify its own.
+func cloneResult(r *models.AnalysisResult) *models.AnalysisResult {
+	c := *r
+	c.PrimaryEmotions = append([]models.EmotionIntensity(nil), r.PrimaryEmotions...)
+	c.Themes = append([]string(nil), r.Themes...)
+	c.ReflectionSuggestions = append([]string(nil), r.ReflectionSuggestions...)
+	return &c
+}
+
+func (c *AnalysisCache) Stats() CacheStats {
+	return CacheStats{
+		Backend: c.backend.Name(),
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Shared:  c.shared.Load(),
+		Errors:  c.errs.Load(),
+	}
+}
+
+// NewCacheBackend builds the backend named in the configuration: "memory",
+// "postgres", or "off" (nil).
+func NewCacheBackend(name string, db *sql.DB, size int, ttl time.Duration) (CacheBackend, error) {
+	switch strings.ToLower(name) {
+	case "memory", "":
+		return NewMemoryCache(size, ttl), nil
+	case "postgres":
+		return NewPostgresCache(db, ttl), nil
+	case "off", "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown analysis cache backend: %s", name)
+	}
+}
+
+// MemoryCache is an in-process LRU cache whose entries expire after a TTL.
+// Results are stored encoded so callers can't mutate cached values.
+type MemoryCache struct {
+	size int
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key     string
+	userID  int
+	value   []byte
+	expires time.Time
+}
+
+func NewMemoryCache(size int, ttl time.Duration) *MemoryCache {
+	if size <= 0 {
+		size = 1000
+	}
+	return &MemoryCache{
+		size:  size,
+		ttl:   ttl,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (m *MemoryCache) Name() string { return "memory" }
+
+func (m *MemoryCache) Get(ctx context.Context, userID int, key string) (*models.AnalysisResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.items[key]
+	if !ok {
+		return nil, nil
+	}
+	item := el.Value.(*memoryCacheItem)
+	if time.Now().After(item.expires) {
+		m.order.Remove(el)
+		delete(m.items, key)
+		return nil, nil
+	}
+	if item.userID != userID {
+		return nil, nil
+	}
+	m.order.MoveToFront(el)
+
+	var result models.AnalysisResult
+	if err := json.Unmarshal(item.value, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (m *MemoryCache) Set(ctx context.Context, userID int, key string, result *models.AnalysisResult) error {
+	value, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item := &memoryCacheItem{key: key, userID: userID, value: value, expires: time.Now().Add(m.ttl)}
+	if el, ok := m.items[key]; ok {
+		el.Value = item
+		m.order.MoveToFront(el)
+		return nil
+	}
+	m.items[key] = m.order.PushFront(item)
+
+	for m.order.Len() > m.size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.items, oldest.Value.(*memoryCacheItem).key)
+	}
+	return nil
+}
+
+// PostgresCache stores analyses in the analysis_cache table, so they are
+// shared between instances and survive restarts.
+type PostgresCache struct {
+	db  *sql.DB
+	ttl time.Duration
+
+	// Expired rows are swept every purgeEvery writes rather than on a timer
+	writes atomic.Int64
+}
+
+const purgeEvery = 100
+
+func NewPostgresCache(db *sql.DB, ttl time.Duration) *PostgresCache {
+	return &PostgresCache{db: db, ttl: ttl}
+}
+
+func (p *PostgresCache) Name() string { return "postgres" }
+
+func (p *PostgresCache) Get(ctx context.Context, userID int, key string) (*models.AnalysisResult, error) {
+	return models.GetCachedAnalysis(ctx, p.db, userID, key)
+}
+
+func (p *PostgresCache) Set(ctx context.Context, userID int, key string, result *models.AnalysisResult) error {
+	if err := models.SaveCachedAnalysis(ctx, p.db, userID, key, result, p.ttl); err != nil {
+		return err
+	}
+	if p.writes.Add(1)%purgeEvery == 0 {
+		if n, err := models.DeleteExpiredCachedAnalyses(ctx, p.db); err != nil {
+			log.Printf("Error purging expired cached analyses: %v", err)
+		} else if n > 0 {
+			log.Printf("Purged %d expired cached analyses", n)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package services
+
+import "testing"
+
+func TestAnalysisCacheKey(t *testing.T) {
+	base := AnalysisCacheKey(1, "Felt tired today.\nSlept badly.", "notes", "style", "v1", "model")
+
+	tests := []struct {
+		name string
+		key  string
+		same bool
+	}{
+		{"identical", AnalysisCacheKey(1, "Felt tired today.\nSlept badly.", "notes", "style", "v1", "model"), true},
+		{"case", AnalysisCacheKey(1, "FELT tired today.\nslept BADLY.", "notes", "style", "v1", "model"), true},
+		{"whitespace", AnalysisCacheKey(1, "  Felt   tired today. \r\n\tSlept badly.  ", "notes", "style", "v1", "model"), true},
+		{"different words", AnalysisCacheKey(1, "Felt rested today.\nSlept well.", "notes", "style", "v1", "model"), false},
+		{"punctuation", AnalysisCacheKey(1, "Felt tired today\nSlept badly", "notes", "style", "v1", "model"), false},
+		{"other user", AnalysisCacheKey(2, "Felt tired today.\nSlept badly.", "notes", "style", "v1", "model"), false},
+		{"other history", AnalysisCacheKey(1, "Felt tired today.\nSlept badly.", "", "style", "v1", "model"), false},
+		{"other style", AnalysisCacheKey(1, "Felt tired today.\nSlept badly.", "notes", "", "v1", "model"), false},
+		{"other prompt", AnalysisCacheKey(1, "Felt tired today.\nSlept badly.", "notes", "style", "v2", "model"), false},
+		{"other model", AnalysisCacheKey(1, "Felt tired today.\nSlept badly.", "notes", "style", "v1", "other"), false},
+		// Parts are separated, so text can't move from one into the next
+		{"shifted parts", AnalysisCacheKey(1, "Felt tired today.\nSlept badly.", "notesstyle", "", "v1", "model"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := tt.key == base; same != tt.same {
+				t.Errorf("key matches the base key: %v, want %v", same, tt.same)
+			}
+		})
+	}
+}
+
+func TestNormalizeContent(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"  \n\t ", ""},
+		{"Hello  World", "hello world"},
+		{"Line one\nLine two", "line one line two"},
+		{"ÁRBOL grande", "árbol grande"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeContent(tt.in); got != tt.want {
+			t.Errorf("normalizeContent(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
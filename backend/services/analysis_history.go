@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"go_health_sentiment/models"
+	"go_health_sentiment/promptguard"
+)
+
+// HistoryOptions bound the notes on earlier entries sent with an analysis,
+// which let the model point out patterns across entries.
+type HistoryOptions struct {
+	// Tokens is the budget for the notes. Zero sends the user's recent
+	// conversation turns instead.
+	Tokens int
+
+	// Recent and Similar are how many of the latest and of the most similar
+	// earlier entries are considered
+	Recent  int
+	Similar int
+
+	// Lookback is how far back recent entries and theme counts reach
+	Lookback time.Duration
+}
+
+const (
+	// themeSampleSize is how many recent entries themes are counted over
+	themeSampleSize = 50
+
+	historySnippetLen = 160
+)
+
+// entryHistory returns condensed notes on the user's entries written before
+// entry: how often themes recurred, then the most relevant entries, oldest
+// first, as many as fit in the token budget. Similar entries are found
+// through the semantic index when there is one.
+//
+// Earlier copies of the same text are left out, so a resubmitted entry gets
+// the same notes as the original and its analysis comes from the cache.
+func (c *ChatConversation) entryHistory(ctx context.Context, entry *models.JournalEntry) (string, error) {
+	opts := c.opts.History
+	since := entry.CreatedAt.Add(-opts.Lookback)
+	recent, err := models.GetRecentEntriesBefore(ctx, c.db, entry.UserID, entry.ID, since, entry.CreatedAt, themeSampleSize)
+	if err != nil {
+		return "", fmt.Errorf("error loading earlier entries: %v", err)
+	}
+
+	var similar []SemanticMatch
+	if c.opts.Index != nil && opts.Similar > 0 {
+		similar, err = c.opts.Index.Related(ctx, entry, opts.Similar)
+		if err != nil {
+			log.Printf("Error finding entries related to entry %d: %v", entry.ID, err)
+		}
+	}
+
+	content := normalizeContent(entry.Content)
+	recent = slices.DeleteFunc(recent, func(e models.JournalEntry) bool {
+		return normalizeContent(e.Content) == content
+	})
+	similar = slices.DeleteFunc(similar, func(m SemanticMatch) bool {
+		return normalizeContent(m.Entry.Content) == content
+	})
+
+	budget := opts.Tokens
+	var lines []string
+	if themes := recurringThemes(recent, opts.Lookback); themes != "" {
+		if cost := estimateTokens(themes); cost <= budget {
+			lines = append(lines, themes)
+			budget -= cost
+		}
+	}
+
+	// Alternate between the most similar and the latest entries so both
+	// kinds make it into a tight budget
+	if len(recent) > opts.Recent {
+		recent = recent[:opts.Recent]
+	}
+	var candidates []models.JournalEntry
+	for i := 0; i < len(similar) || i < len(recent); i++ {
+		if i < len(similar) {
+			candidates = append(candidates, similar[i].Entry)
+		}
+		if i < len(recent) {
+			candidates = append(candidates, recent[i])
+		}
+	}
+
+	seen := make(map[int]bool)
+	var chosen []models.JournalEntry
+	notes := make(map[int]string)
+	for _, e := range candidates {
+		// Entries answered with crisis resources are never sent to the model
+		if seen[e.ID] || e.RiskSource == "entry" {
+			continue
+		}
+		seen[e.ID] = true
+
+		note := historyNote(&e)
+		if cost := estimateTokens(note); cost <= budget {
+			chosen = append(chosen, e)
+			notes[e.ID] = note
+			budget -= cost
+		}
+	}
+
+	sort.Slice(chosen, func(i, j int) bool { return chosen[i].CreatedAt.Before(chosen[j].CreatedAt) })
+	for _, e := range chosen {
+		lines = append(lines, notes[e.ID])
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// historyNote condenses an entry and its analysis to a single line.
+func historyNote(e *models.JournalEntry) string {
+	var details []string
+	if e.SentimentScore != nil {
+		details = append(details, fmt.Sprintf("mood %+.2f", *e.SentimentScore))
+	} else if e.SentimentScores != nil {
+		details = append(details, fmt.Sprintf("mood %+.2f", e.SentimentScores.Compound))
+	}
+	if a := e.StructuredAnalysis; a != nil {
+		var emotions []string
+		for _, em := range a.PrimaryEmotions {
+			emotions = append(emotions, em.Emotion)
+		}
+		if len(emotions) > 0 {
+			details = append(details, "felt "+strings.Join(emotions, ", "))
+		}
+		if len(a.Themes) > 0 {
+			details = append(details, "themes: "+strings.Join(a.Themes, ", "))
+		}
+	}
+
+	snippet := truncateRunes(strings.Join(strings.Fields(e.Content), " "), historySnippetLen)
+	note := "- " + e.CreatedAt.Format("Mon Jan 2")
+	if len(details) > 0 {
+		note += " (" + strings.Join(details, "; ") + ")"
+	}
+	return note + ": \"" + promptguard.Escape(snippet) + "\""
+}
+
+// recurringThemes summarizes themes that came up in more than one of
+// entries, most frequent first.
+func recurringThemes(entries []models.JournalEntry, lookback time.Duration) string {
+	counts := make(map[string]int)
+	for _, e := range entries {
+		if e.StructuredAnalysis == nil || e.RiskSource == "entry" {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, t := range e.StructuredAnalysis.Themes {
+			t = strings.ToLower(strings.TrimSpace(t))
+			if t != "" && !seen[t] {
+				seen[t] = true
+				counts[t]++
+			}
+		}
+	}
+
+	var themes []string
+	for t, n := range counts {
+		if n > 1 {
+			themes = append(themes, t)
+		}
+	}
+	if len(themes) == 0 {
+		return ""
+	}
+	sort.Slice(themes, func(i, j int) bool {
+		if counts[themes[i]] != counts[themes[j]] {
+			return counts[themes[i]] > counts[themes[j]]
+		}
+		return themes[i] < themes[j]
+	})
+	if len(themes) > 5 {
+		themes = themes[:5]
+	}
+
+	parts := make([]string, len(themes))
+	for i, t := range themes {
+		parts[i] = fmt.Sprintf("%s (%d entries)", promptguard.Escape(t), counts[t])
+	}
+	return fmt.Sprintf("Recurring themes in the past %d days: %s", int(lookback.Hours()/24), strings.Join(parts, ", "))
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go_health_sentiment/models"
+)
+
+// analysisSchema is shown to the model to describe the expected JSON reply.
+const analysisSchema = `{
+  "sentiment_score": number between -1 (very negative) and 1 (very positive),
+  "primary_emotions": [{"emotion": string, "intensity": number between 0 and 1}] (1 to 5 items),
+  "themes": [string] (up to 5 short themes),
+  "supportive_message": string (2 to 5 empathetic sentences addressed to the writer),
+  "reflection_suggestions": [string] (1 to 5 gentle suggestions)
+}`
+
+var requiredAnalysisFields = []string{
+	"sentiment_score", "primary_emotions", "themes", "supportive_message", "reflection_suggestions",
+}
+
+// SchemaError lists every way a reply failed validation, so the problems can
+// be sent back to the model in a repair request.
+type SchemaError struct {
+	Problems []string
+}
+
+func (e *SchemaError) Error() string {
+	return "analysis does not match schema: " + strings.Join(e.Problems, "; ")
+}
+
+// ParseAnalysisResult extracts, decodes and validates the JSON object in a
+// model reply.
+func ParseAnalysisResult(raw string) (*models.AnalysisResult, error) {
+	text := extractJSONObject(raw)
+	if text == "" {
+		return nil, errors.New("response does not contain a JSON object")
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(text), &fields); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	var problems []string
+	for _, name := range requiredAnalysisFields {
+		if _, ok := fields[name]; !ok {
+			problems = append(problems, fmt.Sprintf("missing field %q", name))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &SchemaError{Problems: problems}
+	}
+
+	var result models.AnalysisResult
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
+		return nil, &SchemaError{Problems: []string{err.Error()}}
+	}
+
+	normalizeAnalysisResult(&result)
+	if problems := validateAnalysisResult(&result); len(problems) > 0 {
+		return nil, &SchemaError{Problems: problems}
+	}
+	return &result, nil
+}
+
+func normalizeAnalysisResult(r *models.AnalysisResult) {
+	r.SupportiveMessage = strings.TrimSpace(r.SupportiveMessage)
+	for i := range r.PrimaryEmotions {
+		r.PrimaryEmotions[i].Emotion = strings.ToLower(strings.TrimSpace(r.PrimaryEmotions[i].Emotion))
+	}
+	for i := range r.Themes {
+		r.Themes[i] = strings.TrimSpace(r.Themes[i])
+	}
+	for i := range r.ReflectionSuggestions {
+		r.ReflectionSuggestions[i] = strings.TrimSpace(r.ReflectionSuggestions[i])
+	}
+}
+
+func validateAnalysisResult(r *models.AnalysisResult) []string {
+	var problems []string
+
+	if r.SentimentScore < -1 || r.SentimentScore > 1 {
+		problems = append(problems, "sentiment_score must be between -1 and 1")
+	}
+
+	if len(r.PrimaryEmotions) == 0 || len(r.PrimaryEmotions) > 5 {
+		problems = append(problems, "primary_emotions must have 1 to 5 items")
+	}
+	for _, e := range r.PrimaryEmotions {
+		if e.Emotion == "" {
+			problems = append(problems, "primary_emotions entries need an emotion name")
+		}
+		if e.Intensity < 0 || e.Intensity > 1 {
+			problems = append(problems, fmt.Sprintf("intensity of %q must be between 0 and 1", e.Emotion))
+		}
+	}
+
+	if len(r.Themes) > 5 {
+		problems = append(problems, "themes must have at most 5 items")
+	}
+	for _, t := range r.Themes {
+		if t == "" {
+			problems = append(problems, "themes must not contain empty strings")
+			break
+		}
+	}
+
+	if r.SupportiveMessage == "" {
+		problems = append(problems, "supportive_message must not be empty")
+	} else if len(r.SupportiveMessage) > 2000 {
+		problems = append(problems, "supportive_message must be under 2000 characters")
+	}
+
+	if len(r.ReflectionSuggestions) == 0 || len(r.ReflectionSuggestions) > 5 {
+		problems = append(problems, "reflection_suggestions must have 1 to 5 items")
+	}
+	for _, s := range r.ReflectionSuggestions {
+		if s == "" {
+			problems = append(problems, "reflection_suggestions must not contain empty strings")
+			break
+		}
+	}
+
+	return problems
+}
+
+// extractJSONObject returns the outermost {...} in text, tolerating code
+// fences or prose the model wraps around it.
+func extractJSONObject(text string) string {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start == -1 || end <= start {
+		return ""
+	}
+	return text[start : end+1]
+}
+
+// repairMessage asks the model to fix a reply that failed to parse.
+func repairMessage(err error) string {
+	return fmt.Sprintf(`Your previous reply could not be used: %v.
+Reply again with only a single JSON object matching this schema and no other text:
+%s`, err, analysisSchema)
+}
+
+// fieldStreamer forwards the decoded value of one string field from a JSON
+// reply that is still being generated, so clients see readable text rather
+// than raw JSON.
+type fieldStreamer struct {
+	pattern *regexp.Regexp
+	buf     strings.Builder
+	sent    int
+	onToken TokenFunc
+}
+
+func newFieldStreamer(field string, onToken TokenFunc) *fieldStreamer {
+	return &fieldStreamer{
+		pattern: regexp.MustCompile(`"` + regexp.QuoteMeta(field) + `"\s*:\s*"`),
+		onToken: onToken,
+	}
+}
+
+func (f *fieldStreamer) Write(token string) error {
+	f.buf.WriteString(token)
+
+	text := f.buf.String()
+	loc := f.pattern.FindStringIndex(text)
+	if loc == nil {
+		return nil
+	}
+
+	value := decodePartialJSONString(text[loc[1]:])
+	if len(value) <= f.sent {
+		return nil
+	}
+	delta := value[f.sent:]
+	f.sent = len(value)
+	return f.onToken(delta)
+}
+
+// decodePartialJSONString decodes the body of a JSON string literal up to
+// the closing quote or the last complete character received so far.
+func decodePartialJSONString(s string) string {
+	var out strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"':
+			return out.String()
+		case c == '\\':
+			if i+1 >= len(s) {
+				return out.String()
+			}
+			switch s[i+1] {
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case 'r':
+				out.WriteByte('\r')
+			case 'b', 'f':
+			case 'u':
+				if i+6 > len(s) {
+					return out.String()
+				}
+				code, err := strconv.ParseUint(s[i+2:i+6], 16, 32)
+				if err != nil {
+					return out.String()
+				}
+				out.WriteRune(rune(code))
+				i += 6
+				continue
+			default:
+				out.WriteByte(s[i+1])
+			}
+			i += 2
+		default:
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size <= 1 && !utf8.FullRuneInString(s[i:]) {
+				return out.String()
+			}
+			out.WriteString(s[i : i+size])
+			i += size
+		}
+	}
+	return out.String()
+}
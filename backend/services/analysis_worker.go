@@ -0,0 +1,413 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go_health_sentiment/models"
+	"go_health_sentiment/safety"
+)
+
+// FailedAnalysisMessage is stored on entries whose analysis job was
+// dead-lettered.
+const FailedAnalysisMessage = "Analysis temporarily unavailable. Your entry has been saved successfully."
+
+var ErrAnalysisInProgress = errors.New("analysis already in progress")
+
+// SafeFallbackMessage replaces a model reply that failed output screening.
+const SafeFallbackMessage = "Thank you for sharing what you're going through. Your feelings matter, and you don't have to carry them alone. If things feel overwhelming, please consider reaching out to someone you trust or a professional who can support you."
+
+type WorkerPoolOptions struct {
+	Workers      int
+	MaxAttempts  int
+	PollInterval time.Duration
+	RetryBase    time.Duration
+	RetryMax     time.Duration
+	StaleAfter   time.Duration
+
+	// Index, when set, embeds each entry for semantic search before it is
+	// analyzed
+	Index *SemanticIndex
+
+	// RefineEmotions blends the emotions named in each analysis into the
+	// entry's lexicon emotion scores
+	RefineEmotions bool
+
+	// Timeout bounds each analysis, whether run by a worker or streamed;
+	// 0 means no limit
+	Timeout time.Duration
+}
+
+// AnalysisWorkerPool processes queued analysis jobs from Postgres.
+type AnalysisWorkerPool struct {
+	db           *sql.DB
+	chat         *ChatConversation
+	notifier     *AnalysisNotifier
+	outputSafety *safety.LanguageCheckers
+	opts         WorkerPoolOptions
+
+	// ctx is cancelled to abandon in-flight jobs when shutdown runs out of time
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	wake chan struct{}
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewAnalysisWorkerPool(db *sql.DB, chat *ChatConversation, notifier *AnalysisNotifier, outputSafety *safety.LanguageCheckers, opts WorkerPoolOptions) *AnalysisWorkerPool {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 2 * time.Second
+	}
+	if opts.RetryBase <= 0 {
+		opts.RetryBase = 5 * time.Second
+	}
+	if opts.RetryMax <= 0 {
+		opts.RetryMax = 5 * time.Minute
+	}
+	if opts.StaleAfter <= 0 {
+		opts.StaleAfter = 5 * time.Minute
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &AnalysisWorkerPool{
+		ctx:          ctx,
+		cancel:       cancel,
+		db:           db,
+		chat:         chat,
+		notifier:     notifier,
+		outputSafety: outputSafety,
+		opts:         opts,
+		wake:         make(chan struct{}, opts.Workers),
+		stop:         make(chan struct{}),
+	}
+}
+
+func (p *AnalysisWorkerPool) Start() {
+	for i := 0; i < p.opts.Workers; i++ {
+		p.wg.Add(1)
+		go p.run()
+	}
+	log.Printf("Started %d analysis workers", p.opts.Workers)
+}
+
+// Enqueue saves a new entry together with its analysis job and wakes a
+// worker to pick it up.
+func (p *AnalysisWorkerPool) Enqueue(ctx context.Context, entry *models.JournalEntry) error {
+	if err := models.CreateEntryWithJob(ctx, p.db, entry, p.opts.MaxAttempts); err != nil {
+		return err
+	}
+	p.Wake()
+	return nil
+}
+
+// Reanalyze queues a fresh analysis of an existing entry. With bypassCache
+// the job skips the analysis cache. It returns models.ErrAnalysisQueued if
+// one is already queued or running.
+func (p *AnalysisWorkerPool) Reanalyze(ctx context.Context, entry *models.JournalEntry, bypassCache bool) error {
+	if err := models.QueueReanalysis(ctx, p.db, entry, p.opts.MaxAttempts, bypassCache); err != nil {
+		return err
+	}
+	p.Wake()
+	return nil
+}
+
+// Wake nudges an idle worker to check the queue without waiting for the
+// next poll.
+func (p *AnalysisWorkerPool) Wake() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Shutdown stops workers from claiming new jobs and waits for in-flight
+// jobs to finish. If ctx expires first the remaining jobs are cancelled and
+// go back to the queue.
+func (p *AnalysisWorkerPool) Shutdown(ctx context.Context) error {
+	close(p.stop)
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
+
+func (p *AnalysisWorkerPool) run() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain the queue before going back to sleep
+		for {
+			select {
+			case <-p.stop:
+				return
+			default:
+			}
+
+			job, err := models.ClaimAnalysisJob(p.ctx, p.db, p.opts.StaleAfter)
+			if err != nil {
+				if err != models.ErrNoJobs {
+					log.Printf("Error claiming analysis job: %v", err)
+				}
+				break
+			}
+			p.process(job)
+		}
+
+		select {
+		case <-p.stop:
+			return
+		case <-p.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *AnalysisWorkerPool) process(job *models.AnalysisJob) {
+	ctx, cancel := p.jobContext(p.ctx)
+	defer cancel()
+
+	entry, err := models.GetEntryForAnalysis(ctx, p.db, job.JournalID)
+	if err != nil {
+		p.fail(ctx, job, err)
+		return
+	}
+
+	if err := models.UpdateAnalysisStatus(ctx, p.db, entry.ID, models.AnalysisRunning); err != nil {
+		log.Printf("Error updating analysis status for entry %d: %v", entry.ID, err)
+	}
+
+	p.indexEntry(ctx, entry)
+
+	analysis, err := p.chat.AnalyzeJournalEntry(ctx, entry, job.BypassCache)
+	if err != nil {
+		p.fail(ctx, job, err)
+		return
+	}
+
+	p.complete(ctx, job, entry, analysis)
+}
+
+// jobContext bounds one analysis by the configured timeout.
+func (p *AnalysisWorkerPool) jobContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if p.opts.Timeout > 0 {
+		return context.WithTimeout(parent, p.opts.Timeout)
+	}
+	return context.WithCancel(parent)
+}
+
+// StreamAnalysis runs the queued analysis of entry in the caller's request,
+// passing tokens to onToken as they are generated. It returns
+// ErrAnalysisInProgress when a worker already holds the job. If ctx is
+// cancelled the job goes back to the queue for a worker to pick up; if the
+// analysis times out the attempt fails as it would in a worker.
+func (p *AnalysisWorkerPool) StreamAnalysis(ctx context.Context, entry *models.JournalEntry, onToken TokenFunc) error {
+	job, err := models.ClaimAnalysisJobForEntry(ctx, p.db, entry.ID)
+	if err != nil {
+		if err == models.ErrNoJobs {
+			return ErrAnalysisInProgress
+		}
+		return err
+	}
+
+	if err := models.UpdateAnalysisStatus(ctx, p.db, entry.ID, models.AnalysisRunning); err != nil {
+		log.Printf("Error updating analysis status for entry %d: %v", entry.ID, err)
+	}
+
+	p.indexEntry(ctx, entry)
+
+	// Stop forwarding tokens as soon as the partial reply trips the output
+	// rules; complete then replaces the reply before it is stored
+	var streamed strings.Builder
+	blocked := false
+	outputSafety := p.outputSafety.ForLanguage(entryLanguage(entry))
+	guarded := func(token string) error {
+		if blocked {
+			return nil
+		}
+		streamed.WriteString(token)
+		if outputSafety.Check(streamed.String()).Flagged {
+			blocked = true
+			return nil
+		}
+		return onToken(token)
+	}
+
+	jobCtx, cancel := p.jobContext(ctx)
+	defer cancel()
+
+	analysis, err := p.chat.StreamJournalEntry(jobCtx, entry, job.BypassCache, guarded)
+	if err != nil {
+		if ctx.Err() != nil {
+			p.release(context.WithoutCancel(ctx), job)
+			p.Wake()
+			return ctx.Err()
+		}
+		p.fail(jobCtx, job, err)
+		return contextError(jobCtx, err)
+	}
+
+	p.complete(jobCtx, job, entry, analysis)
+	return nil
+}
+
+func (p *AnalysisWorkerPool) complete(ctx context.Context, job *models.AnalysisJob, entry *models.JournalEntry, analysis *Analysis) {
+	// The analysis has been generated and paid for; store it even if the
+	// caller has gone away since
+	ctx = context.WithoutCancel(ctx)
+
+	result := analysis.Result
+	p.screenOutput(ctx, entry, result)
+
+	entry.Analysis = result.SupportiveMessage
+	entry.StructuredAnalysis = result
+	entry.SentimentScore = &result.SentimentScore
+	entry.PromptVersion = analysis.PromptVersion
+	entry.Model = analysis.Model
+	if err := entry.SaveAnalysis(ctx, p.db, models.AnalysisDone); err != nil {
+		p.fail(ctx, job, err)
+		return
+	}
+
+	p.refineEmotions(ctx, entry, result)
+
+	if err := models.CompleteAnalysisJob(ctx, p.db, job.ID); err != nil {
+		log.Printf("Error completing analysis job %d: %v", job.ID, err)
+	}
+	p.notifier.Publish(entry.ID)
+}
+
+// screenOutput checks the model's reply against the output safety rules of
+// the entry's language, which the reply is written in, and replaces the
+// user-facing text when it is flagged.
+func (p *AnalysisWorkerPool) screenOutput(ctx context.Context, entry *models.JournalEntry, result *models.AnalysisResult) {
+	text := result.SupportiveMessage + "\n" + strings.Join(result.ReflectionSuggestions, "\n")
+	assessment := p.outputSafety.ForLanguage(entryLanguage(entry)).Check(text)
+	if !assessment.Flagged {
+		return
+	}
+
+	log.Printf("Safety: model output for entry %d assessed %s (%s)",
+		entry.ID, assessment.Level, strings.Join(assessment.Reasons, ", "))
+	if err := models.RecordRisk(ctx, p.db, entry.ID, true, assessment.Level, assessment.Reasons, "model_output"); err != nil {
+		log.Printf("Error recording risk for entry %d: %v", entry.ID, err)
+	}
+	result.SupportiveMessage = SafeFallbackMessage
+	result.ReflectionSuggestions = []string{"Reach out to someone you trust and let them know how you are feeling."}
+}
+
+// fail schedules a retry with jittered exponential backoff, or dead-letters
+// the job once it has used all of its attempts. A job cancelled by shutdown
+// goes back to the queue instead.
+func (p *AnalysisWorkerPool) fail(ctx context.Context, job *models.AnalysisJob, cause error) {
+	// The job's own context may have ended, but its outcome must still be
+	// recorded
+	ctx = context.WithoutCancel(ctx)
+	if p.ctx.Err() != nil {
+		p.release(ctx, job)
+		return
+	}
+
+	log.Printf("Analysis job %d for entry %d failed (attempt %d/%d): %v",
+		job.ID, job.JournalID, job.Attempts, job.MaxAttempts, cause)
+
+	if job.Attempts >= job.MaxAttempts {
+		if err := models.DeadLetterAnalysisJob(ctx, p.db, job.ID, cause.Error()); err != nil {
+			log.Printf("Error dead-lettering analysis job %d: %v", job.ID, err)
+		}
+		p.giveUp(ctx, job.JournalID)
+		p.notifier.Publish(job.JournalID)
+		return
+	}
+
+	runAt := time.Now().Add(p.backoff(job.Attempts))
+	if err := models.RetryAnalysisJob(ctx, p.db, job.ID, runAt, cause.Error()); err != nil {
+		log.Printf("Error rescheduling analysis job %d: %v", job.ID, err)
+	}
+	if err := models.UpdateAnalysisStatus(ctx, p.db, job.JournalID, models.AnalysisPending); err != nil {
+		log.Printf("Error updating analysis status for entry %d: %v", job.JournalID, err)
+	}
+}
+
+// giveUp records that an entry couldn't be analyzed. A failed re-analysis
+// leaves the entry's existing analysis in place.
+func (p *AnalysisWorkerPool) giveUp(ctx context.Context, entryID int) {
+	existing, err := models.GetEntryForAnalysis(ctx, p.db, entryID)
+	if err == nil && existing.CurrentAnalysisID != nil {
+		if err := models.UpdateAnalysisStatus(ctx, p.db, entryID, models.AnalysisDone); err != nil {
+			log.Printf("Error restoring analysis status for entry %d: %v", entryID, err)
+		}
+		return
+	}
+
+	entry := models.JournalEntry{
+		ID:       entryID,
+		Analysis: FailedAnalysisMessage,
+	}
+	if err := entry.SaveAnalysis(ctx, p.db, models.AnalysisFailed); err != nil {
+		log.Printf("Error saving failed analysis for entry %d: %v", entryID, err)
+	}
+}
+
+// release puts a job that was abandoned part-way back on the queue.
+func (p *AnalysisWorkerPool) release(ctx context.Context, job *models.AnalysisJob) {
+	if err := models.ReleaseAnalysisJob(ctx, p.db, job.ID); err != nil {
+		log.Printf("Error releasing analysis job %d: %v", job.ID, err)
+	}
+	if err := models.UpdateAnalysisStatus(ctx, p.db, job.JournalID, models.AnalysisPending); err != nil {
+		log.Printf("Error updating analysis status for entry %d: %v", job.JournalID, err)
+	}
+}
+
+// indexEntry embeds the entry for semantic search. A failure doesn't hold
+// up the analysis; the entry is indexed on the user's next search instead.
+func (p *AnalysisWorkerPool) indexEntry(ctx context.Context, entry *models.JournalEntry) {
+	if p.opts.Index == nil {
+		return
+	}
+	if err := p.opts.Index.IndexEntry(ctx, entry); err != nil {
+		log.Printf("Error indexing entry %d: %v", entry.ID, err)
+	}
+}
+
+// refineEmotions updates the entry's emotion scores with the model's
+// reading. On failure the lexicon scores stay in place.
+func (p *AnalysisWorkerPool) refineEmotions(ctx context.Context, entry *models.JournalEntry, result *models.AnalysisResult) {
+	if !p.opts.RefineEmotions {
+		return
+	}
+	emotions := RefineEmotions(entry.Content, entryLanguage(entry), result.PrimaryEmotions)
+	if err := models.SaveEntryEmotions(ctx, p.db, entry.ID, emotions); err != nil {
+		log.Printf("Error refining emotions for entry %d: %v", entry.ID, err)
+		return
+	}
+	entry.Emotions = emotions
+}
+
+// backoff returns the delay before a job's next attempt.
+func (p *AnalysisWorkerPool) backoff(attempt int) time.Duration {
+	return jitteredBackoff(p.opts.RetryBase, p.opts.RetryMax, attempt)
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"go_health_sentiment/models"
+	"go_health_sentiment/prompts"
+	"go_health_sentiment/safety"
+)
+
+// recordingDriver is a database/sql driver that accepts every statement and
+// remembers it, so code that writes to Postgres can run without one.
+type recordingDriver struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+func (d *recordingDriver) Open(name string) (driver.Conn, error) { return &recordingConn{d}, nil }
+
+func (d *recordingDriver) Queries() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.queries...)
+}
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordingStmt{d: c.d, query: query}, nil
+}
+
+func (c *recordingConn) Close() error { return nil }
+
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions not supported")
+}
+
+type recordingStmt struct {
+	d     *recordingDriver
+	query string
+}
+
+func (s *recordingStmt) Close() error  { return nil }
+func (s *recordingStmt) NumInput() int { return -1 }
+
+func (s *recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.queries = append(s.d.queries, s.query)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("queries not supported")
+}
+
+var registerDriver sync.Once
+
+// newRecordingDB returns a database whose statements are recorded by the
+// returned driver.
+func newRecordingDB(t *testing.T) (*sql.DB, *recordingDriver) {
+	t.Helper()
+	d := &recordingDriver{}
+	registerDriver.Do(func() { sql.Register("recording", &driverMux{}) })
+	db, err := sql.Open("recording", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	drivers.Store(t.Name(), d)
+	t.Cleanup(func() {
+		db.Close()
+		drivers.Delete(t.Name())
+	})
+	return db, d
+}
+
+// driverMux routes each connection to the driver of the test that opened
+// the database, keyed by the data source name.
+type driverMux struct{}
+
+var drivers sync.Map
+
+func (driverMux) Open(name string) (driver.Conn, error) {
+	d, ok := drivers.Load(name)
+	if !ok {
+		return nil, errors.New("no recording driver for " + name)
+	}
+	return d.(*recordingDriver).Open(name)
+}
+
+// newFakeChat returns a ChatConversation backed by the fake provider and
+// the embedded prompts.
+func newFakeChat(t *testing.T) (*ChatConversation, *FakeProvider) {
+	t.Helper()
+	registry, err := prompts.LoadEmbedded()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.SetDefault(prompts.Analysis, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	provider := NewFakeProvider("")
+	return NewChatConversation(nil, provider, registry, ChatOptions{}), provider
+}
+
+func TestWorkerScreenOutput(t *testing.T) {
+	chat, _ := newFakeChat(t)
+
+	tests := []struct {
+		name     string
+		language string
+		reply    string // replaces the fake provider's message when set
+		flagged  bool
+	}{
+		{name: "fake reply passes", language: "en"},
+		{name: "moderate reply is left alone", language: "en", reply: "Honestly, you're overreacting."},
+		{name: "harmful reply is replaced", language: "en", reply: "You should hurt yourself.", flagged: true},
+		{name: "spanish reply uses spanish rules", language: "es", reply: "Deberías lastimarte.", flagged: true},
+		{name: "undetected reply uses every language", language: "", reply: "Du solltest dich verletzen.", flagged: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, recorder := newRecordingDB(t)
+			pool := NewAnalysisWorkerPool(db, chat, NewAnalysisNotifier(), safety.NewOutputCheckers(safety.LevelHigh), WorkerPoolOptions{})
+
+			entry := &models.JournalEntry{ID: 7, UserID: 1, Content: "Long week.", Language: tt.language}
+			analysis, err := chat.AnalyzeText(context.Background(), entry.Content, tt.language)
+			if err != nil {
+				t.Fatalf("AnalyzeText: %v", err)
+			}
+			result := analysis.Result
+			if tt.reply != "" {
+				result.SupportiveMessage = tt.reply
+			}
+			original := result.SupportiveMessage
+
+			pool.screenOutput(context.Background(), entry, result)
+
+			queries := recorder.Queries()
+			if !tt.flagged {
+				if result.SupportiveMessage != original {
+					t.Errorf("unflagged reply replaced with %q", result.SupportiveMessage)
+				}
+				if len(queries) != 0 {
+					t.Errorf("unflagged reply recorded risk: %v", queries)
+				}
+				return
+			}
+
+			if result.SupportiveMessage != SafeFallbackMessage {
+				t.Errorf("flagged reply kept: %q", result.SupportiveMessage)
+			}
+			if len(queries) != 1 || !strings.Contains(queries[0], "UPDATE journals") {
+				t.Errorf("flagged reply recorded %v, want one risk update", queries)
+			}
+		})
+	}
+}
+
+func TestFakeProviderAnalysisIsDeterministic(t *testing.T) {
+	chat, provider := newFakeChat(t)
+
+	first, err := chat.AnalyzeText(context.Background(), "Long week, but the weekend helped.", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := chat.AnalyzeText(context.Background(), "Long week, but the weekend helped.", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Result.SupportiveMessage != second.Result.SupportiveMessage {
+		t.Errorf("same entry analyzed differently: %q and %q", first.Result.SupportiveMessage, second.Result.SupportiveMessage)
+	}
+	if first.Model != "fake" || first.PromptVersion == "" {
+		t.Errorf("analysis model %q prompt %q", first.Model, first.PromptVersion)
+	}
+	if calls := provider.Calls(); len(calls) != 2 || !calls[0].JSONMode {
+		t.Errorf("provider calls = %+v, want two JSON-mode requests", calls)
+	}
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// bloomFilter is a fixed-size Bloom filter of strings. It answers "maybe"
+// or "definitely not", so a negative needs no further lookup.
+type bloomFilter struct {
+	bits []uint64
+	k    uint64
+}
+
+// newBloomFilter sizes a filter for n items at false positive rate p.
+func newBloomFilter(n int, p float64) *bloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/float64(n)*math.Ln2))
+	return &bloomFilter{
+		bits: make([]uint64, (uint64(m)+63)/64),
+		k:    uint64(k),
+	}
+}
+
+func (b *bloomFilter) Add(s string) {
+	h1, h2 := bloomHashes(s)
+	m := uint64(len(b.bits)) * 64
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (b *bloomFilter) MayContain(s string) bool {
+	h1, h2 := bloomHashes(s)
+	m := uint64(len(b.bits)) * 64
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHashes derives the two hashes that are combined into the filter's
+// k probes.
+func bloomHashes(s string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	h1 := h.Sum64()
+	h.Write([]byte{0})
+	h2 := h.Sum64() | 1
+	return h1, h2
+}
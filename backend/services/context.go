@@ -0,0 +1,13 @@
+package services
+
+import "context"
+
+// contextError returns ctx's error in place of err when ctx ended while the
+// operation was running. Errors from deep in a call are often wrapped as
+// text, so this lets callers tell a cancellation or timeout from a failure.
+func contextError(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
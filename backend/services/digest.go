@@ -0,0 +1,470 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go_health_sentiment/models"
+	"go_health_sentiment/promptguard"
+	"go_health_sentiment/prompts"
+	"go_health_sentiment/safety"
+)
+
+// digestSchema is shown to the model to describe the expected JSON reply.
+const digestSchema = `{
+  "summary": string (3 to 6 warm sentences addressed to the writer),
+  "themes": [string] (up to 5 recurring themes),
+  "mood_trajectory": string (1 to 3 sentences on how their mood moved),
+  "wins": [string] (up to 5 wins worth acknowledging),
+  "suggestions": [string] (1 to 5 gentle suggestions)
+}`
+
+// digestPromptChars bounds the entry text in a digest prompt. Each entry
+// gets an equal share, so a busy month is condensed rather than cut off.
+const (
+	digestPromptChars = 12000
+	minDigestEntryLen = 200
+)
+
+// GenerateDigest asks the model to reflect on entries written in [start,
+// end). Entries answered with crisis resources are left out, as they are
+// from analysis. The returned digest has no ID yet.
+func (c *ChatConversation) GenerateDigest(ctx context.Context, userID int, period string, start, end time.Time, entries []models.JournalEntry) (*models.Digest, error) {
+	began := time.Now()
+	ctx, meter := withUsageMeter(ctx)
+	ctx, err := c.withRedaction(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := c.generateDigest(ctx, period, start, end, entries)
+	promptID := ""
+	if digest != nil {
+		promptID = digest.PromptVersion
+	}
+	c.recordUsage(ctx, userID, promptID, false, meter.Usage(), time.Since(began), err)
+	if err != nil {
+		return nil, err
+	}
+	digest.UserID = userID
+	return digest, nil
+}
+
+func (c *ChatConversation) generateDigest(ctx context.Context, period string, start, end time.Time, entries []models.JournalEntry) (*models.Digest, error) {
+	var included []models.JournalEntry
+	for _, e := range entries {
+		if e.RiskSource != "entry" {
+			included = append(included, e)
+		}
+	}
+	if len(included) == 0 {
+		return nil, errors.New("no entries to summarize")
+	}
+
+	perEntry := digestPromptChars / len(included)
+	if perEntry < minDigestEntryLen {
+		perEntry = minDigestEntryLen
+	}
+
+	var text strings.Builder
+	var moodSum float64
+	for _, e := range included {
+		var mood float64
+		if e.SentimentScores != nil {
+			mood = e.SentimentScores.Compound
+		}
+		moodSum += mood
+		fmt.Fprintf(&text, "%s (mood %+.2f):\n%s\n\n",
+			e.CreatedAt.Format("Monday, January 2"), mood, promptguard.Delimit(truncateRunes(e.Content, perEntry)))
+	}
+	average := moodSum / float64(len(included))
+
+	tmpl, err := c.prompts.Resolve(prompts.Digest, "")
+	if err != nil {
+		return nil, err
+	}
+	noun := "week"
+	if period == models.DigestMonthly {
+		noun = "month"
+	}
+	rendered, err := tmpl.RenderMessages(map[string]string{
+		"Period":  noun,
+		"Start":   start.Format("January 2, 2006"),
+		"End":     end.AddDate(0, 0, -1).Format("January 2, 2006"),
+		"Entries": strings.TrimSpace(text.String()),
+		"Schema":  digestSchema,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := c.provider.Generate(ctx, GenerateRequest{
+		Messages: []Message{
+			{Role: "system", Content: rendered.System},
+			{Role: "user", Content: rendered.User},
+		},
+		Params:   c.opts.Params,
+		JSONMode: true,
+	})
+	if err != nil {
+		return &models.Digest{PromptVersion: tmpl.ID()}, err
+	}
+
+	content, err := ParseDigestContent(raw)
+	if err != nil {
+		return &models.Digest{PromptVersion: tmpl.ID()}, err
+	}
+	filterDigest(promptguard.NewOutputFilter(rendered.System), content)
+
+	return &models.Digest{
+		Period:           period,
+		PeriodStart:      start,
+		PeriodEnd:        end.AddDate(0, 0, -1),
+		Content:          content,
+		EntryCount:       len(included),
+		AverageSentiment: &average,
+		PromptVersion:    tmpl.ID(),
+		Model:            c.provider.Model(),
+	}, nil
+}
+
+// ParseDigestContent extracts and validates the JSON object in a digest
+// reply.
+func ParseDigestContent(raw string) (*models.DigestContent, error) {
+	text := extractJSONObject(raw)
+	if text == "" {
+		return nil, errors.New("response does not contain a JSON object")
+	}
+
+	var content models.DigestContent
+	if err := content.Scan(text); err != nil {
+		return nil, &SchemaError{Problems: []string{err.Error()}}
+	}
+
+	content.Summary = strings.TrimSpace(content.Summary)
+	content.MoodTrajectory = strings.TrimSpace(content.MoodTrajectory)
+	content.Themes = trimNonEmpty(content.Themes, 5)
+	content.Wins = trimNonEmpty(content.Wins, 5)
+	content.Suggestions = trimNonEmpty(content.Suggestions, 5)
+
+	var problems []string
+	if content.Summary == "" {
+		problems = append(problems, "summary must not be empty")
+	}
+	if len(content.Suggestions) == 0 {
+		problems = append(problems, "suggestions must have 1 to 5 items")
+	}
+	if len(problems) > 0 {
+		return nil, &SchemaError{Problems: problems}
+	}
+	return &content, nil
+}
+
+// trimNonEmpty trims each item, drops empty ones and keeps at most max.
+func trimNonEmpty(items []string, max int) []string {
+	kept := []string{}
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" && len(kept) < max {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// filterDigest applies the output filter to every user-facing field.
+func filterDigest(filter *promptguard.OutputFilter, content *models.DigestContent) {
+	var removed []string
+	filterText := func(text string) string {
+		filtered, reasons := filter.Filter(text)
+		removed = append(removed, reasons...)
+		return filtered
+	}
+	filterList := func(items []string) []string {
+		kept := items[:0]
+		for _, item := range items {
+			if filtered := filterText(item); filtered != "" {
+				kept = append(kept, filtered)
+			}
+		}
+		return kept
+	}
+
+	content.Summary = filterText(content.Summary)
+	if content.Summary == "" {
+		content.Summary = SafeFallbackMessage
+	}
+	content.MoodTrajectory = filterText(content.MoodTrajectory)
+	content.Wins = filterList(content.Wins)
+	content.Suggestions = filterList(content.Suggestions)
+
+	if len(removed) > 0 {
+		log.Printf("Output filter removed content from digest: %s", strings.Join(removed, ", "))
+	}
+}
+
+func truncateRunes(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max]) + "…"
+}
+
+// DigestOptions configure the digest scheduler.
+type DigestOptions struct {
+	// Periods lists the digests to write: models.DigestWeekly and/or
+	// models.DigestMonthly
+	Periods []string
+
+	// Interval is how often the scheduler looks for finished periods
+	Interval time.Duration
+
+	// MinEntries is the fewest entries a period needs to get a digest
+	MinEntries int
+
+	// MaxAttempts is how many times a digest is tried before the period
+	// is skipped for that user
+	MaxAttempts int
+
+	// StaleAfter is when a digest claimed by a scheduler that stopped
+	// mid-way may be taken over
+	StaleAfter time.Duration
+
+	// Timeout bounds writing one digest; 0 leaves it unbounded
+	Timeout time.Duration
+}
+
+// DigestScheduler writes a digest for each user after every completed
+// week (Monday to Sunday) and calendar month, in UTC. Claims in the digests
+// table make it safe to restart or to run on several instances.
+type DigestScheduler struct {
+	db           *sql.DB
+	chat         *ChatConversation
+	usage        *UsageTracker
+	outputSafety *safety.LanguageCheckers
+	opts         DigestOptions
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// ParseDigestPeriods parses a comma-separated list of digest periods.
+// "off" or an empty list means no digests.
+func ParseDigestPeriods(spec string) ([]string, error) {
+	var periods []string
+	for _, p := range strings.Split(spec, ",") {
+		switch p = strings.ToLower(strings.TrimSpace(p)); p {
+		case "", "off":
+		case models.DigestWeekly, models.DigestMonthly:
+			periods = append(periods, p)
+		default:
+			return nil, fmt.Errorf("unknown digest period: %s", p)
+		}
+	}
+	return periods, nil
+}
+
+func NewDigestScheduler(db *sql.DB, chat *ChatConversation, usage *UsageTracker, outputSafety *safety.LanguageCheckers, opts DigestOptions) *DigestScheduler {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Hour
+	}
+	if opts.MinEntries <= 0 {
+		opts.MinEntries = 1
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.StaleAfter <= 0 {
+		opts.StaleAfter = 30 * time.Minute
+	}
+	return &DigestScheduler{
+		db:           db,
+		chat:         chat,
+		usage:        usage,
+		outputSafety: outputSafety,
+		opts:         opts,
+	}
+}
+
+func (s *DigestScheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.opts.Interval)
+		defer ticker.Stop()
+		for {
+			s.RunOnce(ctx, time.Now())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Printf("Started digest scheduler (%s every %v)", strings.Join(s.opts.Periods, ", "), s.opts.Interval)
+}
+
+// Shutdown stops the scheduler, abandoning any digest in progress, and
+// waits for it to exit or for ctx to expire.
+func (s *DigestScheduler) Shutdown(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RunOnce writes the missing digests of the last period of each kind that
+// ended before now.
+func (s *DigestScheduler) RunOnce(ctx context.Context, now time.Time) {
+	for _, period := range s.opts.Periods {
+		start, end := lastDigestPeriod(period, now)
+
+		userIDs, err := models.FindDigestCandidates(ctx, s.db, period, start, end, s.opts.MinEntries, s.opts.MaxAttempts)
+		if err != nil {
+			log.Printf("Error finding users for %s digests: %v", period, err)
+			continue
+		}
+
+		for _, userID := range userIDs {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := s.generate(ctx, userID, period, start, end); err != nil {
+				log.Printf("Error writing %s digest for user %d: %v", period, userID, err)
+			}
+		}
+	}
+}
+
+func (s *DigestScheduler) generate(ctx context.Context, userID int, period string, start, end time.Time) error {
+	// Digests wait for quota like analyses; the next run after it resets
+	// picks the period up again
+	if s.usage != nil {
+		report, err := s.usage.Report(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if report.Exceeded != "" {
+			return nil
+		}
+	}
+
+	id, err := models.ClaimDigest(ctx, s.db, userID, period, start, end.AddDate(0, 0, -1), s.opts.StaleAfter, s.opts.MaxAttempts)
+	if err != nil {
+		if err == models.ErrDigestClaimed {
+			return nil
+		}
+		return err
+	}
+
+	writeCtx, cancel := s.writeContext(ctx)
+	defer cancel()
+
+	digest, err := s.write(writeCtx, userID, period, start, end)
+	if err != nil {
+		// A digest cut short by shutdown is released without counting the
+		// attempt, so the next run neither waits out StaleAfter nor gives
+		// up on it sooner
+		outcomeCtx := context.WithoutCancel(ctx)
+		if ctx.Err() != nil {
+			if releaseErr := models.ReleaseDigest(outcomeCtx, s.db, id); releaseErr != nil {
+				log.Printf("Error releasing digest %d: %v", id, releaseErr)
+			}
+			return err
+		}
+		if failErr := models.FailDigest(outcomeCtx, s.db, id, err.Error()); failErr != nil {
+			log.Printf("Error recording failure of digest %d: %v", id, failErr)
+		}
+		return err
+	}
+
+	digest.ID = id
+	if err := models.CompleteDigest(ctx, s.db, digest); err != nil {
+		return err
+	}
+	log.Printf("Wrote %s digest %d for user %d", period, id, userID)
+	return nil
+}
+
+// writeContext bounds writing one digest by the configured timeout.
+func (s *DigestScheduler) writeContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if s.opts.Timeout > 0 {
+		return context.WithTimeout(parent, s.opts.Timeout)
+	}
+	return context.WithCancel(parent)
+}
+
+func (s *DigestScheduler) write(ctx context.Context, userID int, period string, start, end time.Time) (*models.Digest, error) {
+	entries, err := models.GetEntriesInRange(ctx, s.db, userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := s.chat.GenerateDigest(ctx, userID, period, start, end, entries)
+	if err != nil {
+		return nil, err
+	}
+	s.screenOutput(digest)
+	return digest, nil
+}
+
+// screenOutput replaces a digest that fails the output safety rules.
+func (s *DigestScheduler) screenOutput(digest *models.Digest) {
+	if s.outputSafety == nil {
+		return
+	}
+
+	c := digest.Content
+	text := strings.Join(append([]string{c.Summary, c.MoodTrajectory}, append(c.Wins, c.Suggestions...)...), "\n")
+	// A digest can draw on entries in several languages
+	assessment := s.outputSafety.AnyLanguage().Check(text)
+	if !assessment.Flagged {
+		return
+	}
+
+	log.Printf("Safety: digest for user %d assessed %s (%s)",
+		digest.UserID, assessment.Level, strings.Join(assessment.Reasons, ", "))
+	c.Summary = SafeFallbackMessage
+	c.MoodTrajectory = ""
+	c.Wins = []string{}
+	c.Suggestions = []string{"Reach out to someone you trust and let them know how you are feeling."}
+}
+
+// lastDigestPeriod returns the [start, end) of the most recent week
+// (Monday to Monday) or calendar month that ended at or before now, in UTC.
+func lastDigestPeriod(period string, now time.Time) (time.Time, time.Time) {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	if period == models.DigestMonthly {
+		end := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return end.AddDate(0, -1, 0), end
+	}
+
+	sinceMonday := (int(today.Weekday()) + 6) % 7
+	end := today.AddDate(0, 0, -sinceMonday)
+	return end.AddDate(0, 0, -7), end
+}
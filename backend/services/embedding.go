@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"go_health_sentiment/config"
+)
+
+// Embedder turns texts into vectors whose cosine similarity reflects how
+// alike the texts are. Name identifies the embedder and model, so vectors
+// from different ones are never compared.
+type Embedder interface {
+	Name() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// NewEmbedder builds the embedder selected in the configuration.
+func NewEmbedder(cfg config.EmbeddingConfig) (Embedder, error) {
+	client := &http.Client{Timeout: cfg.Timeout}
+
+	switch strings.ToLower(cfg.Provider) {
+	case "hashing", "":
+		return NewHashingEmbedder(cfg.Dimensions), nil
+	case "openai":
+		return NewOpenAIEmbedder(client, cfg.BaseURL, modelOr(cfg.Model, "text-embedding-3-small"), cfg.APIKey), nil
+	case "llamacpp", "llama.cpp":
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = "http://localhost:8080/v1"
+		}
+		return NewOpenAIEmbedder(client, baseURL, cfg.Model, cfg.APIKey), nil
+	case "ollama":
+		return NewOllamaEmbedder(client, cfg.BaseURL, modelOr(cfg.Model, "nomic-embed-text")), nil
+	case "huggingface", "hf":
+		return NewHuggingFaceEmbedder(client, cfg.BaseURL, modelOr(cfg.Model, "sentence-transformers/all-MiniLM-L6-v2"), cfg.APIKey), nil
+	default:
+		return nil, fmt.Errorf("unknown embedding provider: %s", cfg.Provider)
+	}
+}
+
+func modelOr(model, fallback string) string {
+	if model == "" {
+		return fallback
+	}
+	return model
+}
+
+// HashingEmbedder embeds text offline by hashing its words and word pairs
+// into a fixed number of buckets, weighted by log term frequency. It
+// matches entries that share vocabulary rather than meaning, which is
+// enough for small journals and needs no model.
+type HashingEmbedder struct {
+	dims int
+}
+
+func NewHashingEmbedder(dims int) *HashingEmbedder {
+	if dims <= 0 {
+		dims = 512
+	}
+	return &HashingEmbedder{dims: dims}
+}
+
+func (h *HashingEmbedder) Name() string { return fmt.Sprintf("hashing-%d", h.dims) }
+
+func (h *HashingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = h.embed(text)
+	}
+	return vectors, nil
+}
+
+func (h *HashingEmbedder) embed(text string) []float32 {
+	words := embeddingTerms(text)
+	counts := make(map[string]int)
+	for i, w := range words {
+		counts[w]++
+		if i > 0 {
+			counts[words[i-1]+" "+w]++
+		}
+	}
+
+	vector := make([]float32, h.dims)
+	for term, n := range counts {
+		hash := fnv.New64a()
+		hash.Write([]byte(term))
+		sum := hash.Sum64()
+
+		// The top bit picks a sign so collisions tend to cancel out
+		weight := float32(1 + math.Log(float64(n)))
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		vector[sum%uint64(h.dims)] += weight
+	}
+	normalize(vector)
+	return vector
+}
+
+// embeddingStopwords carry little meaning and would otherwise dominate
+// hashed vectors.
+var embeddingStopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"but": true, "by": true, "for": true, "from": true, "had": true, "has": true,
+	"have": true, "i": true, "i'm": true, "in": true, "is": true, "it": true, "it's": true,
+	"me": true, "my": true, "of": true, "on": true, "or": true, "so": true, "that": true,
+	"the": true, "this": true, "to": true, "was": true, "were": true, "with": true,
+}
+
+func embeddingTerms(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+
+	terms := fields[:0]
+	for _, f := range fields {
+		f = strings.Trim(f, "'")
+		if f != "" && !embeddingStopwords[f] {
+			terms = append(terms, stem(f))
+		}
+	}
+	return terms
+}
+
+// stem strips a few common English suffixes so "stressed" and "stressing"
+// hash to the same bucket. It is deliberately crude.
+func stem(word string) string {
+	for _, suffix := range []string{"ing", "ed", "s"} {
+		if len(word) > len(suffix)+3 && strings.HasSuffix(word, suffix) {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}
+
+func normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b, or 0
+// if their lengths differ or either is zero.
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
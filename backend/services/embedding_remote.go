@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Remote embedders send entry text to the embedding API, so they redact it
+// first when the request's context carries a redaction session.
+
+// OpenAIEmbedder calls an OpenAI-style embeddings endpoint, which llama.cpp
+// and vLLM also serve.
+type OpenAIEmbedder struct {
+	client  *http.Client
+	baseURL string
+	model   string
+	apiKey  string
+}
+
+func NewOpenAIEmbedder(client *http.Client, baseURL, model, apiKey string) *OpenAIEmbedder {
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	return &OpenAIEmbedder{
+		client:  defaultClient(client),
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   model,
+		apiKey:  apiKey,
+	}
+}
+
+func (e *OpenAIEmbedder) Name() string { return "openai:" + e.model }
+
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	payload := map[string]interface{}{
+		"model": e.model,
+		"input": redactTexts(ctx, texts),
+	}
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := postJSON(ctx, e.client, e.baseURL+"/embeddings", e.apiKey, payload, &result); err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return checkEmbeddings(vectors)
+}
+
+// OllamaEmbedder calls a local Ollama server's embed API.
+type OllamaEmbedder struct {
+	client  *http.Client
+	baseURL string
+	model   string
+}
+
+func NewOllamaEmbedder(client *http.Client, baseURL, model string) *OllamaEmbedder {
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+	return &OllamaEmbedder{
+		client:  defaultClient(client),
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   model,
+	}
+}
+
+func (e *OllamaEmbedder) Name() string { return "ollama:" + e.model }
+
+func (e *OllamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	payload := map[string]interface{}{
+		"model": e.model,
+		"input": redactTexts(ctx, texts),
+	}
+
+	var result struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := postJSON(ctx, e.client, e.baseURL+"/api/embed", "", payload, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(result.Embeddings))
+	}
+	return checkEmbeddings(result.Embeddings)
+}
+
+// HuggingFaceEmbedder calls the Hugging Face feature-extraction inference
+// API with a sentence-transformers model.
+type HuggingFaceEmbedder struct {
+	client  *http.Client
+	baseURL string
+	model   string
+	apiKey  string
+}
+
+func NewHuggingFaceEmbedder(client *http.Client, baseURL, model, apiKey string) *HuggingFaceEmbedder {
+	if baseURL == "" {
+		baseURL = "https://api-inference.huggingface.co/models"
+	}
+	return &HuggingFaceEmbedder{
+		client:  defaultClient(client),
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   model,
+		apiKey:  apiKey,
+	}
+}
+
+func (e *HuggingFaceEmbedder) Name() string { return "huggingface:" + e.model }
+
+func (e *HuggingFaceEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if e.apiKey == "" {
+		return nil, fmt.Errorf("API key is not set")
+	}
+
+	payload := map[string]interface{}{
+		"inputs": redactTexts(ctx, texts),
+		"options": map[string]interface{}{
+			"wait_for_model": true,
+		},
+	}
+
+	var vectors [][]float32
+	if err := postJSON(ctx, e.client, e.baseURL+"/"+e.model, e.apiKey, payload, &vectors); err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(vectors))
+	}
+	return checkEmbeddings(vectors)
+}
+
+func checkEmbeddings(vectors [][]float32) ([][]float32, error) {
+	for i, v := range vectors {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("no embedding returned for input %d", i)
+		}
+	}
+	return vectors, nil
+}
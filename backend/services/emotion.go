@@ -0,0 +1,77 @@
+package services
+
+import (
+	"math"
+	"sort"
+
+	"go_health_sentiment/models"
+	"go_health_sentiment/sentiment"
+)
+
+// modelEmotionWeight is the share of a refined intensity that comes from
+// the model's reading of the entry; the rest comes from the lexicon.
+const modelEmotionWeight = 0.6
+
+// LexiconEmotions scores an entry's emotions offline with the lexicon for
+// its language.
+func LexiconEmotions(content, language string) []models.EntryEmotion {
+	var emotions []models.EntryEmotion
+	for _, s := range sentiment.ForText(language, content).Emotions(content) {
+		emotions = append(emotions, models.EntryEmotion{
+			Emotion:   s.Emotion,
+			Intensity: s.Intensity,
+			Source:    models.EmotionSourceLexicon,
+		})
+	}
+	return emotions
+}
+
+// RefineEmotions blends the lexicon scores of an entry with the emotions
+// the model named in its analysis. The model's labels are free-form, so
+// each is mapped onto Plutchik emotions through the lexicon; labels it
+// doesn't know are ignored. If none can be mapped the lexicon scores are
+// returned unchanged. Prompts ask for English labels whatever the entry's
+// language, but labels in the entry's language are understood too.
+func RefineEmotions(content, language string, named []models.EmotionIntensity) []models.EntryEmotion {
+	lexicon := LexiconEmotions(content, language)
+
+	fromModel := make(map[string]float64)
+	for _, e := range named {
+		weights := sentiment.EmotionWeights(e.Emotion)
+		if weights == nil {
+			weights = sentiment.ForText(language, content).EmotionWeights(e.Emotion)
+		}
+		for emotion, weight := range weights {
+			if v := weight * e.Intensity; v > fromModel[emotion] {
+				fromModel[emotion] = v
+			}
+		}
+	}
+	if len(fromModel) == 0 {
+		return lexicon
+	}
+
+	fromLexicon := make(map[string]float64, len(lexicon))
+	for _, e := range lexicon {
+		fromLexicon[e.Emotion] = e.Intensity
+	}
+
+	var refined []models.EntryEmotion
+	for _, emotion := range sentiment.EmotionLabels {
+		intensity := modelEmotionWeight*fromModel[emotion] + (1-modelEmotionWeight)*fromLexicon[emotion]
+		intensity = math.Round(intensity*10000) / 10000
+		if intensity < sentiment.MinEmotionIntensity {
+			continue
+		}
+		refined = append(refined, models.EntryEmotion{
+			Emotion:   emotion,
+			Intensity: intensity,
+			Source:    models.EmotionSourceModel,
+		})
+	}
+
+	sort.SliceStable(refined, func(i, j int) bool {
+		return refined[i].Intensity > refined[j].Intensity
+	})
+	return refined
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go_health_sentiment/models"
+	"go_health_sentiment/promptguard"
+	"go_health_sentiment/prompts"
+)
+
+// ErrCrisisEntry is returned when asked for a model reply about an entry
+// that was answered with crisis resources.
+var ErrCrisisEntry = errors.New("entry was answered with crisis resources")
+
+// EntryThread is the persisted chat about one journal entry.
+type EntryThread struct {
+	JournalID int                          `json:"journal_id"`
+	Summary   string                       `json:"summary,omitempty"`
+	Messages  []models.ConversationMessage `json:"messages"`
+}
+
+// GetEntryThread returns every message of the chat about entry.
+func (c *ChatConversation) GetEntryThread(ctx context.Context, entry *models.JournalEntry) (*EntryThread, error) {
+	thread := &EntryThread{JournalID: entry.ID, Messages: []models.ConversationMessage{}}
+
+	conv, err := models.GetEntryConversation(ctx, c.db, entry.ID)
+	if err != nil {
+		if err.Error() == "conversation not found" {
+			return thread, nil
+		}
+		return nil, err
+	}
+	thread.Summary = conv.Summary
+
+	messages, err := models.GetMessagesAfter(ctx, c.db, conv.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+	if messages != nil {
+		thread.Messages = messages
+	}
+	return thread, nil
+}
+
+// ReplyToEntry adds the user's message to the chat about entry and returns
+// the model's reply. screen, if set, may replace the reply before it is
+// stored. The model sees the entry, its analysis, a summary of older turns
+// and the newest ChatWindow messages. Entries answered with crisis
+// resources get ErrCrisisEntry without a model call.
+func (c *ChatConversation) ReplyToEntry(ctx context.Context, entry *models.JournalEntry, message string, screen func(reply string) string) (*models.ConversationMessage, error) {
+	if entry.RiskSource == "entry" {
+		return nil, ErrCrisisEntry
+	}
+
+	ctx, cancel := c.replyContext(ctx)
+	defer cancel()
+
+	start := time.Now()
+	ctx, meter := withUsageMeter(ctx)
+	ctx, err := c.withRedaction(ctx, entry.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, promptID, err := c.replyToEntry(ctx, entry, message, screen)
+	c.recordUsage(ctx, entry.UserID, promptID, false, meter.Usage(), time.Since(start), err)
+	return reply, contextError(ctx, err)
+}
+
+func (c *ChatConversation) replyToEntry(ctx context.Context, entry *models.JournalEntry, message string, screen func(reply string) string) (*models.ConversationMessage, string, error) {
+	conv, err := models.GetOrCreateEntryConversation(ctx, c.db, entry.UserID, entry.ID)
+	if err != nil {
+		return nil, "", fmt.Errorf("error loading conversation: %v", err)
+	}
+
+	recent, err := c.entryContext(ctx, conv)
+	if err != nil {
+		return nil, "", err
+	}
+
+	prefs, err := models.GetUserResponsePreferences(ctx, c.db, entry.UserID)
+	if err != nil {
+		return nil, "", fmt.Errorf("error loading response preferences: %v", err)
+	}
+
+	language := entryLanguage(entry)
+	tmpl, err := c.prompts.ResolveLanguage(prompts.EntryChat, "", language)
+	if err != nil {
+		return nil, "", err
+	}
+	system, err := tmpl.Render(map[string]string{
+		"Entry":    promptguard.Delimit(entry.Content),
+		"Analysis": promptguard.Escape(entry.Analysis),
+		"Summary":  promptguard.Escape(conv.Summary),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	style, err := c.styleInstructions(prefs, language)
+	if err != nil {
+		return nil, "", err
+	}
+	system = withStyle(system, style)
+
+	messages := make([]Message, 0, len(recent)+2)
+	messages = append(messages, Message{Role: "system", Content: system})
+	// The user's turns are tagged like the entry, which the prompt tells the
+	// model never to take instructions from
+	for _, m := range recent {
+		content := m.Content
+		if m.Role == "user" {
+			content = promptguard.Delimit(content)
+		}
+		messages = append(messages, Message{Role: m.Role, Content: content})
+	}
+	messages = append(messages, Message{Role: "user", Content: promptguard.Delimit(message)})
+
+	text, err := c.provider.Generate(ctx, GenerateRequest{Messages: messages, Params: styledParams(c.opts.Params, prefs)})
+	if err != nil {
+		return nil, tmpl.ID(), err
+	}
+
+	text, reasons := promptguard.NewOutputFilter(system).Filter(text)
+	if len(reasons) > 0 {
+		log.Printf("Output filter removed content from chat reply: %s", strings.Join(reasons, ", "))
+	}
+	if text == "" {
+		text = SafeFallbackMessage
+	}
+	if screen != nil {
+		text = screen(text)
+	}
+
+	stored, err := c.AppendEntryMessages(ctx, entry, message, text)
+	if err != nil {
+		return nil, tmpl.ID(), err
+	}
+	return &stored[1], tmpl.ID(), nil
+}
+
+// AppendEntryMessages stores a user message and the reply to it in the chat
+// about entry without calling the model, e.g. when the reply is a fixed
+// crisis response.
+func (c *ChatConversation) AppendEntryMessages(ctx context.Context, entry *models.JournalEntry, message, reply string) ([]models.ConversationMessage, error) {
+	conv, err := models.GetOrCreateEntryConversation(ctx, c.db, entry.UserID, entry.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading conversation: %v", err)
+	}
+
+	stored, err := models.AppendMessages(ctx, c.db, conv.ID, 0,
+		models.ConversationMessage{Role: "user", Content: message},
+		models.ConversationMessage{Role: "assistant", Content: reply},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error saving conversation: %v", err)
+	}
+	return stored, nil
+}
+
+// entryContext returns the messages that fit in the context window. Once
+// the window is full, all but its newest half are folded into the
+// conversation summary, so a summary is written every few turns rather than
+// on each one. If summarizing fails the older messages are left out and
+// summarized on a later turn.
+func (c *ChatConversation) entryContext(ctx context.Context, conv *models.Conversation) ([]models.ConversationMessage, error) {
+	messages, err := models.GetMessagesAfter(ctx, c.db, conv.ID, conv.SummarizedThrough)
+	if err != nil {
+		return nil, fmt.Errorf("error loading conversation: %v", err)
+	}
+	if len(messages) <= c.opts.ChatWindow {
+		return messages, nil
+	}
+
+	keep := c.opts.ChatWindow / 2
+	older, recent := messages[:len(messages)-keep], messages[len(messages)-keep:]
+	summary, err := c.summarize(ctx, conv.Summary, older)
+	if err != nil {
+		log.Printf("Error summarizing conversation %d: %v", conv.ID, err)
+		return messages[len(messages)-c.opts.ChatWindow:], nil
+	}
+
+	through := older[len(older)-1].ID
+	if err := models.UpdateConversationSummary(ctx, c.db, conv.ID, summary, through); err != nil {
+		return nil, fmt.Errorf("error saving conversation summary: %v", err)
+	}
+	conv.Summary, conv.SummarizedThrough = summary, through
+	return recent, nil
+}
+
+// summarize asks the model to fold messages into the running summary.
+func (c *ChatConversation) summarize(ctx context.Context, summary string, messages []models.ConversationMessage) (string, error) {
+	var transcript strings.Builder
+	for _, m := range messages {
+		speaker := "Writer"
+		if m.Role == "assistant" {
+			speaker = "Companion"
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n", speaker, promptguard.Escape(m.Content))
+	}
+
+	tmpl, err := c.prompts.Resolve(prompts.ChatSummary, "")
+	if err != nil {
+		return "", err
+	}
+	prompt, err := tmpl.Render(map[string]string{
+		"Summary":    promptguard.Escape(summary),
+		"Transcript": transcript.String(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	text, err := c.provider.Generate(ctx, GenerateRequest{
+		Messages: []Message{{Role: "user", Content: prompt}},
+		Params:   c.opts.Params,
+	})
+	if err != nil {
+		return "", err
+	}
+	if text == "" {
+		return "", fmt.Errorf("model returned an empty summary")
+	}
+	return text, nil
+}
@@ -0,0 +1,16 @@
+package services
+
+import (
+	"go_health_sentiment/langdetect"
+	"go_health_sentiment/models"
+)
+
+// entryLanguage returns the language the entry was written in, detecting
+// it for entries saved before languages were recorded. It is empty when
+// the language can't be told, and prompts then fall back to English.
+func entryLanguage(entry *models.JournalEntry) string {
+	if entry.Language != "" {
+		return entry.Language
+	}
+	return langdetect.Detect(entry.Content).Language
+}
@@ -1,14 +1,18 @@
 package services
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"os"
+	"log"
 	"strings"
 	"time"
+
+	"go_health_sentiment/models"
+	"go_health_sentiment/promptguard"
+	"go_health_sentiment/prompts"
+	"go_health_sentiment/redact"
 )
 
 type Message struct {
@@ -16,116 +20,463 @@ type Message struct {
 	Content string `json:"content"`
 }
 
+// ChatOptions tune how entries are analyzed.
+type ChatOptions struct {
+	Params         GenerationParams
+	HistoryLimit   int
+	RepairAttempts int
+
+	// ChatWindow is the number of recent messages sent with each reply in a
+	// chat about an entry; older ones are summarized
+	ChatWindow int
+
+	// Cache, when set, reuses analyses of identical entries
+	Cache *AnalysisCache
+
+	// Usage, when set, records the tokens and outcome of every analysis
+	Usage *UsageTracker
+
+	// Redactor, when set, replaces PII in model requests for users who have
+	// redaction on; RedactByDefault applies to users who haven't chosen
+	Redactor        *redact.Redactor
+	RedactByDefault bool
+
+	// History selects the notes on earlier entries sent with each analysis;
+	// Index, when set, adds similar entries to the latest ones
+	History HistoryOptions
+	Index   *SemanticIndex
+
+	// ReplyTimeout bounds each chat reply and style preview; 0 leaves them
+	// to the caller's deadline
+	ReplyTimeout time.Duration
+}
+
+// Analysis is a validated analysis together with the prompt and model that
+// produced it.
+type Analysis struct {
+	Result        *models.AnalysisResult
+	PromptVersion string
+	Model         string
+
+	// Cached is set when the result came from the cache without a model call
+	Cached bool
+}
+
+// ChatConversation analyzes journal entries and keeps a per-user
+// conversation history in Postgres. It holds no per-user state itself, so a
+// single instance is safe to share between requests.
 type ChatConversation struct {
-	Messages []Message
-	client   *http.Client
+	db       *sql.DB
+	provider Provider
+	prompts  *prompts.Registry
+	opts     ChatOptions
 }
 
-func NewChatConversation() *ChatConversation {
+func NewChatConversation(db *sql.DB, provider Provider, registry *prompts.Registry, opts ChatOptions) *ChatConversation {
+	if opts.HistoryLimit <= 0 {
+		opts.HistoryLimit = 10
+	}
+	if opts.RepairAttempts < 0 {
+		opts.RepairAttempts = 0
+	}
+	if opts.ChatWindow < 2 {
+		opts.ChatWindow = 12
+	}
+	if opts.History.Recent <= 0 {
+		opts.History.Recent = 5
+	}
+	if opts.History.Lookback <= 0 {
+		opts.History.Lookback = 30 * 24 * time.Hour
+	}
+	if opts.Redactor != nil {
+		provider = &redactingProvider{Provider: provider}
+	}
 	return &ChatConversation{
-		Messages: []Message{},
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		db:       db,
+		provider: provider,
+		prompts:  registry,
+		opts:     opts,
 	}
 }
 
-func (c *ChatConversation) AnalyzeJournalEntry(content string) (string, error) {
-	apiURL := "https://api-inference.huggingface.co/models/meta-llama/Llama-3.2-1B-Instruct"
-	apiKey := os.Getenv("OPENAI_API_KEY")
+// Provider returns the backend used for analysis.
+func (c *ChatConversation) Provider() Provider {
+	return c.provider
+}
+
+// analysisRequest is a prepared model request for one entry.
+type analysisRequest struct {
+	conv     *models.Conversation
+	req      GenerateRequest
+	promptID string
+	filter   *promptguard.OutputFilter
+
+	// history is the notes on earlier entries included in the prompt
+	history string
+
+	// style is the user's response preferences as sent to the model
+	style string
+}
 
-	if apiKey == "" {
-		return "", fmt.Errorf("API key is not set")
+// AnalyzeJournalEntry analyzes an entry. With bypassCache a cached analysis
+// of the same entry is ignored and replaced by the new one.
+func (c *ChatConversation) AnalyzeJournalEntry(ctx context.Context, entry *models.JournalEntry, bypassCache bool) (*Analysis, error) {
+	ar, err := c.buildRequest(ctx, entry)
+	if err != nil {
+		return nil, err
 	}
 
-	// Create a more structured prompt for better analysis
-	prompt := fmt.Sprintf(`You are an empathetic AI mental health companion. Analyze the following journal entry and provide supportive, insightful feedback. Focus on:
-1. Emotional tone and sentiment
-2. Potential patterns or themes
-3. Supportive encouragement
-4. Gentle suggestions for reflection or self-care
+	return c.analyze(ctx, ar, entry.UserID, entry.Content, bypassCache, func(ctx context.Context) (string, error) {
+		return c.provider.Generate(ctx, ar.req)
+	}, nil)
+}
 
-Journal Entry: "%s"
+// StreamJournalEntry analyzes an entry like AnalyzeJournalEntry but passes
+// the supportive message to onToken as it is generated. Providers without
+// streaming support, and cached analyses, deliver the whole message in one
+// call.
+func (c *ChatConversation) StreamJournalEntry(ctx context.Context, entry *models.JournalEntry, bypassCache bool, onToken TokenFunc) (*Analysis, error) {
+	ar, err := c.buildRequest(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
 
-Response:`, content)
+	return c.analyze(ctx, ar, entry.UserID, entry.Content, bypassCache, func(ctx context.Context) (string, error) {
+		streamer := newFieldStreamer("supportive_message", onToken)
+		return GenerateStream(ctx, c.provider, ar.req, streamer.Write)
+	}, onToken)
+}
 
-	payload := map[string]interface{}{
-		"inputs": prompt,
-		"parameters": map[string]interface{}{
-			"max_new_tokens": 200,
-			"temperature":    0.7,
-			"do_sample":      true,
-			"top_p":          0.9,
-		},
-		"options": map[string]interface{}{
-			"wait_for_model": true,
-		},
+// AnalyzeText analyzes content on its own, outside any user's journal: no
+// conversation history, response style, cache, redaction or usage
+// accounting applies. It is meant for offline evaluation of prompts and
+// models; language selects the prompt variant as for entries.
+func (c *ChatConversation) AnalyzeText(ctx context.Context, content, language string) (*Analysis, error) {
+	ar, err := c.newAnalysisRequest("", language, content, "", models.ResponsePreferences{}, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	body, err := json.Marshal(payload)
+	result, err := c.generateAnalysis(ctx, ar)
 	if err != nil {
-		return "", fmt.Errorf("error encoding request payload: %v", err)
+		return nil, err
 	}
+	return &Analysis{Result: result, PromptVersion: ar.promptID, Model: c.provider.Model()}, nil
+}
+
+// generateAnalysis sends a prepared request without touching the cache or
+// conversation history and returns the validated, filtered result.
+func (c *ChatConversation) generateAnalysis(ctx context.Context, ar *analysisRequest) (*models.AnalysisResult, error) {
+	raw, err := c.provider.Generate(ctx, ar.req)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.parseWithRepair(ctx, ar.req, raw)
+	if err != nil {
+		return nil, err
+	}
+	filterResult(ar.filter, result)
+	return result, nil
+}
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(body))
+// analyze returns the cached analysis for the entry when there is one and
+// otherwise calls generate, validates the reply and records the exchange in
+// the user's conversation history. bypassCache skips the lookup but still
+// caches the new analysis. onCached, if set, receives the message of a
+// cached analysis. Every call is recorded for usage accounting.
+func (c *ChatConversation) analyze(ctx context.Context, ar *analysisRequest, userID int, content string, bypassCache bool, generate func(ctx context.Context) (string, error), onCached TokenFunc) (*Analysis, error) {
+	start := time.Now()
+	ctx, meter := withUsageMeter(ctx)
+	ctx, err := c.withRedaction(ctx, userID)
 	if err != nil {
-		return "", fmt.Errorf("error creating request: %v", err)
+		return nil, err
+	}
+
+	compute := func() (*models.AnalysisResult, error) {
+		raw, err := generate(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := c.parseWithRepair(ctx, ar.req, raw)
+		if err != nil {
+			return nil, err
+		}
+		filterResult(ar.filter, result)
+
+		if err := c.saveExchange(ctx, ar.conv, content, result.SupportiveMessage); err != nil {
+			return nil, err
+		}
+		return result, nil
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+	analysis := &Analysis{
+		PromptVersion: ar.promptID,
+		Model:         c.provider.Model(),
+	}
 
-	resp, err := c.client.Do(req)
+	if c.opts.Cache == nil {
+		analysis.Result, err = compute()
+	} else {
+		key := AnalysisCacheKey(userID, content, ar.history, ar.style, analysis.PromptVersion, analysis.Model)
+		if bypassCache {
+			analysis.Result, analysis.Cached, err = c.opts.Cache.Recompute(ctx, userID, key, compute)
+		} else {
+			analysis.Result, analysis.Cached, err = c.opts.Cache.GetOrCompute(ctx, userID, key, compute)
+		}
+	}
+	c.recordUsage(ctx, userID, analysis.PromptVersion, analysis.Cached, meter.Usage(), time.Since(start), err)
 	if err != nil {
-		return "", fmt.Errorf("error sending request: %v", err)
+		return nil, err
+	}
+
+	if analysis.Cached {
+		log.Printf("Reused cached analysis for user %d", userID)
+	}
+	if analysis.Cached && onCached != nil {
+		if err := onCached(analysis.Result.SupportiveMessage); err != nil {
+			return nil, err
+		}
 	}
-	defer resp.Body.Close()
+	return analysis, nil
+}
+
+// withRedaction adds a redaction session to ctx when PII redaction is on
+// for the user.
+func (c *ChatConversation) withRedaction(ctx context.Context, userID int) (context.Context, error) {
+	return withUserRedaction(ctx, c.db, c.opts.Redactor, c.opts.RedactByDefault, userID)
+}
 
-	respBody, err := ioutil.ReadAll(resp.Body)
+// replyContext bounds a reply made while the user waits by ReplyTimeout.
+func (c *ChatConversation) replyContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.opts.ReplyTimeout > 0 {
+		return context.WithTimeout(ctx, c.opts.ReplyTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// recordUsage stores the usage of one analysis or chat reply.
+func (c *ChatConversation) recordUsage(ctx context.Context, userID int, promptID string, cached bool, usage Usage, latency time.Duration, err error) {
+	if c.opts.Usage == nil {
+		return
+	}
+
+	var schemaErr *SchemaError
+	outcome := models.UsageSuccess
+	switch {
+	case err == nil && cached:
+		outcome = models.UsageCached
+	case err == nil:
+	case ctx.Err() != nil:
+		outcome = models.UsageCancelled
+	case errors.As(err, &schemaErr):
+		outcome = models.UsageInvalidOutput
+	case errors.Is(err, ErrCircuitOpen), retryable(err):
+		outcome = models.UsageUnavailable
+	default:
+		outcome = models.UsageError
+	}
+
+	c.opts.Usage.Record(ctx, &models.UsageRecord{
+		UserID:           userID,
+		Provider:         c.provider.Name(),
+		Model:            c.provider.Model(),
+		PromptVersion:    promptID,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Estimated:        usage.Estimated,
+		LatencyMs:        latency.Milliseconds(),
+		Outcome:          outcome,
+	})
+}
+
+// filterResult strips sentences that echo the instructions or claim clinical
+// credentials from every user-facing field of the result.
+func filterResult(filter *promptguard.OutputFilter, result *models.AnalysisResult) {
+	var removed []string
+
+	message, reasons := filter.Filter(result.SupportiveMessage)
+	removed = append(removed, reasons...)
+	if message == "" {
+		message = SafeFallbackMessage
+	}
+	result.SupportiveMessage = message
+
+	suggestions := result.ReflectionSuggestions[:0]
+	for _, s := range result.ReflectionSuggestions {
+		filtered, reasons := filter.Filter(s)
+		removed = append(removed, reasons...)
+		if filtered != "" {
+			suggestions = append(suggestions, filtered)
+		}
+	}
+	result.ReflectionSuggestions = suggestions
+
+	if len(removed) > 0 {
+		log.Printf("Output filter removed content from model reply: %s", strings.Join(removed, ", "))
+	}
+}
+
+// parseWithRepair validates raw and, when it is malformed, asks the model to
+// correct it up to RepairAttempts times.
+func (c *ChatConversation) parseWithRepair(ctx context.Context, req GenerateRequest, raw string) (*models.AnalysisResult, error) {
+	result, err := ParseAnalysisResult(raw)
+	for attempt := 0; err != nil && attempt < c.opts.RepairAttempts; attempt++ {
+		req.Messages = append(req.Messages,
+			Message{Role: "assistant", Content: raw},
+			Message{Role: "user", Content: repairMessage(err)},
+		)
+
+		raw, err = c.provider.Generate(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		result, err = ParseAnalysisResult(raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// promptData is the data available to analysis templates. Content is the
+// escaped entry text; Entry is the same text wrapped in delimiter tags.
+// History holds notes on earlier entries, when enabled.
+type promptData struct {
+	Content string
+	Entry   string
+	Schema  string
+	History string
+}
+
+// buildRequest prepares the analysis of entry. With a history budget the
+// prompt carries notes on the user's earlier entries; otherwise their
+// recent conversation turns are sent ahead of it.
+func (c *ChatConversation) buildRequest(ctx context.Context, entry *models.JournalEntry) (*analysisRequest, error) {
+	userID, content := entry.UserID, entry.Content
+	conv, err := models.GetOrCreateConversation(ctx, c.db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading conversation: %v", err)
+	}
+
+	var history []models.ConversationMessage
+	var notes string
+	if c.opts.History.Tokens > 0 {
+		if notes, err = c.entryHistory(ctx, entry); err != nil {
+			return nil, err
+		}
+	} else {
+		history, err = models.GetRecentMessages(ctx, c.db, conv.ID, c.opts.HistoryLimit)
+		if err != nil {
+			return nil, fmt.Errorf("error loading conversation history: %v", err)
+		}
+	}
+
+	// Users may be pinned to a prompt version; otherwise the deployment default applies
+	version, err := models.GetUserPromptVersion(ctx, c.db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading prompt preference: %v", err)
+	}
+	prefs, err := models.GetUserResponsePreferences(ctx, c.db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading response preferences: %v", err)
+	}
+
+	ar, err := c.newAnalysisRequest(version, entryLanguage(entry), content, notes, prefs, history)
 	if err != nil {
-		return "", fmt.Errorf("error reading response body: %v", err)
+		return nil, err
 	}
+	ar.conv = conv
+	return ar, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(respBody))
+// newAnalysisRequest renders the analysis prompt for content in the user's
+// response style, after any conversation history.
+func (c *ChatConversation) newAnalysisRequest(version, language, content, notes string, prefs models.ResponsePreferences, history []models.ConversationMessage) (*analysisRequest, error) {
+	// Entries written in another language get that language's variant of the
+	// prompt, so the reply comes back in it
+	tmpl, err := c.prompts.ResolveLanguage(prompts.Analysis, version, language)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := tmpl.RenderMessages(promptData{
+		Content: promptguard.Escape(content),
+		Entry:   promptguard.Delimit(content),
+		Schema:  analysisSchema,
+		History: notes,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	var result []map[string]interface{}
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		return "", fmt.Errorf("error decoding response: %v", err)
+	style, err := c.styleInstructions(prefs, language)
+	if err != nil {
+		return nil, err
 	}
+	rendered.System = withStyle(rendered.System, style)
 
-	if len(result) > 0 {
-		if generatedText, ok := result[0]["generated_text"].(string); ok {
-			// Extract the response part after "Response:"
-			if idx := strings.Index(generatedText, "Response:"); idx != -1 {
-				response := strings.TrimSpace(generatedText[idx+9:])
-				if response != "" {
-					// Store the conversation for context
-					c.Messages = append(c.Messages, Message{Role: "user", Content: content})
-					c.Messages = append(c.Messages, Message{Role: "assistant", Content: response})
-					
-					// Keep only last 10 messages to prevent context from growing too large
-					if len(c.Messages) > 10 {
-						c.Messages = c.Messages[len(c.Messages)-10:]
-					}
-					
-					return response, nil
-				}
-			}
-			
-			// Fallback: return the full generated text if we can't parse it
-			return strings.TrimSpace(generatedText), nil
+	// Instructions go in their own system message, ahead of the history, so
+	// chat-style providers keep them separate from anything the user wrote
+	messages := make([]Message, 0, len(history)+2)
+	if rendered.System != "" {
+		messages = append(messages, Message{Role: "system", Content: rendered.System})
+	}
+	for _, m := range history {
+		content := m.Content
+		if m.Role == "user" {
+			content = promptguard.Delimit(content)
 		}
+		messages = append(messages, Message{Role: m.Role, Content: content})
+	}
+	messages = append(messages, Message{Role: "user", Content: rendered.User})
+
+	return &analysisRequest{
+		req:      GenerateRequest{Messages: messages, Params: styledParams(c.opts.Params, prefs), JSONMode: true},
+		promptID: tmpl.ID(),
+		filter:   promptguard.NewOutputFilter(rendered.System),
+		history:  notes,
+		style:    style,
+	}, nil
+}
+
+// saveExchange stores the entry and reply, keeping only the most recent
+// messages for context.
+func (c *ChatConversation) saveExchange(ctx context.Context, conv *models.Conversation, content, response string) error {
+	if response == "" {
+		return fmt.Errorf("failed to extract response from model")
 	}
 
-	return "", fmt.Errorf("failed to extract response from model")
+	_, err := models.AppendMessages(ctx, c.db, conv.ID, c.opts.HistoryLimit,
+		models.ConversationMessage{Role: "user", Content: content},
+		models.ConversationMessage{Role: "assistant", Content: response},
+	)
+	if err != nil {
+		return fmt.Errorf("error saving conversation history: %v", err)
+	}
+	return nil
 }
 
-func (c *ChatConversation) GetConversationHistory() []Message {
-	return c.Messages
+func (c *ChatConversation) GetConversationHistory(ctx context.Context, userID int) ([]Message, error) {
+	conv, err := models.GetConversationByUser(ctx, c.db, userID)
+	if err != nil {
+		if err.Error() == "conversation not found" {
+			return []Message{}, nil
+		}
+		return nil, err
+	}
+
+	history, err := models.GetRecentMessages(ctx, c.db, conv.ID, c.opts.HistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, 0, len(history))
+	for _, m := range history {
+		messages = append(messages, Message{Role: m.Role, Content: m.Content})
+	}
+	return messages, nil
 }
 
-func (c *ChatConversation) ClearHistory() {
-	c.Messages = []Message{}
-}
\ No newline at end of file
+func (c *ChatConversation) ClearHistory(ctx context.Context, userID int) error {
+	return models.ClearUserConversation(ctx, c.db, userID)
+}
@@ -0,0 +1,55 @@
+package services
+
+import "sync"
+
+// AnalysisNotifier lets in-process subscribers wait for an entry's analysis
+// to finish. It only covers the current process; clients on other instances
+// fall back to polling GET /journal/{id}.
+type AnalysisNotifier struct {
+	mu          sync.Mutex
+	subscribers map[int]map[chan struct{}]struct{}
+}
+
+func NewAnalysisNotifier() *AnalysisNotifier {
+	return &AnalysisNotifier{
+		subscribers: make(map[int]map[chan struct{}]struct{}),
+	}
+}
+
+// Subscribe returns a channel that is closed when the entry's analysis
+// finishes, and a function that releases the subscription.
+func (n *AnalysisNotifier) Subscribe(entryID int) (<-chan struct{}, func()) {
+	ch := make(chan struct{})
+
+	n.mu.Lock()
+	if n.subscribers[entryID] == nil {
+		n.subscribers[entryID] = make(map[chan struct{}]struct{})
+	}
+	n.subscribers[entryID][ch] = struct{}{}
+	n.mu.Unlock()
+
+	cancel := func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		if subs, ok := n.subscribers[entryID]; ok {
+			if _, ok := subs[ch]; ok {
+				delete(subs, ch)
+				close(ch)
+			}
+			if len(subs) == 0 {
+				delete(n.subscribers, entryID)
+			}
+		}
+	}
+	return ch, cancel
+}
+
+// Publish wakes every subscriber waiting on the entry.
+func (n *AnalysisNotifier) Publish(entryID int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for ch := range n.subscribers[entryID] {
+		close(ch)
+	}
+	delete(n.subscribers, entryID)
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_health_sentiment/config"
+)
+
+// GenerationParams are the sampling parameters forwarded to the model.
+type GenerationParams struct {
+	MaxTokens   int
+	Temperature float64
+	TopP        float64
+}
+
+// GenerateRequest is a provider-agnostic completion request. Chat-style
+// providers send Messages as-is; text-generation providers flatten them.
+type GenerateRequest struct {
+	Messages []Message
+	Params   GenerationParams
+
+	// JSONMode asks providers that support it to constrain output to JSON
+	JSONMode bool
+}
+
+// Provider is implemented by every LLM backend the analyzer can talk to.
+type Provider interface {
+	Name() string
+	Model() string
+	Generate(ctx context.Context, req GenerateRequest) (string, error)
+}
+
+// NewProvider builds the provider selected in the configuration.
+func NewProvider(cfg config.LLMConfig) (Provider, error) {
+	client := &http.Client{Timeout: cfg.Timeout}
+
+	switch strings.ToLower(cfg.Provider) {
+	case "huggingface", "hf", "":
+		return NewHuggingFaceProvider(client, cfg.BaseURL, cfg.Model, cfg.APIKey), nil
+	case "openai":
+		return NewOpenAIProvider(client, cfg.BaseURL, cfg.Model, cfg.APIKey), nil
+	case "llamacpp", "llama.cpp":
+		// llama.cpp's server exposes an OpenAI-compatible chat completions API
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = "http://localhost:8080/v1"
+		}
+		return NewOpenAIProvider(client, baseURL, cfg.Model, cfg.APIKey), nil
+	case "ollama":
+		return NewOllamaProvider(client, cfg.BaseURL, cfg.Model), nil
+	case "fake":
+		return NewFakeProvider(cfg.Model), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider: %s", cfg.Provider)
+	}
+}
+
+// postJSON sends payload to url and decodes a successful JSON response into out.
+func postJSON(ctx context.Context, client *http.Client, url, apiKey string, payload, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding request payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return &RequestError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &RequestError{Err: fmt.Errorf("error reading response body: %v", err)}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp, respBody)
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("error decoding response: %v", err)
+	}
+	return nil
+}
+
+// APIError is a non-200 response from the model API.
+type APIError struct {
+	StatusCode int
+	Body       string
+
+	// RetryAfter is how long the API asked us to wait, from the Retry-After
+	// header or Hugging Face's estimated_time while a model loads
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// Temporary reports whether the same request may succeed later.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode >= 500
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil {
+			apiErr.RetryAfter = time.Duration(secs) * time.Second
+		} else if at, err := http.ParseTime(v); err == nil {
+			apiErr.RetryAfter = time.Until(at)
+		}
+	}
+
+	if apiErr.RetryAfter <= 0 {
+		var loading struct {
+			EstimatedTime float64 `json:"estimated_time"`
+		}
+		if json.Unmarshal(body, &loading) == nil && loading.EstimatedTime > 0 {
+			apiErr.RetryAfter = time.Duration(loading.EstimatedTime * float64(time.Second))
+		}
+	}
+	return apiErr
+}
+
+// RequestError is a failure to reach the model API at all.
+type RequestError struct {
+	Err error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("error sending request: %v", e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+func defaultClient(client *http.Client) *http.Client {
+	if client == nil {
+		return &http.Client{Timeout: 30 * time.Second}
+	}
+	return client
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"hash/fnv"
+	"strings"
+	"sync"
+)
+
+// FakeProvider returns canned, deterministic replies without touching the
+// network. The same input always produces the same output, and every reply
+// is a valid structured analysis.
+type FakeProvider struct {
+	model string
+
+	mu    sync.Mutex
+	calls []GenerateRequest
+}
+
+var fakeReplies = []string{
+	`{"sentiment_score": 0.1, "primary_emotions": [{"emotion": "reflective", "intensity": 0.6}], "themes": ["daily life"], "supportive_message": "Thank you for sharing this. It sounds like a lot has been on your mind, and taking time to write it down is a meaningful step.", "reflection_suggestions": ["Notice which moments today felt lighter."]}`,
+	`{"sentiment_score": -0.5, "primary_emotions": [{"emotion": "sadness", "intensity": 0.7}, {"emotion": "fatigue", "intensity": 0.5}], "themes": ["stress"], "supportive_message": "It sounds like you have been carrying some heavy feelings. Be gentle with yourself.", "reflection_suggestions": ["Think about one small thing that could bring you comfort this evening."]}`,
+	`{"sentiment_score": 0.7, "primary_emotions": [{"emotion": "joy", "intensity": 0.8}], "themes": ["accomplishment"], "supportive_message": "There is real positive energy in what you wrote. Celebrate these moments.", "reflection_suggestions": ["Reflect on what made today possible so you can return to it."]}`,
+}
+
+func NewFakeProvider(model string) *FakeProvider {
+	if model == "" {
+		model = "fake"
+	}
+	return &FakeProvider{model: model}
+}
+
+func (p *FakeProvider) Name() string  { return "fake" }
+func (p *FakeProvider) Model() string { return p.model }
+
+func (p *FakeProvider) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	p.mu.Lock()
+	p.calls = append(p.calls, req)
+	p.mu.Unlock()
+
+	h := fnv.New32a()
+	for _, m := range req.Messages {
+		h.Write([]byte(m.Role))
+		h.Write([]byte(m.Content))
+	}
+	reply := fakeReplies[h.Sum32()%uint32(len(fakeReplies))]
+	reportUsage(ctx, req, reply, 0, 0)
+	return reply, nil
+}
+
+// Calls returns the requests received so far, for assertions in tests.
+func (p *FakeProvider) Calls() []GenerateRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	calls := make([]GenerateRequest, len(p.calls))
+	copy(calls, p.calls)
+	return calls
+}
+
+// Stream emits the canned reply word by word.
+func (p *FakeProvider) Stream(ctx context.Context, req GenerateRequest, onToken TokenFunc) (string, error) {
+	text, err := p.Generate(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	words := strings.SplitAfter(text, " ")
+	for _, w := range words {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if err := onToken(w); err != nil {
+			return "", err
+		}
+	}
+	return text, nil
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// HuggingFaceProvider calls the Hugging Face text-generation inference API.
+type HuggingFaceProvider struct {
+	client  *http.Client
+	baseURL string
+	model   string
+	apiKey  string
+}
+
+func NewHuggingFaceProvider(client *http.Client, baseURL, model, apiKey string) *HuggingFaceProvider {
+	if baseURL == "" {
+		baseURL = "https://api-inference.huggingface.co/models"
+	}
+	return &HuggingFaceProvider{
+		client:  defaultClient(client),
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   model,
+		apiKey:  apiKey,
+	}
+}
+
+func (p *HuggingFaceProvider) Name() string  { return "huggingface" }
+func (p *HuggingFaceProvider) Model() string { return p.model }
+
+func (p *HuggingFaceProvider) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	if p.apiKey == "" {
+		return "", fmt.Errorf("API key is not set")
+	}
+
+	prompt := renderPrompt(req.Messages)
+	payload := map[string]interface{}{
+		"inputs": prompt,
+		"parameters": map[string]interface{}{
+			"max_new_tokens":   req.Params.MaxTokens,
+			"temperature":      req.Params.Temperature,
+			"top_p":            req.Params.TopP,
+			"do_sample":        true,
+			"return_full_text": false,
+		},
+		"options": map[string]interface{}{
+			"wait_for_model": true,
+		},
+	}
+
+	var result []struct {
+		GeneratedText string `json:"generated_text"`
+	}
+	if err := postJSON(ctx, p.client, p.baseURL+"/"+p.model, p.apiKey, payload, &result); err != nil {
+		return "", err
+	}
+
+	if len(result) == 0 {
+		return "", fmt.Errorf("failed to extract response from model")
+	}
+
+	// Some deployments ignore return_full_text and echo the prompt back
+	text := strings.TrimSpace(strings.TrimPrefix(result[0].GeneratedText, prompt))
+
+	// The Inference API doesn't report token counts; they are estimated
+	reportUsage(ctx, req, text, 0, 0)
+	return text, nil
+}
+
+// renderPrompt flattens chat messages into a single text-generation prompt
+// with a labelled section per role. Role labels inside user text are already
+// escaped, so they cannot open a new section.
+func renderPrompt(messages []Message) string {
+	parts := make([]string, 0, len(messages)+1)
+	for _, m := range messages {
+		label := strings.ToUpper(m.Role[:1]) + m.Role[1:]
+		parts = append(parts, label+":\n"+m.Content)
+	}
+	parts = append(parts, "Assistant:")
+	return strings.Join(parts, "\n\n")
+}
+
+// Stream uses the text-generation-inference streaming mode, which emits one
+// server-sent event per generated token.
+func (p *HuggingFaceProvider) Stream(ctx context.Context, req GenerateRequest, onToken TokenFunc) (string, error) {
+	if p.apiKey == "" {
+		return "", fmt.Errorf("API key is not set")
+	}
+
+	payload := map[string]interface{}{
+		"inputs": renderPrompt(req.Messages),
+		"parameters": map[string]interface{}{
+			"max_new_tokens":   req.Params.MaxTokens,
+			"temperature":      req.Params.Temperature,
+			"top_p":            req.Params.TopP,
+			"do_sample":        true,
+			"return_full_text": false,
+		},
+		"options": map[string]interface{}{
+			"wait_for_model": true,
+		},
+		"stream": true,
+	}
+
+	body, err := openStream(ctx, p.client, p.baseURL+"/"+p.model, p.apiKey, payload)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	var text strings.Builder
+	err = readSSE(body, func(data string) (bool, error) {
+		var chunk struct {
+			Token struct {
+				Text    string `json:"text"`
+				Special bool   `json:"special"`
+			} `json:"token"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return false, fmt.Errorf("error decoding stream chunk: %v", err)
+		}
+		if chunk.Error != "" {
+			return false, fmt.Errorf("inference error: %s", chunk.Error)
+		}
+		if chunk.Token.Special || chunk.Token.Text == "" {
+			return false, nil
+		}
+
+		text.WriteString(chunk.Token.Text)
+		return false, onToken(chunk.Token.Text)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	result := strings.TrimSpace(text.String())
+	reportUsage(ctx, req, result, 0, 0)
+	return result, nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OllamaProvider calls a local Ollama server's chat API.
+type OllamaProvider struct {
+	client  *http.Client
+	baseURL string
+	model   string
+}
+
+func NewOllamaProvider(client *http.Client, baseURL, model string) *OllamaProvider {
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+	return &OllamaProvider{
+		client:  defaultClient(client),
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   model,
+	}
+}
+
+func (p *OllamaProvider) Name() string  { return "ollama" }
+func (p *OllamaProvider) Model() string { return p.model }
+
+func (p *OllamaProvider) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	payload := map[string]interface{}{
+		"model":    p.model,
+		"messages": req.Messages,
+		"stream":   false,
+		"options": map[string]interface{}{
+			"num_predict": req.Params.MaxTokens,
+			"temperature": req.Params.Temperature,
+			"top_p":       req.Params.TopP,
+		},
+	}
+	if req.JSONMode {
+		payload["format"] = "json"
+	}
+
+	var result struct {
+		Message         Message `json:"message"`
+		PromptEvalCount int     `json:"prompt_eval_count"`
+		EvalCount       int     `json:"eval_count"`
+	}
+	if err := postJSON(ctx, p.client, p.baseURL+"/api/chat", "", payload, &result); err != nil {
+		return "", err
+	}
+
+	text := strings.TrimSpace(result.Message.Content)
+	reportUsage(ctx, req, text, result.PromptEvalCount, result.EvalCount)
+	return text, nil
+}
+
+func (p *OllamaProvider) Stream(ctx context.Context, req GenerateRequest, onToken TokenFunc) (string, error) {
+	payload := map[string]interface{}{
+		"model":    p.model,
+		"messages": req.Messages,
+		"stream":   true,
+		"options": map[string]interface{}{
+			"num_predict": req.Params.MaxTokens,
+			"temperature": req.Params.Temperature,
+			"top_p":       req.Params.TopP,
+		},
+	}
+	if req.JSONMode {
+		payload["format"] = "json"
+	}
+
+	body, err := openStream(ctx, p.client, p.baseURL+"/api/chat", "", payload)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	// Ollama streams newline-delimited JSON objects
+	var text strings.Builder
+	var promptTokens, completionTokens int
+	decoder := json.NewDecoder(body)
+	for {
+		var chunk struct {
+			Message         Message `json:"message"`
+			Done            bool    `json:"done"`
+			Error           string  `json:"error"`
+			PromptEvalCount int     `json:"prompt_eval_count"`
+			EvalCount       int     `json:"eval_count"`
+		}
+		if err := decoder.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", fmt.Errorf("error decoding stream chunk: %v", err)
+		}
+		if chunk.Error != "" {
+			return "", fmt.Errorf("ollama error: %s", chunk.Error)
+		}
+
+		if token := chunk.Message.Content; token != "" {
+			text.WriteString(token)
+			if err := onToken(token); err != nil {
+				return "", err
+			}
+		}
+		if chunk.Done {
+			// The final chunk carries the token counts
+			promptTokens, completionTokens = chunk.PromptEvalCount, chunk.EvalCount
+			break
+		}
+	}
+
+	result := strings.TrimSpace(text.String())
+	reportUsage(ctx, req, result, promptTokens, completionTokens)
+	return result, nil
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OpenAIProvider calls an OpenAI-style chat completions endpoint. It also
+// serves any compatible server such as llama.cpp or vLLM.
+type OpenAIProvider struct {
+	client  *http.Client
+	baseURL string
+	model   string
+	apiKey  string
+}
+
+func NewOpenAIProvider(client *http.Client, baseURL, model, apiKey string) *OpenAIProvider {
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	return &OpenAIProvider{
+		client:  defaultClient(client),
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   model,
+		apiKey:  apiKey,
+	}
+}
+
+func (p *OpenAIProvider) Name() string  { return "openai" }
+func (p *OpenAIProvider) Model() string { return p.model }
+
+func (p *OpenAIProvider) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	payload := map[string]interface{}{
+		"model":       p.model,
+		"messages":    req.Messages,
+		"max_tokens":  req.Params.MaxTokens,
+		"temperature": req.Params.Temperature,
+		"top_p":       req.Params.TopP,
+	}
+	if req.JSONMode {
+		payload["response_format"] = map[string]string{"type": "json_object"}
+	}
+
+	var result struct {
+		Choices []struct {
+			Message Message `json:"message"`
+		} `json:"choices"`
+		Usage openAIUsage `json:"usage"`
+	}
+	if err := postJSON(ctx, p.client, p.baseURL+"/chat/completions", p.apiKey, payload, &result); err != nil {
+		return "", err
+	}
+
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("failed to extract response from model")
+	}
+	text := strings.TrimSpace(result.Choices[0].Message.Content)
+	reportUsage(ctx, req, text, result.Usage.PromptTokens, result.Usage.CompletionTokens)
+	return text, nil
+}
+
+func (p *OpenAIProvider) Stream(ctx context.Context, req GenerateRequest, onToken TokenFunc) (string, error) {
+	payload := map[string]interface{}{
+		"model":       p.model,
+		"messages":    req.Messages,
+		"max_tokens":  req.Params.MaxTokens,
+		"temperature": req.Params.Temperature,
+		"top_p":       req.Params.TopP,
+		"stream":      true,
+		// Ask for token counts in the final chunk
+		"stream_options": map[string]bool{"include_usage": true},
+	}
+	if req.JSONMode {
+		payload["response_format"] = map[string]string{"type": "json_object"}
+	}
+
+	body, err := openStream(ctx, p.client, p.baseURL+"/chat/completions", p.apiKey, payload)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	var text strings.Builder
+	var usage openAIUsage
+	err = readSSE(body, func(data string) (bool, error) {
+		if data == "[DONE]" {
+			return true, nil
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta Message `json:"delta"`
+			} `json:"choices"`
+			Usage *openAIUsage `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return false, fmt.Errorf("error decoding stream chunk: %v", err)
+		}
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			return false, nil
+		}
+
+		token := chunk.Choices[0].Delta.Content
+		text.WriteString(token)
+		return false, onToken(token)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	result := strings.TrimSpace(text.String())
+	reportUsage(ctx, req, result, usage.PromptTokens, usage.CompletionTokens)
+	return result, nil
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
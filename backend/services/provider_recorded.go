@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// ErrNotRecorded is returned by ReplayProvider for a request it has no
+// recorded reply to.
+var ErrNotRecorded = errors.New("no recorded reply for request")
+
+// Recording is a set of model replies keyed by the request that produced
+// them, so an evaluation run against a live model can be replayed offline.
+type Recording struct {
+	Provider string            `json:"provider"`
+	Model    string            `json:"model"`
+	Replies  map[string]string `json:"replies"`
+
+	mu sync.Mutex
+}
+
+// LoadRecording reads a recording written by Save.
+func LoadRecording(path string) (*Recording, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rec Recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("error parsing recording %s: %v", path, err)
+	}
+	if rec.Replies == nil {
+		rec.Replies = make(map[string]string)
+	}
+	return &rec, nil
+}
+
+// Save writes the recording to path as JSON.
+func (r *Recording) Save(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func (r *Recording) get(key string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reply, ok := r.Replies[key]
+	return reply, ok
+}
+
+func (r *Recording) set(key, reply string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Replies == nil {
+		r.Replies = make(map[string]string)
+	}
+	r.Replies[key] = reply
+}
+
+// RecordingKey identifies a request by its messages and generation
+// settings. Any change to the prompt gives a new key, so stale replies are
+// never replayed against it.
+func RecordingKey(req GenerateRequest) string {
+	h := sha256.New()
+	for _, m := range req.Messages {
+		h.Write([]byte(m.Role))
+		h.Write([]byte{0})
+		h.Write([]byte(m.Content))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte(strconv.FormatBool(req.JSONMode)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// RecordingProvider passes requests to another provider and records each
+// successful reply.
+type RecordingProvider struct {
+	Provider
+	rec *Recording
+}
+
+func NewRecordingProvider(p Provider, rec *Recording) *RecordingProvider {
+	rec.Provider, rec.Model = p.Name(), p.Model()
+	return &RecordingProvider{Provider: p, rec: rec}
+}
+
+func (p *RecordingProvider) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	reply, err := p.Provider.Generate(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	p.rec.set(RecordingKey(req), reply)
+	return reply, nil
+}
+
+// ReplayProvider answers requests from a recording without touching the
+// network.
+type ReplayProvider struct {
+	rec *Recording
+}
+
+func NewReplayProvider(rec *Recording) *ReplayProvider {
+	return &ReplayProvider{rec: rec}
+}
+
+func (p *ReplayProvider) Name() string  { return "replay" }
+func (p *ReplayProvider) Model() string { return p.rec.Model }
+
+func (p *ReplayProvider) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	reply, ok := p.rec.get(RecordingKey(req))
+	if !ok {
+		return "", ErrNotRecorded
+	}
+	reportUsage(ctx, req, reply, 0, 0)
+	return reply, nil
+}
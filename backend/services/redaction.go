@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"go_health_sentiment/config"
+	"go_health_sentiment/models"
+	"go_health_sentiment/redact"
+)
+
+// NewRedactor builds the redactor for the configured mode and reports
+// whether users who haven't chosen get redaction. It returns a nil redactor
+// when redaction is off.
+func NewRedactor(cfg config.RedactionConfig) (*redact.Redactor, bool, error) {
+	var byDefault bool
+	switch strings.ToLower(cfg.Mode) {
+	case "on", "":
+		byDefault = true
+	case "opt-in":
+	case "off":
+		return nil, false, nil
+	default:
+		return nil, false, fmt.Errorf("unknown PII redaction mode: %s", cfg.Mode)
+	}
+
+	detectors := append([]redact.Detector{}, redact.Patterns()...)
+	detectors = append(detectors, redact.Names(), redact.Places())
+	for kind, file := range map[string]string{redact.KindName: cfg.NamesFile, redact.KindLocation: cfg.PlacesFile} {
+		if file == "" {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, false, fmt.Errorf("error reading %s dictionary: %v", kind, err)
+		}
+		detectors = append(detectors, redact.NewDictionaryDetector(kind, redact.ParseTerms(string(data))))
+	}
+	return redact.New(detectors...), byDefault, nil
+}
+
+type redactionKey struct{}
+
+// redaction is the redaction state of one analysis or chat reply. Every
+// model call made with its context shares the session, so placeholders stay
+// the same across retries, repair prompts and summaries.
+type redaction struct {
+	userID  int
+	session *redact.Session
+}
+
+func withRedaction(ctx context.Context, userID int, session *redact.Session) context.Context {
+	return context.WithValue(ctx, redactionKey{}, &redaction{userID: userID, session: session})
+}
+
+// withUserRedaction adds a redaction session to ctx when redactor is set
+// and the user has redaction on, or hasn't chosen and byDefault is set.
+func withUserRedaction(ctx context.Context, db *sql.DB, redactor *redact.Redactor, byDefault bool, userID int) (context.Context, error) {
+	if redactor == nil {
+		return ctx, nil
+	}
+
+	enabled, err := models.GetUserRedaction(ctx, db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading redaction preference: %v", err)
+	}
+	if enabled == nil {
+		enabled = &byDefault
+	}
+	if !*enabled {
+		return ctx, nil
+	}
+	return withRedaction(ctx, userID, redactor.NewSession()), nil
+}
+
+// redactTexts redacts texts with the session in ctx, if any, for calls
+// whose output doesn't need restoring, such as embeddings.
+func redactTexts(ctx context.Context, texts []string) []string {
+	rd, ok := ctx.Value(redactionKey{}).(*redaction)
+	if !ok {
+		return texts
+	}
+
+	counts := redact.Counts{}
+	redacted := make([]string, len(texts))
+	for i, text := range texts {
+		var c redact.Counts
+		redacted[i], c = rd.session.Redact(text)
+		counts.Add(c)
+	}
+	if len(counts) > 0 {
+		log.Printf("Redacted PII from embedding request for user %d: %s", rd.userID, counts)
+	}
+	return redacted
+}
+
+// redactingProvider replaces PII in requests made with a redaction context
+// and restores it in the reply. Requests without one pass through.
+type redactingProvider struct {
+	Provider
+}
+
+func (p *redactingProvider) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	rd, ok := ctx.Value(redactionKey{}).(*redaction)
+	if !ok {
+		return p.Provider.Generate(ctx, req)
+	}
+
+	text, err := p.Provider.Generate(ctx, rd.redactRequest(req))
+	if err != nil {
+		return "", err
+	}
+	return rd.session.Rehydrate(text), nil
+}
+
+// Stream restores placeholders in streamed text as it arrives, holding back
+// a token that ends partway through one until the rest of it is received.
+func (p *redactingProvider) Stream(ctx context.Context, req GenerateRequest, onToken TokenFunc) (string, error) {
+	rd, ok := ctx.Value(redactionKey{}).(*redaction)
+	if !ok {
+		return GenerateStream(ctx, p.Provider, req, onToken)
+	}
+
+	w := &rehydratingWriter{session: rd.session, onToken: onToken}
+	text, err := GenerateStream(ctx, p.Provider, rd.redactRequest(req), w.Write)
+	if err != nil {
+		return "", err
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return rd.session.Rehydrate(text), nil
+}
+
+// redactRequest returns a copy of req with PII in every message replaced,
+// and logs how much was redacted but not what.
+func (rd *redaction) redactRequest(req GenerateRequest) GenerateRequest {
+	counts := redact.Counts{}
+	messages := make([]Message, len(req.Messages))
+	for i, m := range req.Messages {
+		content, c := rd.session.Redact(m.Content)
+		counts.Add(c)
+		messages[i] = Message{Role: m.Role, Content: content}
+	}
+	req.Messages = messages
+
+	if len(counts) > 0 {
+		log.Printf("Redacted PII from model request for user %d: %s", rd.userID, counts)
+	}
+	return req
+}
+
+type rehydratingWriter struct {
+	session *redact.Session
+	onToken TokenFunc
+	pending string
+}
+
+func (w *rehydratingWriter) Write(token string) error {
+	text := w.pending + token
+	w.pending = ""
+	if i := strings.LastIndexByte(text, '['); i >= 0 && !strings.Contains(text[i:], "]") && len(text)-i < redact.MaxPlaceholderLen {
+		text, w.pending = text[:i], text[i:]
+	}
+	if text == "" {
+		return nil
+	}
+	return w.onToken(w.session.Rehydrate(text))
+}
+
+func (w *rehydratingWriter) Flush() error {
+	if w.pending == "" {
+		return nil
+	}
+	text := w.pending
+	w.pending = ""
+	return w.onToken(w.session.Rehydrate(text))
+}
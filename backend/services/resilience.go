@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting the model while the circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("LLM provider unavailable: circuit breaker is open")
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// CircuitBreaker stops calls to an upstream that keeps failing. After
+// threshold consecutive failures it opens and rejects calls for cooldown,
+// then lets a single trial call through; its outcome closes or reopens it.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	trial     bool
+	lastError string
+}
+
+// NewCircuitBreaker returns a closed breaker. A threshold of zero or less
+// disables it.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+	}
+}
+
+// Allow returns ErrCircuitOpen if a call may not proceed now. Every allowed
+// call must be followed by Success, Failure or Cancel.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.trial = false
+		fallthrough
+	case BreakerHalfOpen:
+		if b.trial {
+			return ErrCircuitOpen
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// Success records a call that reached the upstream and closes the breaker.
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != BreakerClosed {
+		log.Println("LLM circuit breaker closed")
+	}
+	b.state = BreakerClosed
+	b.failures = 0
+	b.trial = false
+}
+
+// Failure records a call that failed because the upstream was unavailable.
+func (b *CircuitBreaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.lastError = err.Error()
+	b.trial = false
+
+	if b.threshold <= 0 {
+		return
+	}
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		if b.state != BreakerOpen {
+			log.Printf("LLM circuit breaker opened after %d consecutive failures: %v", b.failures, err)
+		}
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Cancel records a call abandoned by the caller, which says nothing about
+// the upstream.
+func (b *CircuitBreaker) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// BreakerStatus is a snapshot of the breaker for health reporting.
+type BreakerStatus struct {
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenUntil           *time.Time   `json:"open_until,omitempty"`
+	LastError           string       `json:"last_error,omitempty"`
+}
+
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+	}
+	if b.state == BreakerOpen {
+		until := b.openedAt.Add(b.cooldown)
+		if time.Now().Before(until) {
+			status.OpenUntil = &until
+		} else {
+			status.State = BreakerHalfOpen
+		}
+	}
+	return status
+}
+
+// RetryOptions control how failed upstream calls are retried.
+type RetryOptions struct {
+	// MaxAttempts per call, including the first
+	MaxAttempts int
+	Base        time.Duration
+	Max         time.Duration
+}
+
+// ResilientProvider wraps a provider with retries of transient failures and
+// a circuit breaker. Rate limits (429), server errors and network failures
+// are retried with jittered exponential backoff, or after the delay the API
+// asked for when it gave one.
+type ResilientProvider struct {
+	Provider
+	retry   RetryOptions
+	breaker *CircuitBreaker
+}
+
+func NewResilientProvider(provider Provider, retry RetryOptions, breaker *CircuitBreaker) *ResilientProvider {
+	if retry.MaxAttempts < 1 {
+		retry.MaxAttempts = 1
+	}
+	if retry.Base <= 0 {
+		retry.Base = 500 * time.Millisecond
+	}
+	if retry.Max < retry.Base {
+		retry.Max = retry.Base
+	}
+	return &ResilientProvider{
+		Provider: provider,
+		retry:    retry,
+		breaker:  breaker,
+	}
+}
+
+// Breaker returns the provider's circuit breaker.
+func (r *ResilientProvider) Breaker() *CircuitBreaker {
+	return r.breaker
+}
+
+func (r *ResilientProvider) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	var text string
+	err := r.call(ctx, func() bool { return true }, func() error {
+		var err error
+		text, err = r.Provider.Generate(ctx, req)
+		return err
+	})
+	return text, err
+}
+
+// Stream retries only until the first token has been delivered; after that
+// a retry would repeat output the caller has already seen.
+func (r *ResilientProvider) Stream(ctx context.Context, req GenerateRequest, onToken TokenFunc) (string, error) {
+	emitted := false
+	forward := func(token string) error {
+		emitted = true
+		return onToken(token)
+	}
+
+	var text string
+	err := r.call(ctx, func() bool { return !emitted }, func() error {
+		var err error
+		text, err = GenerateStream(ctx, r.Provider, req, forward)
+		return err
+	})
+	return text, err
+}
+
+func (r *ResilientProvider) call(ctx context.Context, canRetry func() bool, fn func() error) error {
+	if err := r.breaker.Allow(); err != nil {
+		return err
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= r.retry.MaxAttempts || !retryable(err) || !canRetry() {
+			break
+		}
+
+		wait := r.retryDelay(err, attempt)
+		if wait > r.retry.Max {
+			// The API wants more time than we are willing to hold the
+			// caller for; the job queue will try again later
+			break
+		}
+		log.Printf("LLM request failed (attempt %d/%d), retrying in %v: %v",
+			attempt, r.retry.MaxAttempts, wait.Round(time.Millisecond), err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		r.breaker.Cancel()
+	case err != nil && retryable(err):
+		r.breaker.Failure(err)
+	default:
+		// Success, or an error the upstream answered with (bad request,
+		// malformed output) which says it is up
+		r.breaker.Success()
+	}
+	return err
+}
+
+// retryDelay honours a Retry-After or estimated_time hint and otherwise
+// backs off exponentially.
+func (r *ResilientProvider) retryDelay(err error, attempt int) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter
+	}
+	return jitteredBackoff(r.retry.Base, r.retry.Max, attempt)
+}
+
+// retryable reports whether err means the upstream is temporarily
+// unavailable rather than rejecting the request.
+func retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	var reqErr *RequestError
+	return errors.As(err, &reqErr)
+}
+
+// jitteredBackoff returns base * 2^(attempt-1), capped at max, with up to
+// 50% random jitter so retries from many callers don't line up.
+func jitteredBackoff(base, max time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
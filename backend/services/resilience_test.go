@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+	failure := errors.New("upstream down")
+
+	tests := []struct {
+		name      string
+		threshold int
+		run       func(b *CircuitBreaker)
+		allowed   bool
+		state     BreakerState
+	}{
+		{
+			name:      "closed below threshold",
+			threshold: 2,
+			run:       func(b *CircuitBreaker) { b.Failure(failure) },
+			allowed:   true,
+			state:     BreakerClosed,
+		},
+		{
+			name:      "opens at threshold",
+			threshold: 2,
+			run: func(b *CircuitBreaker) {
+				b.Failure(failure)
+				b.Failure(failure)
+			},
+			allowed: false,
+			state:   BreakerOpen,
+		},
+		{
+			name:      "success resets the count",
+			threshold: 2,
+			run: func(b *CircuitBreaker) {
+				b.Failure(failure)
+				b.Success()
+				b.Failure(failure)
+			},
+			allowed: true,
+			state:   BreakerClosed,
+		},
+		{
+			name:      "one trial call after cooldown",
+			threshold: 1,
+			run: func(b *CircuitBreaker) {
+				b.Failure(failure)
+				time.Sleep(cooldown)
+				if err := b.Allow(); err != nil {
+					t.Fatalf("trial call rejected: %v", err)
+				}
+			},
+			allowed: false,
+			state:   BreakerHalfOpen,
+		},
+		{
+			name:      "successful trial closes",
+			threshold: 1,
+			run: func(b *CircuitBreaker) {
+				b.Failure(failure)
+				time.Sleep(cooldown)
+				b.Allow()
+				b.Success()
+			},
+			allowed: true,
+			state:   BreakerClosed,
+		},
+		{
+			name:      "failed trial reopens",
+			threshold: 1,
+			run: func(b *CircuitBreaker) {
+				b.Failure(failure)
+				time.Sleep(cooldown)
+				b.Allow()
+				b.Failure(failure)
+			},
+			allowed: false,
+			state:   BreakerOpen,
+		},
+		{
+			name:      "cancelled trial lets another through",
+			threshold: 1,
+			run: func(b *CircuitBreaker) {
+				b.Failure(failure)
+				time.Sleep(cooldown)
+				b.Allow()
+				b.Cancel()
+			},
+			allowed: true,
+			state:   BreakerHalfOpen,
+		},
+		{
+			name:      "zero threshold never opens",
+			threshold: 0,
+			run: func(b *CircuitBreaker) {
+				for i := 0; i < 10; i++ {
+					b.Failure(failure)
+				}
+			},
+			allowed: true,
+			state:   BreakerClosed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewCircuitBreaker(tt.threshold, cooldown)
+			tt.run(b)
+
+			if state := b.Status().State; state != tt.state {
+				t.Errorf("state = %s, want %s", state, tt.state)
+			}
+			err := b.Allow()
+			if allowed := err == nil; allowed != tt.allowed {
+				t.Errorf("Allow() = %v, want allowed %v", err, tt.allowed)
+			}
+			if err != nil && !errors.Is(err, ErrCircuitOpen) {
+				t.Errorf("Allow() = %v, want ErrCircuitOpen", err)
+			}
+		})
+	}
+}
+
+func TestAPIErrorRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		body   string
+		min    time.Duration
+		max    time.Duration
+	}{
+		{name: "seconds", header: "3", min: 3 * time.Second, max: 3 * time.Second},
+		{name: "http date", header: time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), min: 8 * time.Second, max: 10 * time.Second},
+		{name: "date in the past", header: time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), max: 0},
+		{name: "invalid header", header: "soon", max: 0},
+		{name: "none", max: 0},
+		{name: "estimated time", body: `{"error": "Model is loading", "estimated_time": 1.5}`, min: 1500 * time.Millisecond, max: 1500 * time.Millisecond},
+		{name: "header wins over estimated time", header: "2", body: `{"estimated_time": 20}`, min: 2 * time.Second, max: 2 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}
+			if tt.header != "" {
+				resp.Header.Set("Retry-After", tt.header)
+			}
+			apiErr := newAPIError(resp, []byte(tt.body))
+
+			got := apiErr.RetryAfter
+			if tt.max == 0 {
+				if got > 0 {
+					t.Errorf("RetryAfter = %v, want none", got)
+				}
+				return
+			}
+			if got < tt.min || got > tt.max {
+				t.Errorf("RetryAfter = %v, want between %v and %v", got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+// scriptedProvider fails with the given errors in turn and then succeeds.
+type scriptedProvider struct {
+	errs  []error
+	calls int
+}
+
+func (p *scriptedProvider) Name() string  { return "scripted" }
+func (p *scriptedProvider) Model() string { return "scripted" }
+
+func (p *scriptedProvider) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	p.calls++
+	if p.calls <= len(p.errs) {
+		return "", p.errs[p.calls-1]
+	}
+	return "ok", nil
+}
+
+func TestResilientProviderRetries(t *testing.T) {
+	unavailable := &APIError{StatusCode: http.StatusServiceUnavailable}
+	badRequest := &APIError{StatusCode: http.StatusBadRequest}
+	networkDown := &RequestError{Err: errors.New("connection refused")}
+	waitLong := &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}
+
+	tests := []struct {
+		name    string
+		errs    []error
+		calls   int
+		wantErr error
+		state   BreakerState
+	}{
+		{name: "succeeds first time", calls: 1, state: BreakerClosed},
+		{name: "retries server errors", errs: []error{unavailable, networkDown}, calls: 3, state: BreakerClosed},
+		{name: "gives up after max attempts", errs: []error{unavailable, unavailable, unavailable}, calls: 3, wantErr: unavailable, state: BreakerOpen},
+		{name: "doesn't retry bad requests", errs: []error{badRequest}, calls: 1, wantErr: badRequest, state: BreakerClosed},
+		{name: "doesn't wait longer than max", errs: []error{waitLong}, calls: 1, wantErr: waitLong, state: BreakerOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &scriptedProvider{errs: tt.errs}
+			provider := NewResilientProvider(upstream,
+				RetryOptions{MaxAttempts: 3, Base: time.Millisecond, Max: 5 * time.Millisecond},
+				NewCircuitBreaker(1, time.Minute))
+
+			_, err := provider.Generate(context.Background(), GenerateRequest{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Generate() error = %v, want %v", err, tt.wantErr)
+			}
+			if upstream.calls != tt.calls {
+				t.Errorf("upstream called %d times, want %d", upstream.calls, tt.calls)
+			}
+			if state := provider.Breaker().Status().State; state != tt.state {
+				t.Errorf("breaker %s, want %s", state, tt.state)
+			}
+		})
+	}
+}
+
+func TestJitteredBackoff(t *testing.T) {
+	base, max := 100*time.Millisecond, time.Second
+	for attempt, ceiling := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 10: time.Second} {
+		for i := 0; i < 20; i++ {
+			if d := jitteredBackoff(base, max, attempt); d < ceiling/2 || d > ceiling {
+				t.Fatalf("attempt %d: backoff %v outside [%v, %v]", attempt, d, ceiling/2, ceiling)
+			}
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"go_health_sentiment/auth"
+	"go_health_sentiment/models"
+)
+
+// revocationSyncOverlap re-reads revocations this far behind the last one
+// loaded, so one committed late by another instance isn't skipped.
+const revocationSyncOverlap = time.Minute
+
+// RevocationOptions configures TokenRevocations.
+type RevocationOptions struct {
+	// SyncInterval is how often revocations made by other instances are
+	// loaded; until then they only take effect on the instance that made
+	// them
+	SyncInterval time.Duration
+
+	// CleanupInterval is how often revocations of expired tokens and
+	// expired refresh tokens are deleted
+	CleanupInterval time.Duration
+
+	// CacheSize is how many token IDs that hit the Bloom filter are kept
+	// with their answer from Postgres
+	CacheSize int
+}
+
+// TokenRevocations tracks revoked access tokens. Revocations are stored in
+// Postgres and mirrored in memory: a Bloom filter of revoked token IDs
+// answers most checks without a query, an LRU cache remembers the
+// filter's false positives, and the cutoffs of users who logged out
+// everywhere are kept for as long as an access token lives.
+type TokenRevocations struct {
+	db   *sql.DB
+	opts RevocationOptions
+
+	mu              sync.Mutex
+	bloom           *bloomFilter
+	checked         *revocationCache
+	cutoffs         map[int]time.Time
+	tokensSyncedTo  time.Time
+	cutoffsSyncedTo time.Time
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewTokenRevocations(db *sql.DB, opts RevocationOptions) *TokenRevocations {
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = 30 * time.Second
+	}
+	if opts.CleanupInterval <= 0 {
+		opts.CleanupInterval = time.Hour
+	}
+	if opts.CacheSize <= 0 {
+		opts.CacheSize = 10000
+	}
+	return &TokenRevocations{
+		db:      db,
+		opts:    opts,
+		bloom:   newBloomFilter(opts.CacheSize, 0.01),
+		checked: newRevocationCache(opts.CacheSize),
+		cutoffs: make(map[int]time.Time),
+	}
+}
+
+// Load replaces the in-memory state with the revocations in Postgres.
+func (t *TokenRevocations) Load(ctx context.Context) error {
+	tokens, err := models.GetRevokedTokensSince(ctx, t.db, time.Time{})
+	if err != nil {
+		return err
+	}
+	cutoffs, err := models.GetTokenCutoffsSince(ctx, t.db, time.Now().Add(-auth.AccessTokenTTL))
+	if err != nil {
+		return err
+	}
+
+	bloom := newBloomFilter(max(t.opts.CacheSize, 2*len(tokens)), 0.01)
+	var syncedTo time.Time
+	for _, token := range tokens {
+		bloom.Add(token.JTI)
+		if token.RevokedAt.After(syncedTo) {
+			syncedTo = token.RevokedAt
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bloom = bloom
+	t.checked = newRevocationCache(t.opts.CacheSize)
+	t.tokensSyncedTo = syncedTo
+	t.cutoffs = make(map[int]time.Time, len(cutoffs))
+	t.mergeCutoffs(cutoffs)
+	return nil
+}
+
+// Start loads revocations made elsewhere every SyncInterval and deletes
+// expired ones every CleanupInterval.
+func (t *TokenRevocations) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+
+		syncTicker := time.NewTicker(t.opts.SyncInterval)
+		defer syncTicker.Stop()
+		cleanupTicker := time.NewTicker(t.opts.CleanupInterval)
+		defer cleanupTicker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-syncTicker.C:
+				if err := t.sync(ctx); err != nil && ctx.Err() == nil {
+					log.Printf("Error loading token revocations: %v", err)
+				}
+			case <-cleanupTicker.C:
+				t.cleanup(ctx)
+			}
+		}
+	}()
+}
+
+// Shutdown stops the background sync and waits for it to exit or for ctx
+// to expire.
+func (t *TokenRevocations) Shutdown(ctx context.Context) error {
+	if t.cancel == nil {
+		return nil
+	}
+	t.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// IsRevoked reports whether the access token with claims was revoked,
+// either by itself or by its user logging out everywhere.
+func (t *TokenRevocations) IsRevoked(ctx context.Context, claims *auth.Claims) (bool, error) {
+	t.mu.Lock()
+	if cutoff, ok := t.cutoffs[claims.UserID]; ok {
+		if claims.IssuedAt == nil || claims.IssuedAt.Before(cutoff) {
+			t.mu.Unlock()
+			return true, nil
+		}
+	}
+	if !t.bloom.MayContain(claims.ID) {
+		t.mu.Unlock()
+		return false, nil
+	}
+	if revoked, ok := t.checked.get(claims.ID); ok {
+		t.mu.Unlock()
+		return revoked, nil
+	}
+	t.mu.Unlock()
+
+	revoked, err := models.IsTokenRevoked(ctx, t.db, claims.ID)
+	if err != nil {
+		return false, err
+	}
+
+	t.mu.Lock()
+	t.checked.set(claims.ID, revoked)
+	t.mu.Unlock()
+	return revoked, nil
+}
+
+// Revoke revokes one access token until it expires, as on logout.
+func (t *TokenRevocations) Revoke(ctx context.Context, claims *auth.Claims) error {
+	expiresAt := time.Now().Add(auth.AccessTokenTTL)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	if err := models.RevokeToken(ctx, t.db, claims.ID, claims.UserID, expiresAt); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bloom.Add(claims.ID)
+	t.checked.set(claims.ID, true)
+	return nil
+}
+
+// RevokeAll revokes every access and refresh token issued to the user so
+// far, logging them out everywhere. The cutoff is rounded down to the
+// precision of token times, so a token issued right afterwards, even in the
+// same second, stays valid.
+func (t *TokenRevocations) RevokeAll(ctx context.Context, userID int) error {
+	cutoff := time.Now().UTC().Truncate(auth.TokenTimePrecision)
+	if err := models.RevokeUserTokens(ctx, t.db, userID, cutoff); err != nil {
+		return err
+	}
+	if err := models.RevokeUserRefreshTokens(ctx, t.db, userID); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mergeCutoffs(map[int]time.Time{userID: cutoff})
+	return nil
+}
+
+// sync loads the revocations made since the last sync.
+func (t *TokenRevocations) sync(ctx context.Context) error {
+	t.mu.Lock()
+	tokensSince := t.tokensSyncedTo.Add(-revocationSyncOverlap)
+	cutoffsSince := t.cutoffsSyncedTo.Add(-revocationSyncOverlap)
+	t.mu.Unlock()
+
+	tokens, err := models.GetRevokedTokensSince(ctx, t.db, tokensSince)
+	if err != nil {
+		return err
+	}
+	cutoffs, err := models.GetTokenCutoffsSince(ctx, t.db, cutoffsSince)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, token := range tokens {
+		t.bloom.Add(token.JTI)
+		t.checked.set(token.JTI, true)
+		if token.RevokedAt.After(t.tokensSyncedTo) {
+			t.tokensSyncedTo = token.RevokedAt
+		}
+	}
+	t.mergeCutoffs(cutoffs)
+
+	// Tokens issued before an old cutoff have expired by now
+	oldest := time.Now().Add(-auth.AccessTokenTTL)
+	for userID, cutoff := range t.cutoffs {
+		if cutoff.Before(oldest) {
+			delete(t.cutoffs, userID)
+		}
+	}
+	return nil
+}
+
+// mergeCutoffs keeps the latest cutoff of each user. t.mu must be held.
+func (t *TokenRevocations) mergeCutoffs(cutoffs map[int]time.Time) {
+	for userID, cutoff := range cutoffs {
+		if cutoff.After(t.cutoffs[userID]) {
+			t.cutoffs[userID] = cutoff
+		}
+		if cutoff.After(t.cutoffsSyncedTo) {
+			t.cutoffsSyncedTo = cutoff
+		}
+	}
+}
+
+// cleanup deletes revocations of expired tokens and expired refresh
+// tokens, then rebuilds the Bloom filter without them.
+func (t *TokenRevocations) cleanup(ctx context.Context) {
+	if n, err := models.DeleteExpiredRevocations(ctx, t.db); err != nil {
+		log.Printf("Error deleting expired token revocations: %v", err)
+	} else if n > 0 {
+		log.Printf("Deleted %d expired token revocations", n)
+	}
+	if n, err := models.DeleteExpiredRefreshTokens(ctx, t.db); err != nil {
+		log.Printf("Error deleting expired refresh tokens: %v", err)
+	} else if n > 0 {
+		log.Printf("Deleted %d expired refresh tokens", n)
+	}
+
+	if err := t.Load(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("Error reloading token revocations: %v", err)
+	}
+}
+
+// revocationCache is an LRU cache of whether token IDs are revoked.
+type revocationCache struct {
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+type revocationCacheItem struct {
+	jti     string
+	revoked bool
+}
+
+func newRevocationCache(size int) *revocationCache {
+	return &revocationCache{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *revocationCache) get(jti string) (bool, bool) {
+	el, ok := c.items[jti]
+	if !ok {
+		return false, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*revocationCacheItem).revoked, true
+}
+
+func (c *revocationCache) set(jti string, revoked bool) {
+	if el, ok := c.items[jti]; ok {
+		el.Value.(*revocationCacheItem).revoked = revoked
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[jti] = c.order.PushFront(&revocationCacheItem{jti: jti, revoked: revoked})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*revocationCacheItem).jti)
+	}
+}
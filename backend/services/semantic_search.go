@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+
+	"go_health_sentiment/models"
+	"go_health_sentiment/redact"
+)
+
+// SemanticIndexOptions tune semantic search. Redactor and RedactByDefault
+// work as in ChatOptions and only matter for remote embedders.
+type SemanticIndexOptions struct {
+	Redactor        *redact.Redactor
+	RedactByDefault bool
+
+	// BackfillBatch is how many of the user's unindexed entries a search
+	// embeds first, covering entries written before search existed or
+	// before the embedder changed
+	BackfillBatch int
+}
+
+// SemanticIndex stores a vector per journal entry and finds a user's
+// entries nearest to a query. Vectors are compared in Go, which is fast
+// enough for a single user's journal without a vector extension.
+type SemanticIndex struct {
+	db       *sql.DB
+	embedder Embedder
+	opts     SemanticIndexOptions
+}
+
+func NewSemanticIndex(db *sql.DB, embedder Embedder, opts SemanticIndexOptions) *SemanticIndex {
+	if opts.BackfillBatch <= 0 {
+		opts.BackfillBatch = 50
+	}
+	return &SemanticIndex{db: db, embedder: embedder, opts: opts}
+}
+
+// Embedder returns the embedder vectors are computed with.
+func (s *SemanticIndex) Embedder() Embedder {
+	return s.embedder
+}
+
+// IndexEntry computes and stores the entry's vector unless it already has
+// one from the current embedder.
+func (s *SemanticIndex) IndexEntry(ctx context.Context, entry *models.JournalEntry) error {
+	exists, err := models.HasEntryEmbedding(ctx, s.db, entry.ID, s.embedder.Name())
+	if err != nil || exists {
+		return err
+	}
+	return s.index(ctx, entry.UserID, []models.JournalEntry{*entry})
+}
+
+func (s *SemanticIndex) index(ctx context.Context, userID int, entries []models.JournalEntry) error {
+	ctx, err := withUserRedaction(ctx, s.db, s.opts.Redactor, s.opts.RedactByDefault, userID)
+	if err != nil {
+		return err
+	}
+
+	texts := make([]string, len(entries))
+	for i, e := range entries {
+		texts[i] = e.Content
+	}
+	vectors, err := s.embedder.Embed(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("error embedding entries: %v", err)
+	}
+
+	for i, e := range entries {
+		if err := models.SaveEntryEmbedding(ctx, s.db, e.ID, userID, s.embedder.Name(), vectors[i]); err != nil {
+			return fmt.Errorf("error saving embedding for entry %d: %v", e.ID, err)
+		}
+	}
+	return nil
+}
+
+// SemanticMatch is an entry found by a semantic search. Score is the cosine
+// similarity to the query, 1 being identical.
+type SemanticMatch struct {
+	Entry models.JournalEntry
+	Score float64
+}
+
+// Search returns up to limit of the user's entries most similar to query,
+// best first. Only the user's own vectors are compared.
+func (s *SemanticIndex) Search(ctx context.Context, userID int, query string, limit int) ([]SemanticMatch, error) {
+	s.backfill(ctx, userID)
+	return s.nearest(ctx, userID, query, limit, nil)
+}
+
+// Related returns up to limit of the user's entries written before entry
+// that are most similar to it, best first.
+func (s *SemanticIndex) Related(ctx context.Context, entry *models.JournalEntry, limit int) ([]SemanticMatch, error) {
+	return s.nearest(ctx, entry.UserID, entry.Content, limit, func(e *models.JournalEntry) bool {
+		return e.ID != entry.ID && e.CreatedAt.Before(entry.CreatedAt)
+	})
+}
+
+// nearest ranks the user's indexed entries by similarity to text and
+// returns the best limit that keep accepts, or all of them if keep is nil.
+func (s *SemanticIndex) nearest(ctx context.Context, userID int, text string, limit int, keep func(*models.JournalEntry) bool) ([]SemanticMatch, error) {
+	ctx, err := withUserRedaction(ctx, s.db, s.opts.Redactor, s.opts.RedactByDefault, userID)
+	if err != nil {
+		return nil, err
+	}
+	vectors, err := s.embedder.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, fmt.Errorf("error embedding query: %v", err)
+	}
+
+	embeddings, err := models.GetUserEmbeddings(ctx, s.db, userID, s.embedder.Name())
+	if err != nil {
+		return nil, fmt.Errorf("error loading embeddings: %v", err)
+	}
+
+	scores := make(map[int]float64)
+	ids := make([]int, 0, len(embeddings))
+	for _, e := range embeddings {
+		if score := cosineSimilarity(vectors[0], e.Vector); score > 0 {
+			scores[e.JournalID] = score
+			ids = append(ids, e.JournalID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return scores[ids[i]] > scores[ids[j]] })
+
+	// Load a few spare candidates so filtered-out entries don't leave the
+	// result short
+	candidates := limit
+	if keep != nil {
+		candidates = 4*limit + 1
+	}
+	if len(ids) > candidates {
+		ids = ids[:candidates]
+	}
+	if len(ids) == 0 {
+		return []SemanticMatch{}, nil
+	}
+
+	entries, err := models.GetEntriesByIDs(ctx, s.db, userID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("error loading entries: %v", err)
+	}
+	matches := make([]SemanticMatch, 0, len(entries))
+	for i := range entries {
+		if keep == nil || keep(&entries[i]) {
+			matches = append(matches, SemanticMatch{Entry: entries[i], Score: scores[entries[i].ID]})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// backfill indexes a batch of the user's entries that have no vector yet.
+// Failures are logged; the search goes ahead with the entries already
+// indexed.
+func (s *SemanticIndex) backfill(ctx context.Context, userID int) {
+	entries, err := models.GetEntriesWithoutEmbedding(ctx, s.db, userID, s.embedder.Name(), s.opts.BackfillBatch)
+	if err != nil {
+		log.Printf("Error finding unindexed entries for user %d: %v", userID, err)
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+	if err := s.index(ctx, userID, entries); err != nil {
+		log.Printf("Error indexing entries for user %d: %v", userID, err)
+	}
+}
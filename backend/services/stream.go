@@ -0,0 +1,98 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// TokenFunc receives each chunk of generated text as it arrives. Returning
+// an error aborts the stream.
+type TokenFunc func(token string) error
+
+// StreamingProvider is implemented by providers that can emit output
+// incrementally. Stream returns the assembled text once generation ends.
+type StreamingProvider interface {
+	Provider
+	Stream(ctx context.Context, req GenerateRequest, onToken TokenFunc) (string, error)
+}
+
+// GenerateStream streams from provider when it supports streaming and
+// otherwise falls back to a single Generate call delivered as one token.
+func GenerateStream(ctx context.Context, provider Provider, req GenerateRequest, onToken TokenFunc) (string, error) {
+	if sp, ok := provider.(StreamingProvider); ok {
+		return sp.Stream(ctx, req, onToken)
+	}
+
+	text, err := provider.Generate(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if err := onToken(text); err != nil {
+		return "", err
+	}
+	return text, nil
+}
+
+// openStream POSTs payload and returns the response body for incremental
+// reading. The caller must close it.
+func openStream(ctx context.Context, client *http.Client, url, apiKey string, payload interface{}) (io.ReadCloser, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	// Streams can legitimately outlive the client's overall timeout; the
+	// request context bounds them instead
+	streamClient := *client
+	streamClient.Timeout = 0
+
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return nil, &RequestError{Err: err}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, newAPIError(resp, respBody)
+	}
+	return resp.Body, nil
+}
+
+// readSSE calls onData with the payload of every "data:" line in an
+// event stream until the stream ends or onData returns done.
+func readSSE(r io.Reader, onData func(data string) (done bool, err error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		done, err := onData(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
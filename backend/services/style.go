@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"go_health_sentiment/langdetect"
+	"go_health_sentiment/models"
+	"go_health_sentiment/prompts"
+)
+
+// SamplePreviewEntry is the entry answered in each style when the user
+// doesn't preview with their own text.
+const SamplePreviewEntry = "Work has been piling up and I snapped at my partner last night over nothing. " +
+	"I keep telling myself I should be able to handle this, but I lie awake going over everything I haven't done. " +
+	"I did manage a walk at lunch today, which helped a little."
+
+// styleTemperatures sets the sampling temperature for each response style:
+// lower where replies should stay focused, higher where they should feel
+// more conversational.
+var styleTemperatures = map[string]float64{
+	models.StyleConcise:         0.5,
+	models.StyleWarm:            0.8,
+	models.StyleCBT:             0.6,
+	models.StyleSolutionFocused: 0.5,
+	models.StyleSocratic:        0.7,
+}
+
+// lengthTokenScales scales the configured token limit for each response
+// length.
+var lengthTokenScales = map[string]float64{
+	models.LengthShort:  0.75,
+	models.LengthMedium: 1,
+	models.LengthLong:   1.5,
+}
+
+// styledParams adjusts the generation parameters for the user's response
+// preferences. Preferences left unset keep the configured values.
+func styledParams(params GenerationParams, prefs models.ResponsePreferences) GenerationParams {
+	if t, ok := styleTemperatures[prefs.Style]; ok {
+		params.Temperature = t
+	}
+	if scale, ok := lengthTokenScales[prefs.Length]; ok && params.MaxTokens > 0 {
+		params.MaxTokens = int(math.Round(float64(params.MaxTokens) * scale))
+	}
+	return params
+}
+
+// styleInstructions renders the user's response preferences as
+// instructions for the model. It is empty when they keep the defaults.
+func (c *ChatConversation) styleInstructions(prefs models.ResponsePreferences, language string) (string, error) {
+	if prefs == (models.ResponsePreferences{}) {
+		return "", nil
+	}
+	tmpl, err := c.prompts.ResolveLanguage(prompts.ResponseStyle, "", language)
+	if err != nil {
+		return "", err
+	}
+	return tmpl.Render(prefs)
+}
+
+// withStyle appends style instructions to a system prompt.
+func withStyle(system, style string) string {
+	switch {
+	case style == "":
+		return system
+	case system == "":
+		return style
+	}
+	return strings.TrimRight(system, "\n") + "\n\n" + style
+}
+
+// StylePreview is a sample analysis written in one response style. Error is
+// set instead of Result when that style's analysis failed.
+type StylePreview struct {
+	Style  string                 `json:"style"`
+	Result *models.AnalysisResult `json:"result,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+// PreviewStyles analyzes content once in each response style, with the
+// given length and tone, so the user can compare them. Previews aren't
+// cached or added to the user's conversation, but count towards their
+// usage. screen, if set, may replace each result before it is returned.
+// The previews share one ReplyTimeout.
+func (c *ChatConversation) PreviewStyles(ctx context.Context, userID int, content, length, tone string, screen func(*models.AnalysisResult)) ([]StylePreview, error) {
+	version, err := models.GetUserPromptVersion(ctx, c.db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading prompt preference: %v", err)
+	}
+	language := langdetect.Detect(content).Language
+
+	ctx, cancel := c.replyContext(ctx)
+	defer cancel()
+
+	previews := make([]StylePreview, len(models.ResponseStyles))
+	var wg sync.WaitGroup
+	for i, style := range models.ResponseStyles {
+		previews[i].Style = style
+		prefs := models.ResponsePreferences{Style: style, Length: length, Tone: tone}
+
+		wg.Add(1)
+		go func(p *StylePreview) {
+			defer wg.Done()
+			result, err := c.previewStyle(ctx, userID, version, language, content, prefs)
+			if err != nil {
+				p.Error = err.Error()
+				return
+			}
+			if screen != nil {
+				screen(result)
+			}
+			p.Result = result
+		}(&previews[i])
+	}
+	wg.Wait()
+
+	// Previews cut short by the deadline or a departed client aren't worth
+	// returning one by one
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return previews, nil
+}
+
+// previewStyle runs one analysis for PreviewStyles.
+func (c *ChatConversation) previewStyle(ctx context.Context, userID int, version, language, content string, prefs models.ResponsePreferences) (*models.AnalysisResult, error) {
+	ar, err := c.newAnalysisRequest(version, language, content, "", prefs, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	ctx, meter := withUsageMeter(ctx)
+	ctx, err = c.withRedaction(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.generateAnalysis(ctx, ar)
+	c.recordUsage(ctx, userID, ar.promptID, false, meter.Usage(), time.Since(start), err)
+	return result, err
+}
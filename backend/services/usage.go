@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"go_health_sentiment/models"
+)
+
+// Usage is the token count of one or more model calls. Estimated is set
+// when any of the calls came from a provider that doesn't report counts.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	Estimated        bool
+}
+
+type usageMeterKey struct{}
+
+// usageMeter sums the usage providers report for calls made with its
+// context, across retries and repair calls.
+type usageMeter struct {
+	mu    sync.Mutex
+	usage Usage
+}
+
+func withUsageMeter(ctx context.Context) (context.Context, *usageMeter) {
+	m := &usageMeter{}
+	return context.WithValue(ctx, usageMeterKey{}, m), m
+}
+
+func (m *usageMeter) Usage() Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage
+}
+
+// reportUsage adds one call's token counts to the meter in ctx, if there
+// is one. Zero counts mean the provider didn't report them and they are
+// estimated from the text.
+func reportUsage(ctx context.Context, req GenerateRequest, completion string, promptTokens, completionTokens int) {
+	m, ok := ctx.Value(usageMeterKey{}).(*usageMeter)
+	if !ok {
+		return
+	}
+
+	estimated := false
+	if promptTokens == 0 && completionTokens == 0 {
+		for _, msg := range req.Messages {
+			promptTokens += estimateTokens(msg.Content)
+		}
+		completionTokens = estimateTokens(completion)
+		estimated = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.usage.PromptTokens += promptTokens
+	m.usage.CompletionTokens += completionTokens
+	m.usage.Estimated = m.usage.Estimated || estimated
+}
+
+// estimateTokens approximates a token count at four characters per token.
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// Quota limits a user's analyses per day and per calendar month. Zero
+// means unlimited.
+type Quota struct {
+	DailyCalls    int64 `json:"daily_calls"`
+	MonthlyCalls  int64 `json:"monthly_calls"`
+	DailyTokens   int64 `json:"daily_tokens"`
+	MonthlyTokens int64 `json:"monthly_tokens"`
+}
+
+// ParseQuotaPlans parses plan quotas written as
+// "free=daily_calls:20,monthly_tokens:200000;pro=daily_calls:200".
+func ParseQuotaPlans(spec string) (map[string]Quota, error) {
+	plans := make(map[string]Quota)
+	for _, planSpec := range strings.Split(spec, ";") {
+		planSpec = strings.TrimSpace(planSpec)
+		if planSpec == "" {
+			continue
+		}
+
+		name, limits, ok := strings.Cut(planSpec, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid quota plan %q: expected name=limits", planSpec)
+		}
+
+		var quota Quota
+		for _, limit := range strings.Split(limits, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(limit), ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid limit %q in plan %s", limit, name)
+			}
+			n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid value for %s in plan %s", key, name)
+			}
+
+			switch strings.TrimSpace(key) {
+			case "daily_calls":
+				quota.DailyCalls = n
+			case "monthly_calls":
+				quota.MonthlyCalls = n
+			case "daily_tokens":
+				quota.DailyTokens = n
+			case "monthly_tokens":
+				quota.MonthlyTokens = n
+			default:
+				return nil, fmt.Errorf("unknown limit %s in plan %s", key, name)
+			}
+		}
+		plans[strings.TrimSpace(name)] = quota
+	}
+	return plans, nil
+}
+
+// UsageReport is a user's consumption against their quota.
+type UsageReport struct {
+	Plan  string             `json:"plan"`
+	Quota Quota              `json:"quota"`
+	Today models.UsageTotals `json:"today"`
+	Month models.UsageTotals `json:"month"`
+
+	// Exceeded names the period whose quota is used up ("daily" or
+	// "monthly"), if any
+	Exceeded string `json:"exceeded,omitempty"`
+}
+
+// QuotaExceededMessage is saved in place of an analysis once the user's
+// quota is used up.
+func (r *UsageReport) QuotaExceededMessage() string {
+	return fmt.Sprintf("Your entry has been saved. You've reached your %s analysis limit, so this entry wasn't analyzed.", r.Exceeded)
+}
+
+// UsageTracker records LLM usage and enforces per-plan and per-user quotas.
+type UsageTracker struct {
+	db          *sql.DB
+	plans       map[string]Quota
+	defaultPlan string
+}
+
+func NewUsageTracker(db *sql.DB, plans map[string]Quota, defaultPlan string) *UsageTracker {
+	return &UsageTracker{
+		db:          db,
+		plans:       plans,
+		defaultPlan: defaultPlan,
+	}
+}
+
+// Report returns the user's usage this day and month and whether their
+// quota is exhausted.
+func (t *UsageTracker) Report(ctx context.Context, userID int) (*UsageReport, error) {
+	plan, err := models.GetUserPlan(ctx, t.db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading plan: %v", err)
+	}
+	if _, ok := t.plans[plan]; !ok {
+		plan = t.defaultPlan
+	}
+
+	quota := t.plans[plan]
+	override, err := models.GetUserQuotaOverride(ctx, t.db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading quota: %v", err)
+	}
+	if override != nil {
+		applyOverride(&quota.DailyCalls, override.DailyCalls)
+		applyOverride(&quota.MonthlyCalls, override.MonthlyCalls)
+		applyOverride(&quota.DailyTokens, override.DailyTokens)
+		applyOverride(&quota.MonthlyTokens, override.MonthlyTokens)
+	}
+
+	day, month, err := models.GetUsageTotals(ctx, t.db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading usage: %v", err)
+	}
+
+	report := &UsageReport{Plan: plan, Quota: quota, Today: day, Month: month}
+	switch {
+	case exhausted(quota.MonthlyCalls, month.Calls), exhausted(quota.MonthlyTokens, month.Tokens):
+		report.Exceeded = "monthly"
+	case exhausted(quota.DailyCalls, day.Calls), exhausted(quota.DailyTokens, day.Tokens):
+		report.Exceeded = "daily"
+	}
+	return report, nil
+}
+
+func applyOverride(limit *int64, override *int64) {
+	if override != nil {
+		*limit = *override
+	}
+}
+
+func exhausted(limit, used int64) bool {
+	return limit > 0 && used >= limit
+}
+
+// Record stores a usage record. Failures are logged rather than returned so
+// accounting never fails an analysis, and the record is written even when
+// ctx was cancelled so abandoned calls are still counted.
+func (t *UsageTracker) Record(ctx context.Context, record *models.UsageRecord) {
+	if err := record.Create(context.WithoutCancel(ctx), t.db); err != nil {
+		log.Printf("Error recording LLM usage for user %d: %v", record.UserID, err)
+	}
+}
+
+// Recent returns the user's latest usage records.
+func (t *UsageTracker) Recent(ctx context.Context, userID, limit int) ([]models.UsageRecord, error) {
+	return models.GetRecentUsage(ctx, t.db, userID, limit)
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQuotaPlans(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    map[string]Quota
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			spec: "",
+			want: map[string]Quota{},
+		},
+		{
+			name: "two plans",
+			spec: "free=daily_calls:20,monthly_tokens:200000;pro=daily_calls:200",
+			want: map[string]Quota{
+				"free": {DailyCalls: 20, MonthlyTokens: 200000},
+				"pro":  {DailyCalls: 200},
+			},
+		},
+		{
+			name: "every limit with spacing",
+			spec: " team = daily_calls : 1 , monthly_calls: 2, daily_tokens:3 ,monthly_tokens:4 ; ",
+			want: map[string]Quota{
+				"team": {DailyCalls: 1, MonthlyCalls: 2, DailyTokens: 3, MonthlyTokens: 4},
+			},
+		},
+		{
+			name: "zero is unlimited",
+			spec: "free=daily_calls:0",
+			want: map[string]Quota{"free": {}},
+		},
+		{name: "missing equals", spec: "free", wantErr: true},
+		{name: "missing colon", spec: "free=daily_calls", wantErr: true},
+		{name: "not a number", spec: "free=daily_calls:many", wantErr: true},
+		{name: "negative", spec: "free=daily_calls:-1", wantErr: true},
+		{name: "unknown limit", spec: "free=weekly_calls:5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseQuotaPlans(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseQuotaPlans(%q) = %v, want an error", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseQuotaPlans(%q): %v", tt.spec, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseQuotaPlans(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,10 +1,17 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
+// StatusClientClosedRequest reports a request abandoned by the client
+// before it was answered. It is nginx's non-standard code; the client never
+// sees it, but it keeps cancellations apart from failures in the logs.
+const StatusClientClosedRequest = 499
+
 type APIResponse struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message,omitempty"`
@@ -45,6 +52,14 @@ func WriteCreated(w http.ResponseWriter, message string, data interface{}) {
 	})
 }
 
+func WriteAccepted(w http.ResponseWriter, message string, data interface{}) {
+	WriteJSON(w, http.StatusAccepted, APIResponse{
+		Success: true,
+		Message: message,
+		Data:    data,
+	})
+}
+
 func WriteError(w http.ResponseWriter, status int, message string) {
 	WriteJSON(w, status, APIResponse{
 		Success: false,
@@ -58,4 +73,22 @@ func WriteValidationError(w http.ResponseWriter, errors []ValidationError) {
 		Message: "Validation failed",
 		Errors:  errors,
 	})
-}
\ No newline at end of file
+}
+
+// WriteContextError answers a request whose operation ended because ctx was
+// cancelled or its deadline passed, and reports whether it did. Other errors
+// are left for the caller to report.
+func WriteContextError(w http.ResponseWriter, ctx context.Context, err error) bool {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		WriteError(w, http.StatusGatewayTimeout, "The request took too long, please try again")
+	case errors.Is(err, context.Canceled):
+		WriteError(w, StatusClientClosedRequest, "Request cancelled")
+	default:
+		return false
+	}
+	return true
+}
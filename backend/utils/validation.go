@@ -51,6 +51,48 @@ func ValidateJournalContent(content string) []ValidationError {
 	return errors
 }
 
+func ValidateChatMessage(message string) []ValidationError {
+	var errors []ValidationError
+
+	message = strings.TrimSpace(message)
+	if len(message) == 0 {
+		errors = append(errors, ValidationError{
+			Field:   "message",
+			Message: "Message cannot be empty",
+		})
+	}
+
+	if len(message) > 2000 {
+		errors = append(errors, ValidationError{
+			Field:   "message",
+			Message: "Message must be less than 2,000 characters",
+		})
+	}
+
+	return errors
+}
+
+func ValidateSearchQuery(query string) []ValidationError {
+	var errors []ValidationError
+
+	query = strings.TrimSpace(query)
+	if len(query) == 0 {
+		errors = append(errors, ValidationError{
+			Field:   "semantic",
+			Message: "Search query cannot be empty",
+		})
+	}
+
+	if len(query) > 500 {
+		errors = append(errors, ValidationError{
+			Field:   "semantic",
+			Message: "Search query must be less than 500 characters",
+		})
+	}
+
+	return errors
+}
+
 func SanitizeInput(input string) string {
 	return strings.TrimSpace(input)
 }
\ No newline at end of file
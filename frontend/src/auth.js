@@ -0,0 +1,94 @@
+// Session handling for the API. Access tokens are short-lived, so requests
+// that come back 401 trade the refresh token for a new pair and are retried
+// once before the user is sent back to the login page.
+
+export const API_URL = "http://localhost:8080";
+
+// A refresh token works only once, so concurrent requests share one refresh
+let refreshing = null;
+
+export function isLoggedIn() {
+  return localStorage.getItem("jwt") !== null;
+}
+
+// saveSession stores the tokens from a login, register or refresh response.
+// The refresh token is missing when the server sends it as a cookie.
+export function saveSession(data) {
+  localStorage.setItem("jwt", data.token);
+  if (data.refresh_token) {
+    localStorage.setItem("refresh_token", data.refresh_token);
+  }
+}
+
+export function clearSession() {
+  localStorage.removeItem("jwt");
+  localStorage.removeItem("refresh_token");
+}
+
+async function refreshSession() {
+  const response = await fetch(`${API_URL}/token/refresh`, {
+    method: "POST",
+    credentials: "include",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({
+      refresh_token: localStorage.getItem("refresh_token") || "",
+    }),
+  });
+  if (!response.ok) {
+    clearSession();
+    return false;
+  }
+
+  const data = await response.json();
+  saveSession(data.data);
+  return true;
+}
+
+function send(path, options) {
+  return fetch(`${API_URL}${path}`, {
+    ...options,
+    headers: {
+      ...options.headers,
+      Authorization: `Bearer ${localStorage.getItem("jwt")}`,
+    },
+  });
+}
+
+// authFetch calls the API with the stored access token, refreshing it and
+// retrying once when it has expired. A 401 it returns means the session is
+// over and the user has to log in again.
+export async function authFetch(path, options = {}) {
+  const response = await send(path, options);
+  if (response.status !== 401) {
+    return response;
+  }
+
+  if (!refreshing) {
+    refreshing = refreshSession().finally(() => {
+      refreshing = null;
+    });
+  }
+  if (!(await refreshing)) {
+    return response;
+  }
+  return send(path, options);
+}
+
+// logout revokes the access token and refresh token on the server before
+// forgetting them, so a copy of either stops working too.
+export async function logout() {
+  try {
+    await authFetch("/logout", {
+      method: "POST",
+      credentials: "include",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({
+        refresh_token: localStorage.getItem("refresh_token") || "",
+      }),
+    });
+  } catch (error) {
+    console.error("Logout error:", error);
+  } finally {
+    clearSession();
+  }
+}